@@ -0,0 +1,283 @@
+package main
+
+import (
+	"hack/barns"
+	"hack/horses"
+	"hack/riders"
+	"hack/rides"
+	"hack/users"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const userKey = "user"
+
+// currentUser is the user resolved from the session by the session middleware.
+func currentUser(c *fiber.Ctx) *users.User {
+	user, _ := c.Locals(userKey).(*users.User)
+	return user
+}
+
+// membership returns the signed in user's link to barnID, failing with 403
+// unless their role grants permission.
+func membership(c *fiber.Ctx, s stores, barnID int64, permission barns.Permission) (*barns.BarnOwner, error) {
+	user := currentUser(c)
+	if user == nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "not signed in")
+	}
+	member, err := barns.GetMembership(barnID, user.ID, s.barns)
+	if err == utils.ErrNotFound {
+		return nil, fiber.NewError(fiber.StatusForbidden, "not a member of this barn")
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to check barn membership: "+err.Error())
+	}
+	if !member.Role.Can(permission) {
+		return nil, fiber.NewError(fiber.StatusForbidden, "role "+string(member.Role)+" does not allow "+string(permission))
+	}
+	return member, nil
+}
+
+func authorizeBarn(c *fiber.Ctx, s stores, barnID int64, permission barns.Permission) error {
+	_, err := membership(c, s, barnID, permission)
+	return err
+}
+
+func authorizeHorse(c *fiber.Ctx, s stores, horseID int64, permission barns.Permission) (*horses.Horse, error) {
+	horse, err := horses.GetHorse(horseID, s.horses)
+	if err == utils.ErrNotFound {
+		return nil, fiber.NewError(fiber.StatusNotFound, "horse not found")
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to get horse: "+err.Error())
+	}
+	return horse, authorizeBarn(c, s, horse.BarnID, permission)
+}
+
+func authorizeRider(c *fiber.Ctx, s stores, riderID int64, permission barns.Permission) (*riders.Rider, error) {
+	rider, err := riders.GetRider(riderID, s.riders)
+	if err == utils.ErrNotFound {
+		return nil, fiber.NewError(fiber.StatusNotFound, "rider not found")
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to get rider: "+err.Error())
+	}
+	return rider, authorizeBarn(c, s, rider.BarnID, permission)
+}
+
+// authorizeRiderView lets members of the rider's barn, the rider's own
+// account and their guardians see the rider. It also reports whether the
+// user may read the rider's medical notes, which takes ManageRiders or a
+// link to the rider.
+func authorizeRiderView(c *fiber.Ctx, s stores, riderID int64) (*riders.Rider, bool, error) {
+	user := currentUser(c)
+	if user == nil {
+		return nil, false, fiber.NewError(fiber.StatusUnauthorized, "not signed in")
+	}
+	rider, err := riders.GetRider(riderID, s.riders)
+	if err == utils.ErrNotFound {
+		return nil, false, fiber.NewError(fiber.StatusNotFound, "rider not found")
+	}
+	if err != nil {
+		return nil, false, fiber.NewError(fiber.StatusInternalServerError, "Failed to get rider: "+err.Error())
+	}
+	linked, err := riderLinked(c, s, rider)
+	if err != nil {
+		return nil, false, fiber.NewError(fiber.StatusInternalServerError, "Failed to check rider link: "+err.Error())
+	}
+	if linked {
+		return rider, true, nil
+	}
+	member, err := membership(c, s, rider.BarnID, barns.ViewBarn)
+	if err != nil {
+		return nil, false, err
+	}
+	return rider, member.Role.Can(barns.ManageRiders), nil
+}
+
+// riderLinked reports whether the current user is the rider or one of their
+// guardians and still belongs to the rider's barn. A link is made while the
+// user is a member and does not outlive their membership.
+func riderLinked(c *fiber.Ctx, s stores, rider *riders.Rider) (bool, error) {
+	userID := currentUser(c).ID
+	linked, err := rider.IsLinked(userID, s.riders)
+	if err != nil || !linked {
+		return false, err
+	}
+	_, err = barns.GetMembership(rider.BarnID, userID, s.barns)
+	if err == utils.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// authorizeHorseAndRider checks the pair a ride or schedule is for: both have
+// to be in a barn the user belongs to, and in the same barn.
+func authorizeHorseAndRider(c *fiber.Ctx, s stores, horseID int64, riderID int64, permission barns.Permission) error {
+	horse, err := authorizeHorse(c, s, horseID, permission)
+	if err != nil {
+		return err
+	}
+	rider, err := authorizeRider(c, s, riderID, permission)
+	if err != nil {
+		return err
+	}
+	if horse.BarnID != rider.BarnID {
+		return fiber.NewError(fiber.StatusBadRequest, "horse and rider belong to different barns")
+	}
+	return nil
+}
+
+// authorizeRide checks the ride as submitted and, when it already exists,
+// the ride as stored, so a ride cannot be moved out of another barn.
+func authorizeRide(c *fiber.Ctx, s stores, ride *rides.Ride) error {
+	if ride.ID > 0 {
+		existing, err := s.rides.GetRide(ride.ID)
+		if err == utils.ErrNotFound {
+			return fiber.NewError(fiber.StatusNotFound, "ride not found")
+		}
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to get ride: "+err.Error())
+		}
+		err = authorizeHorseAndRider(c, s, existing.HorseID, existing.RiderID, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+	}
+	if ride.ScheduleID != nil {
+		_, err := authorizeScheduleID(c, s, *ride.ScheduleID, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+	}
+	err := authorizeHorseAndRider(c, s, ride.HorseID, ride.RiderID, barns.ManageRides)
+	if err != nil {
+		return err
+	}
+	err = authorizeResource(s, ride.ResourceID, ride.HorseID)
+	if err != nil {
+		return err
+	}
+	err = authorizeInstructor(s, ride.InstructorID, ride.HorseID)
+	if err != nil {
+		return err
+	}
+	return authorizeLesson(s, ride.LessonID, ride.HorseID)
+}
+
+func authorizeScheduleID(c *fiber.Ctx, s stores, id int64, permission barns.Permission) (*rides.Schedule, error) {
+	schedule, err := s.rides.GetSchedule(id)
+	if err == utils.ErrNotFound {
+		return nil, fiber.NewError(fiber.StatusNotFound, "schedule not found")
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to get schedule: "+err.Error())
+	}
+	return schedule, authorizeHorseAndRider(c, s, schedule.HorseID, schedule.RiderID, permission)
+}
+
+func authorizeSchedule(c *fiber.Ctx, s stores, schedule *rides.Schedule) error {
+	if schedule.ID > 0 {
+		_, err := authorizeScheduleID(c, s, schedule.ID, barns.ManageSchedules)
+		if err != nil {
+			return err
+		}
+	}
+	err := authorizeHorseAndRider(c, s, schedule.HorseID, schedule.RiderID, barns.ManageSchedules)
+	if err != nil {
+		return err
+	}
+	err = authorizeResource(s, schedule.ResourceID, schedule.HorseID)
+	if err != nil {
+		return err
+	}
+	return authorizeInstructor(s, schedule.InstructorID, schedule.HorseID)
+}
+
+// authorizeResource checks that the resource a booking reserves, if any, is
+// in the barn of its horse. The horse has been authorized already.
+func authorizeResource(s stores, resourceID *int64, horseID int64) error {
+	if resourceID == nil {
+		return nil
+	}
+	horse, err := horses.GetHorse(horseID, s.horses)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get horse: "+err.Error())
+	}
+	_, err = rides.GetResource(horse.BarnID, *resourceID, s.rides)
+	if err == utils.ErrNotFound {
+		return fiber.NewError(fiber.StatusBadRequest, "resource not found in the horse's barn")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get resource: "+err.Error())
+	}
+	return nil
+}
+
+// authorizeInstructor checks that the instructor of a booking, if any, is in
+// the barn of its horse. The horse has been authorized already.
+func authorizeInstructor(s stores, instructorID *int64, horseID int64) error {
+	if instructorID == nil {
+		return nil
+	}
+	horse, err := horses.GetHorse(horseID, s.horses)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get horse: "+err.Error())
+	}
+	_, err = rides.GetInstructor(horse.BarnID, *instructorID, s.rides)
+	if err == utils.ErrNotFound {
+		return fiber.NewError(fiber.StatusBadRequest, "instructor not found in the horse's barn")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get instructor: "+err.Error())
+	}
+	return nil
+}
+
+// authorizeInstructorAndResource checks that the instructor and resource
+// something of barnID is set up with, if any, are in that barn.
+func authorizeInstructorAndResource(s stores, barnID int64, instructorID *int64, resourceID *int64) error {
+	if instructorID != nil {
+		_, err := rides.GetInstructor(barnID, *instructorID, s.rides)
+		if err == utils.ErrNotFound {
+			return fiber.NewError(fiber.StatusBadRequest, "instructor not found in this barn")
+		}
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to get instructor: "+err.Error())
+		}
+	}
+	if resourceID != nil {
+		_, err := rides.GetResource(barnID, *resourceID, s.rides)
+		if err == utils.ErrNotFound {
+			return fiber.NewError(fiber.StatusBadRequest, "resource not found in this barn")
+		}
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to get resource: "+err.Error())
+		}
+	}
+	return nil
+}
+
+// authorizeLesson checks that the group lesson a ride joins, if any, is in
+// the barn of its horse. The horse has been authorized already.
+func authorizeLesson(s stores, lessonID *int64, horseID int64) error {
+	if lessonID == nil {
+		return nil
+	}
+	horse, err := horses.GetHorse(horseID, s.horses)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get horse: "+err.Error())
+	}
+	lesson, err := s.rides.GetLesson(*lessonID)
+	if err == utils.ErrNotFound || err == nil && lesson.BarnID != horse.BarnID {
+		return fiber.NewError(fiber.StatusBadRequest, "lesson not found in the horse's barn")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get lesson: "+err.Error())
+	}
+	return nil
+}
@@ -1,14 +1,18 @@
 package barns
 
 import (
-	"database/sql"
 	"errors"
+	"time"
+
+	"hack/utils"
 )
 
 type Barn struct {
 	ID        int64  `json:"id"`
 	Name      string `json:"name"`
 	IsPrimary bool   `json:"is_primary,omitempty"`
+	// TimeZone is the IANA zone the barn's ride dates and times are in.
+	TimeZone string `json:"time_zone"`
 }
 
 type Owner struct {
@@ -17,100 +21,108 @@ type Owner struct {
 	UserID int64  `json:"user_id"`
 }
 
-type sqlOwner struct {
-	Owner
-	Name sql.NullString
-}
-
 type BarnOwner struct {
 	ID            int64 `json:"id"`
 	BarnID        int64 `json:"barn_id"`
 	OwnerID       int64 `json:"owner_id"`
 	IsPrimaryBarn bool  `json:"is_primary_barn"`
+	Role          Role  `json:"role"`
+}
+
+type BarnStore interface {
+	GetBarn(id int64) (*Barn, error)
+	InsertBarn(b *Barn) error
+	UpdateBarn(b *Barn) error
+	GetBarnsByUserID(userID int64) ([]*Barn, error)
+	GetOwnerByUserID(userID int64) (*Owner, error)
+	InsertOwner(o *Owner) error
+	InsertBarnOwner(bo *BarnOwner) error
+	// GetBarnOwner returns the link between a barn and the owner record of
+	// userID, or utils.ErrNotFound when the user does not belong to the barn.
+	GetBarnOwner(barnID int64, userID int64) (*BarnOwner, error)
+	GetBarnOwnerByID(id int64) (*BarnOwner, error)
+	ListBarnMembers(barnID int64) ([]*Member, error)
+	UpdateBarnOwnerRole(id int64, role Role) error
+	DeleteBarnOwner(id int64) error
+	CountBarnOwnersWithRole(barnID int64, role Role) (int, error)
+	InvitationStore
 }
 
-func (b *Barn) Save(userID int64, db *sql.DB) error {
-	query := "insert into barns (name) values (?)"
-	result, err := db.Exec(query, b.Name)
+func (b *Barn) Save(userID int64, store BarnStore) error {
+	err := b.validateTimeZone()
 	if err != nil {
-		return errors.New("failed to insert barn into database: " + err.Error())
+		return err
 	}
-	b.ID, err = result.LastInsertId()
+	err = store.InsertBarn(b)
 	if err != nil {
-		return errors.New("failed to get last insert ID: " + err.Error())
+		return err
 	}
-	owner, err := HandleOwner(userID, db)
+	owner, err := HandleOwner(userID, store)
 	if err != nil {
 		return errors.New("failed to handle owner: " + err.Error())
 	}
 
 	// add user as owner of barn
-	_, err = NewBarnOwner(b.ID, owner.ID, db)
+	_, err = NewBarnOwner(b.ID, owner.ID, store)
 	if err != nil {
 		return errors.New("failed to add barn to owner: " + err.Error())
 	}
 	return nil
 }
 
-func GetBarnsByUserID(userID string, db *sql.DB) ([]*Barn, error) {
-	query := "select b.id, b.name, bo.is_primary_barn from barns b join barn_owners bo on b.id = bo.barn_id join owners o on bo.owner_id = o.id where o.user_id = ?"
-	rows, err := db.Query(query, userID)
-	if err != nil {
-		return nil, errors.New("failed to select barns from database: " + err.Error())
+func (b *Barn) validateTimeZone() error {
+	if b.TimeZone == "" {
+		b.TimeZone = "UTC"
 	}
-	defer rows.Close()
-	var barns []*Barn
-	for rows.Next() {
-		var b Barn
-		err := rows.Scan(&b.ID, &b.Name, &b.IsPrimary)
-		if err != nil {
-			return nil, errors.New("failed to scan row: " + err.Error())
-		}
-		barns = append(barns, &b)
+	_, err := time.LoadLocation(b.TimeZone)
+	if err != nil {
+		return errors.New("unknown time zone: " + b.TimeZone)
 	}
-	return barns, nil
+	return nil
 }
 
-func HandleOwner(userID int64, db *sql.DB) (*Owner, error) {
-	var owner Owner
-	owner.UserID = userID
-	query := "select id, name from owners where user_id = ?"
-	row := db.QueryRow(query, userID)
-	var o sqlOwner
-	err := row.Scan(&o.ID, &o.Name)
+func GetBarn(id int64, store BarnStore) (*Barn, error) {
+	return store.GetBarn(id)
+}
+
+func UpdateBarn(b *Barn, store BarnStore) error {
+	err := b.validateTimeZone()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			// create owner
-			insert := "insert into owners (user_id) values (?)"
-			result, err := db.Exec(insert, owner.UserID)
-			if err != nil {
-				return nil, errors.New("failed to insert owner into database: " + err.Error())
-			}
-			owner.ID, err = result.LastInsertId()
-			if err != nil {
-				return nil, errors.New("failed to get last insert ID: " + err.Error())
-			}
-		} else {
-			return nil, errors.New("failed to scan row: " + err.Error())
-		}
+		return err
 	}
-	owner.ID = o.ID
-	owner.Name = o.Name.String
-	return &owner, nil
+	return store.UpdateBarn(b)
+}
+
+func GetBarnsByUserID(userID int64, store BarnStore) ([]*Barn, error) {
+	return store.GetBarnsByUserID(userID)
 }
 
-func NewBarnOwner(barnID int64, ownerID int64, db *sql.DB) (*BarnOwner, error) {
-	query := "insert into barn_owners (barn_id, owner_id) values (?, ?)"
-	result, err := db.Exec(query, barnID, ownerID)
+func HandleOwner(userID int64, store BarnStore) (*Owner, error) {
+	owner, err := store.GetOwnerByUserID(userID)
+	if err == nil {
+		return owner, nil
+	}
+	if err != utils.ErrNotFound {
+		return nil, err
+	}
+	// create owner
+	owner = &Owner{UserID: userID}
+	err = store.InsertOwner(owner)
 	if err != nil {
-		return nil, errors.New("failed to insert barn owner into database: " + err.Error())
+		return nil, err
+	}
+	return owner, nil
+}
+
+func NewBarnOwner(barnID int64, ownerID int64, store BarnStore) (*BarnOwner, error) {
+	barnOwner := BarnOwner{
+		BarnID:  barnID,
+		OwnerID: ownerID,
+		Role:    RoleOwner,
 	}
-	var barnOwner BarnOwner
-	barnOwner.ID, err = result.LastInsertId()
+	err := store.InsertBarnOwner(&barnOwner)
 	if err != nil {
-		return nil, errors.New("failed to get last insert ID: " + err.Error())
+		return nil, err
 	}
-	barnOwner.BarnID = barnID
-	barnOwner.OwnerID = ownerID
 	return &barnOwner, nil
 }
@@ -0,0 +1,131 @@
+package barns
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"hack/utils"
+)
+
+const invitationExpiration = 7 * 24 * time.Hour
+
+var ErrInvalidInvitation = errors.New("invitation code is invalid, expired or already used")
+
+type Invitation struct {
+	ID         int64      `json:"id"`
+	BarnID     int64      `json:"barn_id"`
+	Role       Role       `json:"role"`
+	Phone      string     `json:"phone,omitempty"`
+	Email      string     `json:"email,omitempty"`
+	InvitedBy  int64      `json:"invited_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	AcceptedBy *int64     `json:"accepted_by,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// Code is only filled in when the invitation is created; the store keeps
+	// a hash of it.
+	Code     string `json:"code,omitempty"`
+	CodeHash string `json:"-"`
+}
+
+type InvitationStore interface {
+	InsertInvitation(inv *Invitation) error
+	GetInvitation(id int64) (*Invitation, error)
+	GetInvitationByCodeHash(codeHash string) (*Invitation, error)
+	ListPendingInvitations(barnID int64, now time.Time) ([]*Invitation, error)
+	UpdateInvitation(inv *Invitation) error
+}
+
+// Save creates the invitation and its one-time code.
+func (inv *Invitation) Save(store InvitationStore) error {
+	if !inv.Role.Valid() {
+		return errors.New("invalid role: " + string(inv.Role))
+	}
+	inv.Phone = strings.TrimSpace(inv.Phone)
+	inv.Email = strings.TrimSpace(inv.Email)
+	if inv.Phone == "" && inv.Email == "" {
+		return errors.New("an invitation needs a phone or an email")
+	}
+	code, err := newInvitationCode()
+	if err != nil {
+		return err
+	}
+	inv.Code = code
+	inv.CodeHash = hashInvitationCode(code)
+	inv.CreatedAt = time.Now().UTC()
+	inv.ExpiresAt = inv.CreatedAt.Add(invitationExpiration)
+	return store.InsertInvitation(inv)
+}
+
+func ListPendingInvitations(barnID int64, store InvitationStore) ([]*Invitation, error) {
+	return store.ListPendingInvitations(barnID, time.Now().UTC())
+}
+
+func RevokeInvitation(barnID int64, id int64, store InvitationStore) error {
+	inv, err := store.GetInvitation(id)
+	if err != nil {
+		return err
+	}
+	if inv.BarnID != barnID {
+		return utils.ErrNotFound
+	}
+	if inv.AcceptedAt != nil {
+		return errors.New("invitation has already been accepted")
+	}
+	now := time.Now().UTC()
+	inv.RevokedAt = &now
+	return store.UpdateInvitation(inv)
+}
+
+// AcceptInvitation redeems code for the user with the given phone and
+// email, which have to match whatever the invitation was addressed to.
+func AcceptInvitation(code string, userID int64, phone string, email string, store BarnStore) (*BarnOwner, error) {
+	inv, err := store.GetInvitationByCodeHash(hashInvitationCode(code))
+	if err == utils.ErrNotFound {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if inv.AcceptedAt != nil || inv.RevokedAt != nil || now.After(inv.ExpiresAt) {
+		return nil, ErrInvalidInvitation
+	}
+	if inv.Phone != "" && inv.Phone != phone {
+		return nil, errors.New("invitation was sent to a different phone number")
+	}
+	if inv.Email != "" && !strings.EqualFold(inv.Email, email) {
+		return nil, errors.New("invitation was sent to a different email")
+	}
+	member, err := AddMember(inv.BarnID, userID, inv.Role, store)
+	if err != nil {
+		return nil, err
+	}
+	inv.AcceptedAt = &now
+	inv.AcceptedBy = &userID
+	err = store.UpdateInvitation(inv)
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func newInvitationCode() (string, error) {
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.New("failed to generate invitation code: " + err.Error())
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+func hashInvitationCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,177 @@
+package barns
+
+import (
+	"errors"
+
+	"hack/utils"
+)
+
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleManager Role = "manager"
+	RoleTrainer Role = "trainer"
+	RoleRider   Role = "rider"
+	RoleViewer  Role = "viewer"
+)
+
+type Permission string
+
+const (
+	ViewBarn        Permission = "view_barn"
+	ManageBarn      Permission = "manage_barn"
+	ManageHorses    Permission = "manage_horses"
+	ManageRiders    Permission = "manage_riders"
+	ManageRides     Permission = "manage_rides"
+	ManageSchedules Permission = "manage_schedules"
+	ManageMembers   Permission = "manage_members"
+	// ManageOwners covers inviting, promoting and removing owners.
+	ManageOwners Permission = "manage_owners"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleOwner:   {ViewBarn, ManageBarn, ManageHorses, ManageRiders, ManageRides, ManageSchedules, ManageMembers, ManageOwners},
+	RoleManager: {ViewBarn, ManageBarn, ManageHorses, ManageRiders, ManageRides, ManageSchedules, ManageMembers},
+	RoleTrainer: {ViewBarn, ManageRides, ManageSchedules},
+	RoleRider:   {ViewBarn},
+	RoleViewer:  {ViewBarn},
+}
+
+var ErrLastOwner = errors.New("a barn must keep at least one owner")
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAssign reports whether a member with role r may hand out role to
+// someone else or take it away.
+func (r Role) CanAssign(role Role) bool {
+	if !r.Can(ManageMembers) {
+		return false
+	}
+	return role != RoleOwner || r.Can(ManageOwners)
+}
+
+type Member struct {
+	ID     int64  `json:"id"`
+	BarnID int64  `json:"barn_id"`
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	// Email and Phone are left blank in the list shown to members who
+	// cannot manage members.
+	Email         string `json:"email"`
+	Phone         string `json:"phone"`
+	Role          Role   `json:"role"`
+	IsPrimaryBarn bool   `json:"is_primary_barn"`
+}
+
+func GetMembership(barnID int64, userID int64, store BarnStore) (*BarnOwner, error) {
+	return store.GetBarnOwner(barnID, userID)
+}
+
+func ListMembers(barnID int64, store BarnStore) ([]*Member, error) {
+	return store.ListBarnMembers(barnID)
+}
+
+// AddMember links userID to the barn with role, creating their owner record
+// if they have never belonged to a barn.
+func AddMember(barnID int64, userID int64, role Role, store BarnStore) (*BarnOwner, error) {
+	_, err := store.GetBarnOwner(barnID, userID)
+	if err == nil {
+		return nil, errors.New("user is already a member of this barn")
+	}
+	if err != utils.ErrNotFound {
+		return nil, err
+	}
+	owner, err := HandleOwner(userID, store)
+	if err != nil {
+		return nil, errors.New("failed to handle owner: " + err.Error())
+	}
+	barnOwner := BarnOwner{
+		BarnID:  barnID,
+		OwnerID: owner.ID,
+		Role:    role,
+	}
+	err = store.InsertBarnOwner(&barnOwner)
+	if err != nil {
+		return nil, err
+	}
+	return &barnOwner, nil
+}
+
+func ChangeRole(barnID int64, memberID int64, role Role, store BarnStore) (*BarnOwner, error) {
+	if !role.Valid() {
+		return nil, errors.New("invalid role: " + string(role))
+	}
+	member, err := getMember(barnID, memberID, store)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role == RoleOwner && role != RoleOwner {
+		err = checkNotLastOwner(barnID, store)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = store.UpdateBarnOwnerRole(member.ID, role)
+	if err != nil {
+		return nil, err
+	}
+	member.Role = role
+	return member, nil
+}
+
+func RemoveMember(barnID int64, memberID int64, store BarnStore) error {
+	member, err := getMember(barnID, memberID, store)
+	if err != nil {
+		return err
+	}
+	if member.Role == RoleOwner {
+		err = checkNotLastOwner(barnID, store)
+		if err != nil {
+			return err
+		}
+	}
+	return store.DeleteBarnOwner(member.ID)
+}
+
+func Leave(barnID int64, userID int64, store BarnStore) error {
+	member, err := store.GetBarnOwner(barnID, userID)
+	if err != nil {
+		return err
+	}
+	return RemoveMember(barnID, member.ID, store)
+}
+
+func getMember(barnID int64, memberID int64, store BarnStore) (*BarnOwner, error) {
+	member, err := store.GetBarnOwnerByID(memberID)
+	if err != nil {
+		return nil, err
+	}
+	if member.BarnID != barnID {
+		return nil, utils.ErrNotFound
+	}
+	return member, nil
+}
+
+func checkNotLastOwner(barnID int64, store BarnStore) error {
+	owners, err := store.CountBarnOwnersWithRole(barnID, RoleOwner)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
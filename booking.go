@@ -0,0 +1,266 @@
+package main
+
+import (
+	"hack/barns"
+	"hack/booking"
+	"hack/riders"
+	"hack/rides"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerBookingRoutes(app *fiber.App, s stores) {
+	// a barn that has not set a policy gets booking.DefaultPolicy
+	app.Get("/barn/:barnID/booking-policy", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		policy, err := booking.GetPolicy(barnID, s.booking)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get booking policy", err)
+		}
+		return c.JSON(fiber.Map{
+			"policy": policy,
+		})
+	})
+
+	app.Put("/barn/:barnID/booking-policy", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		var policy booking.Policy
+		err = parseBody(c, &policy, "booking policy")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ManageBarn)
+		if err != nil {
+			return err
+		}
+		policy.BarnID = barnID
+		err = policy.Save(s.booking)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save booking policy", err)
+		}
+		return c.JSON(fiber.Map{
+			"policy": policy,
+		})
+	})
+
+	app.Delete("/barn/:barnID/booking-policy", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ManageBarn)
+		if err != nil {
+			return err
+		}
+		err = booking.DeletePolicy(barnID, s.booking)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to delete booking policy", err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+		})
+	})
+
+	app.Post("/barn/:barnID/availability", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		var template rides.AvailabilityTemplate
+		err = parseBody(c, &template, "availability template")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ManageSchedules)
+		if err != nil {
+			return err
+		}
+		template.ID = 0
+		template.BarnID = barnID
+		return saveAvailabilityTemplate(c, s, &template)
+	})
+
+	app.Get("/barn/:barnID/availability", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		templates, err := rides.ListAvailabilityTemplates(barnID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get availability templates", err)
+		}
+		return c.JSON(fiber.Map{
+			"templates": templates,
+		})
+	})
+
+	// changing a template leaves the rides already booked from it alone
+	app.Put("/barn/:barnID/availability/:templateID", func(c *fiber.Ctx) error {
+		var template rides.AvailabilityTemplate
+		err := parseBody(c, &template, "availability template")
+		if err != nil {
+			return err
+		}
+		existing, err := barnAvailabilityTemplate(c, s, barns.ManageSchedules)
+		if err != nil {
+			return err
+		}
+		template.ID = existing.ID
+		template.BarnID = existing.BarnID
+		return saveAvailabilityTemplate(c, s, &template)
+	})
+
+	app.Delete("/barn/:barnID/availability/:templateID", func(c *fiber.Ctx) error {
+		template, err := barnAvailabilityTemplate(c, s, barns.ManageSchedules)
+		if err != nil {
+			return err
+		}
+		err = rides.DeleteAvailabilityTemplate(template.ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to delete availability template", err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+		})
+	})
+
+	// slots are the open slots of the rider's barn from from to to that the
+	// rider can book now under the barn's policy
+	app.Get("/rider/:id/slots", func(c *fiber.Ctx) error {
+		from, to, err := dateRange(c)
+		if err != nil {
+			return err
+		}
+		rider, err := bookingRider(c, s)
+		if err != nil {
+			return err
+		}
+		slots, err := booking.ListSlots(rider.ID, from, to, s.booking)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get slots", err)
+		}
+		return c.JSON(fiber.Map{
+			"slots": slots,
+		})
+	})
+
+	app.Post("/rider/:id/bookings", func(c *fiber.Ctx) error {
+		var req booking.Request
+		err := parseBody(c, &req, "booking")
+		if err != nil {
+			return err
+		}
+		rider, err := bookingRider(c, s)
+		if err != nil {
+			return err
+		}
+		ride, err := booking.Book(rider.ID, req, s.booking)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to book slot", err)
+		}
+		return c.JSON(fiber.Map{
+			"ride": ride,
+		})
+	})
+
+	// cancel holds the rider to the barn's cancellation policy; the barn
+	// cancels later than that through PUT /ride/cancel
+	app.Put("/rider/:id/bookings/:rideID/cancel", func(c *fiber.Ctx) error {
+		rideID, err := paramID(c, "rideID")
+		if err != nil {
+			return err
+		}
+		rider, err := bookingRider(c, s)
+		if err != nil {
+			return err
+		}
+		ride, err := booking.Cancel(rider.ID, rideID, currentUser(c).ID, s.booking)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to cancel ride", err)
+		}
+		return c.JSON(fiber.Map{
+			"ride": ride,
+		})
+	})
+}
+
+// bookingRider resolves the :id param to a rider that the current user can
+// book for: their own rider or one they are a guardian of, as long as they
+// still belong to its barn, or any rider of a barn they manage rides in.
+func bookingRider(c *fiber.Ctx, s stores) (*riders.Rider, error) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return nil, err
+	}
+	rider, err := riders.GetRider(id, s.riders)
+	if err != nil {
+		return nil, failed(fiber.StatusInternalServerError, "Failed to get rider", err)
+	}
+	linked, err := riderLinked(c, s, rider)
+	if err != nil {
+		return nil, failed(fiber.StatusInternalServerError, "Failed to check rider link", err)
+	}
+	if !linked {
+		err = authorizeBarn(c, s, rider.BarnID, barns.ManageRides)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rider, nil
+}
+
+// saveAvailabilityTemplate checks that the template's instructor and
+// resource are in its barn, saves it and responds with it as it now reads.
+func saveAvailabilityTemplate(c *fiber.Ctx, s stores, template *rides.AvailabilityTemplate) error {
+	err := authorizeInstructorAndResource(s, template.BarnID, template.InstructorID, template.ResourceID)
+	if err != nil {
+		return err
+	}
+	err = template.Save(s.rides)
+	if err != nil {
+		return failed(fiber.StatusBadRequest, "Failed to save availability template", err)
+	}
+	saved, err := rides.GetAvailabilityTemplate(template.BarnID, template.ID, s.rides)
+	if err != nil {
+		return failed(fiber.StatusInternalServerError, "Failed to get availability template", err)
+	}
+	return c.JSON(fiber.Map{
+		"template": saved,
+	})
+}
+
+// barnAvailabilityTemplate resolves the :barnID and :templateID params to a
+// template of that barn.
+func barnAvailabilityTemplate(c *fiber.Ctx, s stores, permission barns.Permission) (*rides.AvailabilityTemplate, error) {
+	barnID, err := paramID(c, "barnID")
+	if err != nil {
+		return nil, err
+	}
+	id, err := paramID(c, "templateID")
+	if err != nil {
+		return nil, err
+	}
+	err = authorizeBarn(c, s, barnID, permission)
+	if err != nil {
+		return nil, err
+	}
+	template, err := rides.GetAvailabilityTemplate(barnID, id, s.rides)
+	if err != nil {
+		return nil, failed(fiber.StatusInternalServerError, "Failed to get availability template", err)
+	}
+	return template, nil
+}
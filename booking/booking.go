@@ -0,0 +1,321 @@
+package booking
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"hack/matching"
+	"hack/recurrence"
+	"hack/rides"
+	"hack/utils"
+)
+
+// now is when slots are booked and rides cancelled; tests fix it.
+var now = time.Now
+
+// Store is everything self-service booking needs from persistence.
+type Store interface {
+	matching.Store
+	InsertBookingPolicy(p *Policy) error
+	UpdateBookingPolicy(p *Policy) error
+	DeleteBookingPolicy(barnID int64) error
+	GetBookingPolicy(barnID int64) (*Policy, error)
+}
+
+// Policy is how a barn lets riders book and cancel rides themselves. A limit
+// of zero is no limit.
+type Policy struct {
+	BarnID int64 `json:"barn_id"`
+	// MinNoticeHours is how long before it starts a slot can still be
+	// booked.
+	MinNoticeHours int `json:"min_notice_hours"`
+	// MaxAdvanceDays is how many days ahead of today slots can be booked.
+	MaxAdvanceDays int `json:"max_advance_days,omitempty"`
+	// MaxRidesPerDay and MaxRidesPerWeek are how many rides a rider can
+	// have on a day and in a Monday to Sunday week, however they were
+	// booked, before they cannot book another.
+	MaxRidesPerDay  int `json:"max_rides_per_day,omitempty"`
+	MaxRidesPerWeek int `json:"max_rides_per_week,omitempty"`
+	// CancelNoticeHours is how long before it starts a rider can still
+	// cancel a ride themselves; after that only the barn can.
+	CancelNoticeHours int `json:"cancel_notice_hours"`
+}
+
+// DefaultPolicy is the policy of a barn that has not set its own: slots are
+// booked and cancelled no later than a day ahead.
+func DefaultPolicy(barnID int64) *Policy {
+	return &Policy{BarnID: barnID, MinNoticeHours: 24, CancelNoticeHours: 24}
+}
+
+// Request is a rider's booking of a slot. HorseID left out is the horse
+// that suits the rider best among those free for the slot, and
+// EventTypeID left out is the slot's event type when it has only one.
+type Request struct {
+	TemplateID  int64       `json:"template_id"`
+	Date        utils.Date  `json:"date"`
+	Time        *utils.Time `json:"time"`
+	EventTypeID int64       `json:"event_type_id,omitempty"`
+	HorseID     int64       `json:"horse_id,omitempty"`
+	Notes       string      `json:"notes"`
+}
+
+func (p *Policy) validate() error {
+	if p.MinNoticeHours < 0 || p.MaxAdvanceDays < 0 || p.MaxRidesPerDay < 0 || p.MaxRidesPerWeek < 0 || p.CancelNoticeHours < 0 {
+		return errors.New("booking limits cannot be negative")
+	}
+	return nil
+}
+
+func (p *Policy) Save(store Store) error {
+	err := p.validate()
+	if err != nil {
+		return err
+	}
+	_, err = store.GetBookingPolicy(p.BarnID)
+	if err == utils.ErrNotFound {
+		return store.InsertBookingPolicy(p)
+	}
+	if err != nil {
+		return err
+	}
+	return store.UpdateBookingPolicy(p)
+}
+
+// GetPolicy returns the barn's policy, or DefaultPolicy if it has none.
+func GetPolicy(barnID int64, store Store) (*Policy, error) {
+	p, err := store.GetBookingPolicy(barnID)
+	if err == utils.ErrNotFound {
+		return DefaultPolicy(barnID), nil
+	}
+	return p, err
+}
+
+// DeletePolicy puts the barn back on DefaultPolicy.
+func DeletePolicy(barnID int64, store Store) error {
+	return store.DeleteBookingPolicy(barnID)
+}
+
+// checkWindow refuses a slot too soon or too far ahead to book at now.
+func (p *Policy) checkWindow(slot *rides.Slot, now time.Time) error {
+	if !now.Before(*slot.StartsAt) {
+		return errors.New("the slot has started")
+	}
+	if now.Add(time.Duration(p.MinNoticeHours) * time.Hour).After(*slot.StartsAt) {
+		return errors.New("slots have to be booked at least " + strconv.Itoa(p.MinNoticeHours) + " hours ahead")
+	}
+	today := recurrence.Day(now.In(slot.StartsAt.Location()))
+	if p.MaxAdvanceDays > 0 && slot.Date.After(today.AddDate(0, 0, p.MaxAdvanceDays)) {
+		return errors.New("slots can only be booked up to " + strconv.Itoa(p.MaxAdvanceDays) + " days ahead")
+	}
+	return nil
+}
+
+// checkLimits refuses a slot that would take the rider past the policy's
+// limits, given the rider's days around it.
+func (p *Policy) checkLimits(slot *rides.Slot, riderDays []*rides.Day) error {
+	start := rides.WeekStart(slot.Date).Time
+	end := start.AddDate(0, 0, 7)
+	day, week := 0, 0
+	for _, d := range riderDays {
+		if d.Date.Before(start) || !d.Date.Before(end) {
+			continue
+		}
+		for _, r := range d.Rides {
+			if r.Status == rides.Cancelled {
+				continue
+			}
+			week++
+			if d.Date.Equal(slot.Date.Time) {
+				day++
+			}
+		}
+	}
+	if p.MaxRidesPerDay > 0 && day >= p.MaxRidesPerDay {
+		return errors.New("the rider already has " + strconv.Itoa(day) + " rides that day, the most the barn allows")
+	}
+	if p.MaxRidesPerWeek > 0 && week >= p.MaxRidesPerWeek {
+		return errors.New("the rider already has " + strconv.Itoa(week) + " rides that week, the most the barn allows")
+	}
+	return nil
+}
+
+// ListSlots returns the open slots of the rider's barn from from to to that
+// the rider can book now: inside the barn's booking window, within the
+// rider's limits and clear of the rider's other rides.
+func ListSlots(riderID int64, from utils.Date, to utils.Date, store Store) ([]*rides.Slot, error) {
+	rider, err := store.GetRider(riderID)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := GetPolicy(rider.BarnID, store)
+	if err != nil {
+		return nil, err
+	}
+	open, err := rides.OpenSlots(rider.BarnID, from, to, store)
+	if err != nil {
+		return nil, err
+	}
+	riderDays, err := rides.GetRiderScheduleByRange(riderID, rides.WeekStart(from), utils.Date{Time: rides.WeekStart(to).AddDate(0, 0, 6)}, store)
+	if err != nil {
+		return nil, err
+	}
+	at := now()
+	slots := []*rides.Slot{}
+	for _, slot := range open {
+		if policy.checkWindow(slot, at) != nil || policy.checkLimits(slot, riderDays) != nil {
+			continue
+		}
+		if overlapsAny(slot, riderDays) {
+			continue
+		}
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+// Book books the slot asked for as a ride for the rider, holding it to the
+// barn's policy. The ride is saved as Ride.Save saves it, never overriding
+// conflicts or workload rules. A slot that is not open gets
+// rides.ErrSlotTaken.
+func Book(riderID int64, req Request, store Store) (*rides.Ride, error) {
+	rider, err := store.GetRider(riderID)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := GetPolicy(rider.BarnID, store)
+	if err != nil {
+		return nil, err
+	}
+	if req.Date.IsZero() {
+		return nil, errors.New("a booking needs a date")
+	}
+	slot, err := rides.FindSlot(rider.BarnID, req.TemplateID, req.Date, req.Time, store)
+	if err != nil {
+		return nil, err
+	}
+	err = policy.checkWindow(slot, now())
+	if err != nil {
+		return nil, err
+	}
+	riderDays, err := rides.GetRiderScheduleByRange(riderID, rides.WeekStart(slot.Date), utils.Date{Time: rides.WeekStart(slot.Date).AddDate(0, 0, 6)}, store)
+	if err != nil {
+		return nil, err
+	}
+	err = policy.checkLimits(slot, riderDays)
+	if err != nil {
+		return nil, err
+	}
+	eventTypeID, err := pickEventType(slot, req.EventTypeID)
+	if err != nil {
+		return nil, err
+	}
+	ride := slot.Ride(req.HorseID, riderID, eventTypeID)
+	ride.Notes = req.Notes
+	if ride.HorseID == 0 {
+		ride.HorseID, err = pickHorse(*ride, store)
+	} else {
+		err = checkHorse(ride.HorseID, rider.BarnID, store)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = ride.Save(store, false)
+	if err != nil {
+		return nil, err
+	}
+	return ride, nil
+}
+
+// Cancel cancels a ride of the rider on behalf of actorID, no later than the
+// barn's policy allows. A ride without a time is taken to start when its day
+// does. Cancelling a cancelled ride does nothing.
+func Cancel(riderID int64, rideID int64, actorID int64, store Store) (*rides.Ride, error) {
+	ride, err := store.GetRide(rideID)
+	if err != nil {
+		return nil, err
+	}
+	if ride.RiderID != riderID {
+		return nil, utils.ErrNotFound
+	}
+	if ride.Status == rides.Cancelled {
+		return ride, nil
+	}
+	rider, err := store.GetRider(riderID)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := GetPolicy(rider.BarnID, store)
+	if err != nil {
+		return nil, err
+	}
+	start := ride.StartsAt
+	if start == nil {
+		zone, err := store.GetHorseTimeZone(ride.HorseID)
+		if err != nil && err != utils.ErrNotFound {
+			return nil, err
+		}
+		midnight := time.Date(ride.Date.Year(), ride.Date.Month(), ride.Date.Day(), 0, 0, 0, 0, utils.Location(zone))
+		start = &midnight
+	}
+	if now().Add(time.Duration(policy.CancelNoticeHours) * time.Hour).After(*start) {
+		return nil, errors.New("rides can only be cancelled " + strconv.Itoa(policy.CancelNoticeHours) + " hours before they start; ask the barn")
+	}
+	err = ride.Cancel(actorID, store)
+	if err != nil {
+		return nil, err
+	}
+	return ride, nil
+}
+
+func pickEventType(slot *rides.Slot, eventTypeID int64) (int64, error) {
+	if eventTypeID == 0 && len(slot.EventTypes) == 1 {
+		return slot.EventTypes[0].ID, nil
+	}
+	for _, t := range slot.EventTypes {
+		if t.ID == eventTypeID {
+			return eventTypeID, nil
+		}
+	}
+	return 0, errors.New("pick one of the slot's event types")
+}
+
+// pickHorse returns the horse matching ranks first for the ride, as long as
+// it suits the rider and is free.
+func pickHorse(ride rides.Ride, store Store) (int64, error) {
+	ranking, err := matching.Rank(ride, "", store)
+	if err != nil {
+		return 0, err
+	}
+	if len(ranking.Matches) == 0 || !ranking.Matches[0].Available || !ranking.Matches[0].Suitable {
+		return 0, errors.New("no horse that suits the rider is free for the slot")
+	}
+	return ranking.Matches[0].Horse.ID, nil
+}
+
+func checkHorse(horseID int64, barnID int64, store Store) error {
+	barnHorses, err := store.GetHorsesByBarnID(barnID)
+	if err != nil {
+		return err
+	}
+	for _, h := range barnHorses {
+		if h.ID == horseID {
+			return nil
+		}
+	}
+	return errors.New("horse not found in the rider's barn")
+}
+
+func overlapsAny(slot *rides.Slot, days []*rides.Day) bool {
+	for _, day := range days {
+		if !day.Date.Equal(slot.Date.Time) {
+			continue
+		}
+		for _, r := range day.Rides {
+			if slot.Overlaps(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
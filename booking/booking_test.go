@@ -0,0 +1,221 @@
+package booking
+
+import (
+	"testing"
+	"time"
+
+	"hack/riders"
+	"hack/rides"
+	"hack/utils"
+)
+
+var newYork = utils.Location("America/New_York")
+
+func date(s string) utils.Date {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return utils.Date{Time: t}
+}
+
+// at is the instant s reads as on the barn's clock in New York.
+func at(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, newYork)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// slotAt is a slot starting at s on the barn's clock.
+func slotAt(s string) *rides.Slot {
+	start := at(s)
+	return &rides.Slot{Date: date(start.Format("2006-01-02")), StartsAt: &start}
+}
+
+func TestCheckWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		now    time.Time
+		ok     bool
+	}{
+		{"well ahead", Policy{MinNoticeHours: 24}, at("2031-01-05 10:00"), true},
+		{"just enough notice", Policy{MinNoticeHours: 24}, at("2031-01-06 10:00"), true},
+		{"not enough notice", Policy{MinNoticeHours: 24}, at("2031-01-06 10:01"), false},
+		{"no notice needed", Policy{}, at("2031-01-07 09:59"), true},
+		{"started", Policy{}, at("2031-01-07 10:00"), false},
+		{"over", Policy{}, at("2031-01-07 12:00"), false},
+		{"last day of the window", Policy{MaxAdvanceDays: 7}, at("2030-12-31 08:00"), true},
+		{"past the window", Policy{MaxAdvanceDays: 7}, at("2030-12-30 23:00"), false},
+		// 2031-01-06 03:00 UTC is still the evening before on the barn's clock
+		{"window counted from the barn's today", Policy{MaxAdvanceDays: 1}, time.Date(2031, 1, 6, 3, 0, 0, 0, time.UTC), false},
+		{"window counted from the barn's today, a day later", Policy{MaxAdvanceDays: 1}, time.Date(2031, 1, 6, 6, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.checkWindow(slotAt("2031-01-07 10:00"), tt.now)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// riderWeek is a rider's days from Sunday 2031-01-05 to Monday 2031-01-13:
+// a ride on each of those two days, which fall outside the week of Monday
+// 2031-01-06, one on that Monday, and one and a cancelled one on the
+// Tuesday.
+func riderWeek() []*rides.Day {
+	var days []*rides.Day
+	for d := date("2031-01-05"); !d.After(date("2031-01-13").Time); d = (utils.Date{Time: d.AddDate(0, 0, 1)}) {
+		days = append(days, &rides.Day{Date: d, Rides: []*rides.RideDetail{}})
+	}
+	book := func(i int, status rides.Status) {
+		days[i].Rides = append(days[i].Rides, &rides.RideDetail{Ride: rides.Ride{Date: days[i].Date, Status: status}})
+	}
+	book(0, rides.Scheduled)
+	book(1, rides.Completed)
+	book(2, rides.Scheduled)
+	book(2, rides.Cancelled)
+	book(8, rides.Scheduled)
+	return days
+}
+
+func TestCheckLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		slot   string
+		ok     bool
+	}{
+		{"no limits", Policy{}, "2031-01-07 10:00", true},
+		{"room that day", Policy{MaxRidesPerDay: 2}, "2031-01-07 10:00", true},
+		{"day full", Policy{MaxRidesPerDay: 1}, "2031-01-07 10:00", false},
+		{"another day", Policy{MaxRidesPerDay: 1}, "2031-01-08 10:00", true},
+		{"room that week", Policy{MaxRidesPerWeek: 3}, "2031-01-08 10:00", true},
+		{"week full", Policy{MaxRidesPerWeek: 2}, "2031-01-08 10:00", false},
+		{"week full on its sunday", Policy{MaxRidesPerWeek: 2}, "2031-01-12 10:00", false},
+		{"next week", Policy{MaxRidesPerWeek: 2}, "2031-01-13 10:00", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.checkLimits(slotAt(tt.slot), riderWeek())
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// fakeStore has rider 1 in barn 1, whose policy is policy, and their rides.
+// It embeds Store and only implements what Cancel reaches; anything else
+// panics.
+type fakeStore struct {
+	Store
+	policy  *Policy
+	rides   map[int64]*rides.Ride
+	changes []*rides.StatusChange
+}
+
+func (f *fakeStore) GetBookingPolicy(barnID int64) (*Policy, error) {
+	if f.policy == nil {
+		return nil, utils.ErrNotFound
+	}
+	return f.policy, nil
+}
+
+func (f *fakeStore) GetRider(id int64) (*riders.Rider, error) {
+	return &riders.Rider{ID: id, BarnID: 1}, nil
+}
+
+func (f *fakeStore) GetHorseTimeZone(horseID int64) (string, error) {
+	return "America/New_York", nil
+}
+
+func (f *fakeStore) GetRide(id int64) (*rides.Ride, error) {
+	r, ok := f.rides[id]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	ride := *r
+	return &ride, nil
+}
+
+func (f *fakeStore) UpdateRideStatus(id int64, status rides.Status) error {
+	f.rides[id].Status = status
+	return nil
+}
+
+func (f *fakeStore) InsertStatusChange(c *rides.StatusChange) error {
+	f.changes = append(f.changes, c)
+	return nil
+}
+
+func TestCancel(t *testing.T) {
+	start := at("2031-01-07 10:00")
+	tests := []struct {
+		name   string
+		policy *Policy
+		rideID int64
+		now    time.Time
+		ok     bool
+	}{
+		{"well ahead", nil, 1, at("2031-01-05 10:00"), true},
+		{"just enough notice", nil, 1, at("2031-01-06 10:00"), true},
+		{"not enough notice", nil, 1, at("2031-01-06 10:01"), false},
+		{"barn that needs no notice", &Policy{}, 1, at("2031-01-07 09:59"), true},
+		{"started", &Policy{}, 1, at("2031-01-07 10:01"), false},
+		// a ride without a time starts at midnight on the barn's clock
+		{"untimed with enough notice", nil, 2, at("2031-01-06 00:00"), true},
+		{"untimed without", nil, 2, at("2031-01-06 00:01"), false},
+		{"another rider's ride", nil, 3, at("2031-01-05 10:00"), false},
+		{"missing ride", nil, 9, at("2031-01-05 10:00"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{policy: tt.policy, rides: map[int64]*rides.Ride{
+				1: {ID: 1, HorseID: 1, RiderID: 1, Date: date("2031-01-07"), StartsAt: &start, Status: rides.Scheduled},
+				2: {ID: 2, HorseID: 1, RiderID: 1, Date: date("2031-01-07"), Status: rides.Scheduled},
+				3: {ID: 3, HorseID: 1, RiderID: 2, Date: date("2031-01-07"), StartsAt: &start, Status: rides.Scheduled},
+			}}
+			now = func() time.Time { return tt.now }
+			defer func() { now = time.Now }()
+
+			_, err := Cancel(1, tt.rideID, 1, store)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			cancelled := 0
+			for _, r := range store.rides {
+				if r.Status == rides.Cancelled {
+					cancelled++
+				}
+			}
+			want := 0
+			if tt.ok {
+				want = 1
+			}
+			if cancelled != want || len(store.changes) != want {
+				t.Fatalf("%d rides cancelled and %d changes recorded, want %d", cancelled, len(store.changes), want)
+			}
+		})
+	}
+}
+
+func TestCancelCancelled(t *testing.T) {
+	store := &fakeStore{rides: map[int64]*rides.Ride{
+		1: {ID: 1, HorseID: 1, RiderID: 1, Date: date("2031-01-07"), Status: rides.Cancelled},
+	}}
+	now = func() time.Time { return at("2031-01-07 12:00") }
+	defer func() { now = time.Now }()
+
+	r, err := Cancel(1, 1, 1, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != rides.Cancelled || len(store.changes) > 0 {
+		t.Fatalf("ride is %s with %d changes, want it left cancelled", r.Status, len(store.changes))
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"time"
+
+	"hack/barns"
+	"hack/rides"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxRangeDays keeps a single range query to about a year.
+const maxRangeDays = 366
+
+func registerCalendarRoutes(app *fiber.App, s stores) {
+	app.Get("/barn/:barnID/rides", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		from, to, err := dateRange(c)
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		days, err := rides.GetScheduleByRange(barnID, from, to, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get ride schedule", err)
+		}
+		return c.JSON(fiber.Map{
+			"days": days,
+		})
+	})
+
+	app.Get("/horse/:id/schedule", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		from, to, err := dateRange(c)
+		if err != nil {
+			return err
+		}
+		_, err = authorizeHorse(c, s, id, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		days, err := rides.GetHorseScheduleByRange(id, from, to, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get schedule", err)
+		}
+		return c.JSON(fiber.Map{
+			"days": days,
+		})
+	})
+
+	app.Get("/rider/:id/schedule", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		from, to, err := dateRange(c)
+		if err != nil {
+			return err
+		}
+		_, _, err = authorizeRiderView(c, s, id)
+		if err != nil {
+			return err
+		}
+		days, err := rides.GetRiderScheduleByRange(id, from, to, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get schedule", err)
+		}
+		return c.JSON(fiber.Map{
+			"days": days,
+		})
+	})
+}
+
+// dateRange reads the from and to query parameters, both YYYY-MM-DD and both
+// included in the range.
+func dateRange(c *fiber.Ctx) (utils.Date, utils.Date, error) {
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		return utils.Date{}, utils.Date{}, fiber.NewError(fiber.StatusBadRequest, "Failed to parse from: "+err.Error())
+	}
+	to, err := time.Parse("2006-01-02", c.Query("to"))
+	if err != nil {
+		return utils.Date{}, utils.Date{}, fiber.NewError(fiber.StatusBadRequest, "Failed to parse to: "+err.Error())
+	}
+	if to.Before(from) {
+		return utils.Date{}, utils.Date{}, fiber.NewError(fiber.StatusBadRequest, "to is before from")
+	}
+	if to.Sub(from) >= maxRangeDays*24*time.Hour {
+		return utils.Date{}, utils.Date{}, fiber.NewError(fiber.StatusBadRequest, "date range is limited to 366 days")
+	}
+	return utils.Date{Time: from}, utils.Date{Time: to}, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strings"
+
+	"hack/barns"
+	"hack/feeds"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerPublicFeedRoutes serves calendar feeds to calendar clients, which
+// cannot send the api key or a session, so it has to come before the
+// middleware that checks them. The token in the URL is the credential.
+func registerPublicFeedRoutes(app *fiber.App, s stores) {
+	app.Get("/feeds/:token", func(c *fiber.Ctx) error {
+		token := strings.TrimSuffix(c.Params("token"), ".ics")
+		feed, err := feeds.Resolve(token, s.feeds)
+		if err == feeds.ErrInvalidFeed {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get calendar feed", err)
+		}
+		// a feed stops working when whoever created it leaves the barn
+		_, err = barns.GetMembership(feed.BarnID, feed.CreatedBy, s.barns)
+		if err == utils.ErrNotFound {
+			return fiber.NewError(fiber.StatusNotFound, feeds.ErrInvalidFeed.Error())
+		}
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to check barn membership", err)
+		}
+		calendar, err := feed.Calendar(s.feeds)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to build calendar", err)
+		}
+		c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+		return c.Send(calendar.Bytes())
+	})
+}
+
+func registerFeedRoutes(app *fiber.App, s stores) {
+	app.Post("/barn/:barnID/feeds", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		var req feeds.Feed
+		err = parseBody(c, &req, "feed")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		feed := feeds.Feed{
+			BarnID:    barnID,
+			Scope:     req.Scope,
+			HorseID:   req.HorseID,
+			RiderID:   req.RiderID,
+			CreatedBy: currentUser(c).ID,
+		}
+		feed.Name, err = feedName(c, s, &feed)
+		if err != nil {
+			return err
+		}
+		err = feed.Save(s.feeds)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to create calendar feed", err)
+		}
+		return c.JSON(fiber.Map{
+			"feed": feed,
+			"url":  c.BaseURL() + "/feeds/" + feed.Token + ".ics",
+		})
+	})
+
+	// members see the feeds they made; managers see everyone's
+	app.Get("/barn/:barnID/feeds", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		member, err := membership(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		list, err := feeds.ListFeeds(barnID, s.feeds)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to list calendar feeds", err)
+		}
+		visible := []*feeds.Feed{}
+		for _, feed := range list {
+			if member.Role.Can(barns.ManageBarn) || feed.CreatedBy == currentUser(c).ID {
+				visible = append(visible, feed)
+			}
+		}
+		return c.JSON(fiber.Map{
+			"feeds": visible,
+		})
+	})
+
+	app.Delete("/barn/:barnID/feeds/:feedID", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		feedID, err := paramID(c, "feedID")
+		if err != nil {
+			return err
+		}
+		member, err := membership(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		feed, err := feeds.GetFeed(barnID, feedID, s.feeds)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get calendar feed", err)
+		}
+		if !member.Role.Can(barns.ManageBarn) && feed.CreatedBy != currentUser(c).ID {
+			return fiber.NewError(fiber.StatusForbidden, "cannot revoke another member's calendar feed")
+		}
+		err = feeds.RevokeFeed(feed, s.feeds)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to revoke calendar feed", err)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+}
+
+// feedName names the calendar after what the feed covers, checking that it
+// belongs to the feed's barn.
+func feedName(c *fiber.Ctx, s stores, feed *feeds.Feed) (string, error) {
+	switch feed.Scope {
+	case feeds.HorseFeed:
+		horse, err := authorizeHorse(c, s, feed.HorseID, barns.ViewBarn)
+		if err != nil {
+			return "", err
+		}
+		if horse.BarnID != feed.BarnID {
+			return "", fiber.NewError(fiber.StatusBadRequest, "horse belongs to a different barn")
+		}
+		return horse.Name, nil
+	case feeds.RiderFeed:
+		rider, err := authorizeRider(c, s, feed.RiderID, barns.ViewBarn)
+		if err != nil {
+			return "", err
+		}
+		if rider.BarnID != feed.BarnID {
+			return "", fiber.NewError(fiber.StatusBadRequest, "rider belongs to a different barn")
+		}
+		return rider.Name, nil
+	}
+	barn, err := barns.GetBarn(feed.BarnID, s.barns)
+	if err != nil {
+		return "", failed(fiber.StatusInternalServerError, "Failed to get barn", err)
+	}
+	return barn.Name, nil
+}
@@ -0,0 +1,196 @@
+package feeds
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"hack/ical"
+	"hack/recurrence"
+	"hack/rides"
+	"hack/utils"
+)
+
+// A feed covers pastDays before today and futureDays from today on the
+// barn's clock.
+const (
+	pastDays   = 90
+	futureDays = 366
+)
+
+var ErrInvalidFeed = errors.New("calendar feed is invalid or has been revoked")
+
+type Scope string
+
+const (
+	BarnFeed  Scope = "barn"
+	HorseFeed Scope = "horse"
+	RiderFeed Scope = "rider"
+)
+
+// Feed is a calendar subscription for a barn, a horse or a rider. Calendar
+// clients cannot send our headers, so the feed is fetched with a secret token
+// in its URL instead, and revoking the feed is how access is taken back.
+type Feed struct {
+	ID        int64      `json:"id"`
+	BarnID    int64      `json:"barn_id"`
+	Scope     Scope      `json:"scope"`
+	HorseID   int64      `json:"horse_id,omitempty"`
+	RiderID   int64      `json:"rider_id,omitempty"`
+	Name      string     `json:"name"`
+	TimeZone  string     `json:"time_zone,omitempty"`
+	CreatedBy int64      `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// Token is only filled in when the feed is created; the store keeps a
+	// hash of it.
+	Token     string `json:"token,omitempty"`
+	TokenHash string `json:"-"`
+}
+
+type FeedStore interface {
+	InsertFeed(f *Feed) error
+	GetFeed(id int64) (*Feed, error)
+	GetFeedByTokenHash(tokenHash string) (*Feed, error)
+	// ListFeeds returns the feeds of a barn that have not been revoked.
+	ListFeeds(barnID int64) ([]*Feed, error)
+	UpdateFeed(f *Feed) error
+}
+
+// Store is everything a feed needs to be served.
+type Store interface {
+	FeedStore
+	rides.Store
+}
+
+// Save creates the feed and its token.
+func (f *Feed) Save(store FeedStore) error {
+	switch f.Scope {
+	case BarnFeed:
+		f.HorseID, f.RiderID = 0, 0
+	case HorseFeed:
+		if f.HorseID == 0 {
+			return errors.New("a horse feed needs a horse_id")
+		}
+		f.RiderID = 0
+	case RiderFeed:
+		if f.RiderID == 0 {
+			return errors.New("a rider feed needs a rider_id")
+		}
+		f.HorseID = 0
+	default:
+		return errors.New("invalid feed scope: " + string(f.Scope))
+	}
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	f.Token = token
+	f.TokenHash = hashToken(token)
+	f.CreatedAt = time.Now().UTC()
+	return store.InsertFeed(f)
+}
+
+func ListFeeds(barnID int64, store FeedStore) ([]*Feed, error) {
+	return store.ListFeeds(barnID)
+}
+
+func GetFeed(barnID int64, id int64, store FeedStore) (*Feed, error) {
+	f, err := store.GetFeed(id)
+	if err != nil {
+		return nil, err
+	}
+	if f.BarnID != barnID {
+		return nil, utils.ErrNotFound
+	}
+	return f, nil
+}
+
+func RevokeFeed(f *Feed, store FeedStore) error {
+	if f.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	f.RevokedAt = &now
+	return store.UpdateFeed(f)
+}
+
+// Resolve returns the live feed for token.
+func Resolve(token string, store FeedStore) (*Feed, error) {
+	f, err := store.GetFeedByTokenHash(hashToken(token))
+	if err == utils.ErrNotFound {
+		return nil, ErrInvalidFeed
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.RevokedAt != nil {
+		return nil, ErrInvalidFeed
+	}
+	return f, nil
+}
+
+// Calendar builds the feed's calendar from the rides and schedules it covers.
+func (f *Feed) Calendar(store Store) (*ical.Calendar, error) {
+	loc := utils.Location(f.TimeZone)
+	today := recurrence.Day(time.Now().In(loc))
+	from := utils.Date{Time: today.AddDate(0, 0, -pastDays)}
+	to := utils.Date{Time: today.AddDate(0, 0, futureDays)}
+
+	var details []*rides.RideDetail
+	var schedules []*rides.Schedule
+	var err error
+	switch f.Scope {
+	case HorseFeed:
+		details, err = store.ListRidesByHorse(f.HorseID, from, to)
+		if err == nil {
+			schedules, err = store.ListActiveSchedulesByHorse(f.HorseID, from, to)
+		}
+	case RiderFeed:
+		details, err = store.ListRidesByRider(f.RiderID, from, to)
+		if err == nil {
+			schedules, err = store.ListActiveSchedulesByRider(f.RiderID, from, to)
+		}
+	default:
+		details, err = store.ListRidesByBarn(f.BarnID, from, to)
+		if err == nil {
+			schedules, err = store.ListActiveSchedulesByBarn(f.BarnID, from, to)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// exceptions count from each schedule's start, not just the feed's window
+	var ids []int64
+	first := from
+	for _, s := range schedules {
+		ids = append(ids, s.ID)
+		if s.StartDate.Before(first.Time) {
+			first = s.StartDate
+		}
+	}
+	replaced, err := store.ListOccurrenceRides(ids, first, to)
+	if err != nil {
+		return nil, err
+	}
+	return ical.Build(f.Name, loc, details, schedules, replaced), nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.New("failed to generate feed token: " + err.Error())
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
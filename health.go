@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"hack/barns"
+	"hack/horses"
+	"hack/recurrence"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultDueDays is how far ahead the due report looks without ?days=.
+const defaultDueDays = 30
+
+func registerHealthRoutes(app *fiber.App, s stores) {
+	app.Post("/horse/:id/health", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		var record horses.HealthRecord
+		err = parseBody(c, &record, "health record")
+		if err != nil {
+			return err
+		}
+		_, err = authorizeHorse(c, s, id, barns.ManageHorses)
+		if err != nil {
+			return err
+		}
+		record.ID = 0
+		record.HorseID = id
+		record.CreatedBy = currentUser(c).ID
+		err = record.Save(s.horses)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save health record", err)
+		}
+		return c.JSON(fiber.Map{
+			"record": record,
+		})
+	})
+
+	app.Get("/horse/:id/health", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		_, err = authorizeHorse(c, s, id, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		records, err := horses.ListHealthRecords(id, horses.HealthKind(c.Query("kind")), s.horses)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get health records", err)
+		}
+		return c.JSON(fiber.Map{
+			"records": records,
+		})
+	})
+
+	app.Put("/horse/:id/health/:recordID", func(c *fiber.Ctx) error {
+		record, err := healthRecord(c, s)
+		if err != nil {
+			return err
+		}
+		var update horses.HealthRecord
+		err = parseBody(c, &update, "health record")
+		if err != nil {
+			return err
+		}
+		update.ID = record.ID
+		update.HorseID = record.HorseID
+		update.CreatedBy = record.CreatedBy
+		update.CreatedAt = record.CreatedAt
+		err = update.Save(s.horses)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save health record", err)
+		}
+		return c.JSON(fiber.Map{
+			"record": update,
+		})
+	})
+
+	app.Delete("/horse/:id/health/:recordID", func(c *fiber.Ctx) error {
+		record, err := healthRecord(c, s)
+		if err != nil {
+			return err
+		}
+		err = horses.DeleteHealthRecord(record, s.horses)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to delete health record", err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+		})
+	})
+
+	// due lists care that is overdue or due within ?days= days, 30 by
+	// default, going by the latest record of each kind
+	app.Get("/barn/:barnID/health/due", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		days := defaultDueDays
+		if raw := c.Query("days"); raw != "" {
+			days, err = strconv.Atoi(raw)
+			if err != nil || days < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "days must be a whole number of days")
+			}
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		barn, err := barns.GetBarn(barnID, s.barns)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get barn", err)
+		}
+		today := utils.Date{Time: recurrence.Day(time.Now().In(utils.Location(barn.TimeZone)))}
+		items, err := horses.GetDueReport(barnID, today, days, s.horses)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get due report", err)
+		}
+		return c.JSON(fiber.Map{
+			"date": today,
+			"due":  items,
+		})
+	})
+}
+
+// healthRecord loads the :recordID health record of the :id horse for a
+// member who can manage horses.
+func healthRecord(c *fiber.Ctx, s stores) (*horses.HealthRecord, error) {
+	horseID, err := paramID(c, "id")
+	if err != nil {
+		return nil, err
+	}
+	id, err := paramID(c, "recordID")
+	if err != nil {
+		return nil, err
+	}
+	_, err = authorizeHorse(c, s, horseID, barns.ManageHorses)
+	if err != nil {
+		return nil, err
+	}
+	record, err := horses.GetHealthRecord(horseID, id, s.horses)
+	if err != nil {
+		return nil, failed(fiber.StatusInternalServerError, "Failed to get health record", err)
+	}
+	return record, nil
+}
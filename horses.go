@@ -0,0 +1,49 @@
+package main
+
+import (
+	"hack/barns"
+	"hack/horses"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerHorseRoutes(app *fiber.App, s stores) {
+	app.Get("/horse/:id", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		horse, err := authorizeHorse(c, s, id, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{
+			"horse": horse,
+		})
+	})
+
+	app.Put("/horse/:id", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		var horse horses.Horse
+		err = parseBody(c, &horse, "horse")
+		if err != nil {
+			return err
+		}
+		existing, err := authorizeHorse(c, s, id, barns.ManageHorses)
+		if err != nil {
+			return err
+		}
+		horse.ID = id
+		horse.BarnID = existing.BarnID
+		err = horse.Save(s.horses)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save horse", err)
+		}
+		return c.JSON(fiber.Map{
+			"horse": horse,
+		})
+	})
+}
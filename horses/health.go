@@ -0,0 +1,192 @@
+package horses
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"hack/utils"
+)
+
+type HealthKind string
+
+const (
+	Vaccination HealthKind = "vaccination"
+	Coggins     HealthKind = "coggins"
+	VetExam     HealthKind = "vet_exam"
+	Farrier     HealthKind = "farrier"
+	Dental      HealthKind = "dental"
+	Deworming   HealthKind = "deworming"
+)
+
+func (k HealthKind) Valid() bool {
+	switch k {
+	case Vaccination, Coggins, VetExam, Farrier, Dental, Deworming:
+		return true
+	}
+	return false
+}
+
+// HealthRecord is one visit or treatment. NextDue, when set, is when the
+// same kind of care is due again.
+type HealthRecord struct {
+	ID       int64       `json:"id"`
+	HorseID  int64       `json:"horse_id"`
+	Kind     HealthKind  `json:"kind"`
+	Date     utils.Date  `json:"date"`
+	Provider string      `json:"provider,omitempty"`
+	Notes    string      `json:"notes,omitempty"`
+	NextDue  *utils.Date `json:"next_due,omitempty"`
+	// Name tells apart records of one kind that are due separately, such
+	// as the vaccines a horse gets.
+	Name      string    `json:"name,omitempty"`
+	CreatedBy int64     `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type HealthStore interface {
+	InsertHealthRecord(r *HealthRecord) error
+	UpdateHealthRecord(r *HealthRecord) error
+	DeleteHealthRecord(id int64) error
+	GetHealthRecord(id int64) (*HealthRecord, error)
+	// ListHealthRecords returns the horse's records, newest first.
+	ListHealthRecords(horseID int64) ([]*HealthRecord, error)
+	ListHealthRecordsByBarnID(barnID int64) ([]*HealthRecord, error)
+}
+
+type Store interface {
+	HorseStore
+	HealthStore
+}
+
+func (r *HealthRecord) validate() error {
+	if !r.Kind.Valid() {
+		return errors.New("invalid health record kind: " + string(r.Kind))
+	}
+	if r.Date.IsZero() {
+		return errors.New("health record needs a date")
+	}
+	if r.NextDue != nil && r.NextDue.Before(r.Date.Time) {
+		return errors.New("next due date is before the record date")
+	}
+	return nil
+}
+
+func (r *HealthRecord) Save(store HealthStore) error {
+	err := r.validate()
+	if err != nil {
+		return err
+	}
+	if r.ID > 0 {
+		return store.UpdateHealthRecord(r)
+	}
+	r.CreatedAt = time.Now().UTC()
+	return store.InsertHealthRecord(r)
+}
+
+// GetHealthRecord returns the record only if it belongs to horseID.
+func GetHealthRecord(horseID int64, id int64, store HealthStore) (*HealthRecord, error) {
+	r, err := store.GetHealthRecord(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.HorseID != horseID {
+		return nil, utils.ErrNotFound
+	}
+	return r, nil
+}
+
+func DeleteHealthRecord(r *HealthRecord, store HealthStore) error {
+	return store.DeleteHealthRecord(r.ID)
+}
+
+// ListHealthRecords returns the horse's records, newest first, keeping only
+// those of kind when it is set.
+func ListHealthRecords(horseID int64, kind HealthKind, store HealthStore) ([]*HealthRecord, error) {
+	records, err := store.ListHealthRecords(horseID)
+	if err != nil {
+		return nil, err
+	}
+	if kind == "" {
+		return records, nil
+	}
+	matching := []*HealthRecord{}
+	for _, r := range records {
+		if r.Kind == kind {
+			matching = append(matching, r)
+		}
+	}
+	return matching, nil
+}
+
+const (
+	Overdue = "overdue"
+	DueSoon = "due_soon"
+)
+
+// DueItem is care a horse needs again, from the latest record of its kind.
+type DueItem struct {
+	HorseID   int64         `json:"horse_id"`
+	HorseName string        `json:"horse_name"`
+	Status    string        `json:"status"`
+	DaysLeft  int           `json:"days_left"`
+	Record    *HealthRecord `json:"record"`
+}
+
+// GetDueReport lists the care in the barn that is overdue on today or falls
+// due within days of it, most overdue first. Only the latest record of each
+// kind and name counts, so a booster given late clears the one it replaces.
+func GetDueReport(barnID int64, today utils.Date, days int, store Store) ([]*DueItem, error) {
+	horses, err := store.GetHorsesByBarnID(barnID)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string)
+	for _, h := range horses {
+		names[h.ID] = h.Name
+	}
+	records, err := store.ListHealthRecordsByBarnID(barnID)
+	if err != nil {
+		return nil, err
+	}
+	type key struct {
+		horseID int64
+		kind    HealthKind
+		name    string
+	}
+	latest := make(map[key]*HealthRecord)
+	for _, r := range records {
+		k := key{r.HorseID, r.Kind, r.Name}
+		if l, ok := latest[k]; !ok || r.Date.After(l.Date.Time) || (r.Date.Equal(l.Date.Time) && r.ID > l.ID) {
+			latest[k] = r
+		}
+	}
+	items := []*DueItem{}
+	for _, r := range latest {
+		if r.NextDue == nil {
+			continue
+		}
+		left := int(r.NextDue.Sub(today.Time).Hours() / 24)
+		if left > days {
+			continue
+		}
+		item := &DueItem{
+			HorseID:   r.HorseID,
+			HorseName: names[r.HorseID],
+			Status:    DueSoon,
+			DaysLeft:  left,
+			Record:    r,
+		}
+		if left < 0 {
+			item.Status = Overdue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].DaysLeft != items[j].DaysLeft {
+			return items[i].DaysLeft < items[j].DaysLeft
+		}
+		return items[i].HorseName < items[j].HorseName
+	})
+	return items, nil
+}
@@ -1,10 +1,10 @@
 package horses
 
 import (
-	"database/sql"
 	"errors"
-	"time"
+	"strings"
 
+	"hack/riders"
 	"hack/utils"
 )
 
@@ -12,70 +12,101 @@ type Horse struct {
 	ID     int64      `json:"id"`
 	Name   string     `json:"name"`
 	DOB    utils.Date `json:"dob"`
-	Gender gender     `json:"gender"`
+	Gender Gender     `json:"gender,omitempty"`
 	BarnID int64      `json:"barn_id"`
+	// Temperament, MinSkillLevel, MaxRiderWeightPounds and Disciplines are
+	// what matching looks at when suggesting a horse for a rider. Left
+	// unset, they rule no one out.
+	Temperament          Temperament       `json:"temperament,omitempty"`
+	MinSkillLevel        riders.SkillLevel `json:"min_skill_level,omitempty"`
+	MaxRiderWeightPounds int               `json:"max_rider_weight_pounds,omitempty"`
+	// Disciplines are lower case names like "dressage" or "jumping".
+	Disciplines []string `json:"disciplines,omitempty"`
 }
 
-func (h *Horse) Save(db *sql.DB) error {
-	query := "insert into horses (name, dob, gender, barn_id) values (?, ?, ?, ?)"
-	result, err := db.Exec(query, h.Name, h.DOB.Time.Format("2006-01-02"), h.Gender, h.BarnID)
-	if err != nil {
-		return errors.New("failed to insert horse into database: " + err.Error())
+type HorseStore interface {
+	InsertHorse(h *Horse) error
+	UpdateHorse(h *Horse) error
+	GetHorse(id int64) (*Horse, error)
+	GetHorsesByBarnID(barnID int64) ([]*Horse, error)
+	// GetHorsesByUserID returns the horses in every barn the user belongs to.
+	GetHorsesByUserID(userID int64) ([]*Horse, error)
+}
+
+func (h *Horse) validate() error {
+	switch h.Gender {
+	case "", Mare, Gelding, Stallion:
+	default:
+		return errors.New("invalid gender: " + string(h.Gender))
 	}
-	h.ID, err = result.LastInsertId()
-	if err != nil {
-		return errors.New("failed to get last insert ID: " + err.Error())
+	switch h.Temperament {
+	case "", Calm, Steady, Spirited:
+	default:
+		return errors.New("invalid temperament: " + string(h.Temperament))
+	}
+	if h.MinSkillLevel != "" && !h.MinSkillLevel.Valid() {
+		return errors.New("invalid skill level: " + string(h.MinSkillLevel))
+	}
+	if h.MaxRiderWeightPounds < 0 {
+		return errors.New("weight capacity cannot be negative")
+	}
+	for i, d := range h.Disciplines {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" || strings.Contains(d, ",") {
+			return errors.New("invalid discipline: " + h.Disciplines[i])
+		}
+		h.Disciplines[i] = d
 	}
 	return nil
 }
 
-type gender string
-
-const (
-	Mare     gender = "mare"
-	Gelding  gender = "gelding"
-	Stallion gender = "stallion"
-)
-
-func GetHorsesByBarnID(barnID int64, db *sql.DB) ([]*Horse, error) {
-	query := "select id, name, dob, gender from horses where barn_id = ?"
-	rows, err := db.Query(query, barnID)
+func (h *Horse) Save(store HorseStore) error {
+	err := h.validate()
 	if err != nil {
-		return nil, errors.New("failed to select horses from database: " + err.Error())
+		return err
 	}
-	defer rows.Close()
-	var horses []*Horse
-	for rows.Next() {
-		var h Horse
-		var dob time.Time
-		err := rows.Scan(&h.ID, &h.Name, &dob, &h.Gender)
-		if err != nil {
-			return nil, errors.New("failed to scan row: " + err.Error())
-		}
-		h.DOB = utils.Date{Time: dob}
-		h.BarnID = barnID
-		horses = append(horses, &h)
+	if h.ID > 0 {
+		return store.UpdateHorse(h)
 	}
-	return horses, nil
+	return store.InsertHorse(h)
 }
 
-func GetHorses(db *sql.DB) ([]*Horse, error) {
-	query := "select id, name, dob, gender, barn_id from horses"
-	rows, err := db.Query(query)
-	if err != nil {
-		return nil, errors.New("failed to select horses from database: " + err.Error())
-	}
-	defer rows.Close()
-	var horses []*Horse
-	for rows.Next() {
-		var h Horse
-		var dob time.Time
-		err := rows.Scan(&h.ID, &h.Name, &dob, &h.Gender, &h.BarnID)
-		if err != nil {
-			return nil, errors.New("failed to scan row: " + err.Error())
+// Does reports whether the horse is listed for the discipline.
+func (h *Horse) Does(discipline string) bool {
+	for _, d := range h.Disciplines {
+		if strings.EqualFold(d, discipline) {
+			return true
 		}
-		h.DOB = utils.Date{Time: dob}
-		horses = append(horses, &h)
 	}
-	return horses, nil
+	return false
+}
+
+type Gender string
+
+const (
+	Mare     Gender = "mare"
+	Gelding  Gender = "gelding"
+	Stallion Gender = "stallion"
+)
+
+type Temperament string
+
+const (
+	// Calm horses suit beginners.
+	Calm   Temperament = "calm"
+	Steady Temperament = "steady"
+	// Spirited horses want a rider who can sit them.
+	Spirited Temperament = "spirited"
+)
+
+func GetHorsesByBarnID(barnID int64, store HorseStore) ([]*Horse, error) {
+	return store.GetHorsesByBarnID(barnID)
+}
+
+func GetHorse(id int64, store HorseStore) (*Horse, error) {
+	return store.GetHorse(id)
+}
+
+func GetHorsesByUserID(userID int64, store HorseStore) ([]*Horse, error) {
+	return store.GetHorsesByUserID(userID)
 }
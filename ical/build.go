@@ -0,0 +1,153 @@
+package ical
+
+import (
+	"strconv"
+	"time"
+
+	"hack/recurrence"
+	"hack/rides"
+	"hack/utils"
+)
+
+const uidDomain = "@hack-equine"
+
+// Build lays out rides and schedules, read on the clock of loc, as a
+// calendar. A schedule becomes one recurring event. A ride standing in for
+// one of its occurrences overrides that occurrence, or removes it with
+// EXDATE when the ride is cancelled; replaced lists every ride linked to an
+// occurrence of the schedules, so occurrences moved out of the calendar are
+// removed too. Other cancelled rides are kept with STATUS:CANCELLED.
+func Build(name string, loc *time.Location, details []*rides.RideDetail, schedules []*rides.Schedule, replaced []*rides.Ride) *Calendar {
+	c := &Calendar{Name: name, Location: loc}
+	masters := make(map[int64]*Event)
+	for _, s := range schedules {
+		e := scheduleEvent(s, loc)
+		if e == nil {
+			continue
+		}
+		masters[s.ID] = e
+		c.Events = append(c.Events, e)
+	}
+
+	listed := make(map[int64]bool)
+	for _, r := range details {
+		listed[r.ID] = true
+		e := rideEvent(&r.Ride, summary(r.EventTypeName, r.RiderName, r.HorseName), loc)
+		master, ok := linkedMaster(&r.Ride, masters)
+		if !ok {
+			e.UID = "ride-" + strconv.FormatInt(r.ID, 10) + uidDomain
+			c.Events = append(c.Events, e)
+			continue
+		}
+		if r.Status == rides.Cancelled {
+			continue
+		}
+		recurrenceID := occurrenceStart(*r.OccurrenceDate, master.Start, loc)
+		e.UID = master.UID
+		e.RecurrenceID = &recurrenceID
+		c.Events = append(c.Events, e)
+	}
+	for _, r := range replaced {
+		master, ok := linkedMaster(r, masters)
+		if ok && (r.Status == rides.Cancelled || !listed[r.ID]) {
+			master.ExDates = append(master.ExDates, occurrenceStart(*r.OccurrenceDate, master.Start, loc))
+		}
+	}
+	return c
+}
+
+func linkedMaster(r *rides.Ride, masters map[int64]*Event) (*Event, bool) {
+	if r.ScheduleID == nil || r.OccurrenceDate == nil {
+		return nil, false
+	}
+	master, ok := masters[*r.ScheduleID]
+	return master, ok
+}
+
+func summary(eventType string, rider string, horse string) string {
+	s := rider + " on " + horse
+	if eventType != "" {
+		s = eventType + ": " + s
+	}
+	return s
+}
+
+func rideEvent(r *rides.Ride, summary string, loc *time.Location) *Event {
+	start, end := span(r.Date, r.Time, r.DurationMinutes, loc)
+	return &Event{
+		Summary:     summary,
+		Description: r.Notes,
+		Start:       start,
+		End:         end,
+		Cancelled:   r.Status == rides.Cancelled,
+	}
+}
+
+// scheduleEvent returns the recurring event for s, or nil if its rule never
+// puts it on a day.
+func scheduleEvent(s *rides.Schedule, loc *time.Location) *Event {
+	rule, err := s.Rule()
+	if err != nil {
+		return nil
+	}
+	first, ok := rule.First(s.StartDate.Time)
+	if !ok || (s.EndDate != nil && first.After(s.EndDate.Time)) {
+		return nil
+	}
+	duration := s.DurationMinutes
+	if duration == 0 {
+		duration = s.EventType.DurationMinutes
+	}
+	start, end := span(utils.Date{Time: first}, s.Time, duration, loc)
+
+	// the end date is the rule's last day, whether the rule has a COUNT, an
+	// UNTIL or the schedule was cut short by a split, so it becomes UNTIL
+	until := s.EndDate
+	if until == nil && rule.Until != nil {
+		until = &utils.Date{Time: *rule.Until}
+	}
+	rule.Count = 0
+	rule.Until = nil
+	rrule := rule.String()
+	if until != nil {
+		if start.DateOnly {
+			rrule += ";UNTIL=" + until.Format("20060102")
+		} else {
+			last := occurrenceStart(*until, start, loc)
+			rrule += ";UNTIL=" + last.Time.UTC().Format("20060102T150405Z")
+		}
+	}
+	return &Event{
+		UID:     "schedule-" + strconv.FormatInt(s.ID, 10) + uidDomain,
+		Summary: summary(s.EventType.Name, s.RiderName, s.HorseName),
+		Start:   start,
+		End:     end,
+		RRule:   rrule,
+	}
+}
+
+// span returns when a booking on date at t starts and ends. Bookings
+// without a time take the whole day.
+func span(date utils.Date, t *utils.Time, durationMinutes int, loc *time.Location) (DateTime, DateTime) {
+	day := recurrence.Day(date.Time)
+	if t == nil || !t.Valid {
+		return DateTime{Time: day, DateOnly: true}, DateTime{Time: day.AddDate(0, 0, 1), DateOnly: true}
+	}
+	if durationMinutes == 0 {
+		durationMinutes = rides.DefaultDurationMinutes
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), t.Time.Hour(), t.Time.Minute(), t.Time.Second(), 0, loc)
+	end := start.Add(time.Duration(durationMinutes) * time.Minute)
+	return DateTime{Time: start}, DateTime{Time: end}
+}
+
+// occurrenceStart is when the occurrence on date of a recurring event
+// starting at first begins, which is how RECURRENCE-ID and EXDATE name it.
+func occurrenceStart(date utils.Date, first DateTime, loc *time.Location) DateTime {
+	day := recurrence.Day(date.Time)
+	if first.DateOnly {
+		return DateTime{Time: day, DateOnly: true}
+	}
+	t := first.Time.In(loc)
+	return DateTime{Time: time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)}
+}
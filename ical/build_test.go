@@ -0,0 +1,130 @@
+package ical
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"hack/recurrence"
+	"hack/rides"
+	"hack/utils"
+)
+
+func day(s string) utils.Date {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return utils.Date{Time: t}
+}
+
+// occurrences returns the days e occurs on from from to to, leaving out its
+// EXDATEs, as a calendar reading it would.
+func occurrences(t *testing.T, e *Event, from string, to string) []string {
+	t.Helper()
+	rule, err := recurrence.Parse(e.RRule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	excluded := make(map[string]bool)
+	for _, exDate := range e.ExDates {
+		excluded[exDate.Time.Format("2006-01-02")] = true
+	}
+	var days []string
+	for _, d := range rule.Between(e.Start.Time, day(from).Time, day(to).Time) {
+		if !excluded[d.Format("2006-01-02")] {
+			days = append(days, d.Format("2006-01-02"))
+		}
+	}
+	return days
+}
+
+func TestExDates(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	five := &utils.Time{}
+	five.Valid = true
+	five.Time = time.Date(0, 1, 1, 17, 0, 0, 0, time.UTC)
+	scheduleID := int64(1)
+	cancelled, moved := day("2026-01-13"), day("2026-01-20")
+	schedule := &rides.Schedule{ID: scheduleID, RRule: "FREQ=WEEKLY;BYDAY=TU", StartDate: day("2026-01-06"), Time: five, DurationMinutes: 60}
+	replaced := []*rides.Ride{
+		{ID: 1, ScheduleID: &scheduleID, OccurrenceDate: &cancelled, Date: cancelled, Time: five, Status: rides.Cancelled},
+		{ID: 2, ScheduleID: &scheduleID, OccurrenceDate: &moved, Date: day("2026-03-01"), Time: five, Status: rides.Scheduled},
+	}
+
+	c := Build("Barn", loc, nil, []*rides.Schedule{schedule}, replaced)
+	if len(c.Events) != 1 {
+		t.Fatalf("got %d events, want the schedule alone", len(c.Events))
+	}
+	want := []string{"2026-01-06", "2026-01-27", "2026-02-03"}
+	if got := occurrences(t, c.Events[0], "2026-01-01", "2026-02-05"); !equal(got, want) {
+		t.Fatalf("built calendar occurs on %v, want %v", got, want)
+	}
+
+	parsed, err := Parse(bytes.NewReader(c.Bytes()), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := parsed.Events[0]
+	if len(e.ExDates) != 2 {
+		t.Fatalf("parsed %d EXDATEs, want 2", len(e.ExDates))
+	}
+	for i, exDate := range e.ExDates {
+		if !exDate.Time.Equal(c.Events[0].ExDates[i].Time) {
+			t.Fatalf("EXDATE %d parsed as %s, want %s", i, exDate.Time, c.Events[0].ExDates[i].Time)
+		}
+	}
+	if got := occurrences(t, e, "2026-01-01", "2026-02-05"); !equal(got, want) {
+		t.Fatalf("parsed calendar occurs on %v, want %v", got, want)
+	}
+}
+
+func TestParseExDates(t *testing.T) {
+	tests := []struct {
+		name     string
+		property string
+		want     []string
+		dateOnly bool
+	}{
+		{"list", "EXDATE;TZID=America/New_York:20260113T170000,20260120T170000", []string{"2026-01-13T22:00:00Z", "2026-01-20T22:00:00Z"}, false},
+		{"repeated", "EXDATE;TZID=America/New_York:20260113T170000\r\nEXDATE;TZID=America/New_York:20260120T170000", []string{"2026-01-13T22:00:00Z", "2026-01-20T22:00:00Z"}, false},
+		{"utc", "EXDATE:20260113T220000Z", []string{"2026-01-13T22:00:00Z"}, false},
+		{"dates", "EXDATE;VALUE=DATE:20260113,20260120", []string{"2026-01-13T00:00:00Z", "2026-01-20T00:00:00Z"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\n" +
+				"DTSTART;TZID=America/New_York:20260106T170000\r\nDTEND;TZID=America/New_York:20260106T180000\r\n" +
+				"RRULE:FREQ=WEEKLY;BYDAY=TU\r\n" + tt.property + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+			c, err := Parse(bytes.NewReader([]byte(text)), time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, exDate := range c.Events[0].ExDates {
+				if exDate.DateOnly != tt.dateOnly {
+					t.Fatalf("EXDATE %s has DateOnly %v", exDate.Time, exDate.DateOnly)
+				}
+				got = append(got, exDate.Time.UTC().Format(time.RFC3339))
+			}
+			if !equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
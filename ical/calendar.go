@@ -0,0 +1,143 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+const prodID = "-//hack-equine//rides//EN"
+
+// Calendar is an RFC 5545 calendar whose events are all in one time zone.
+type Calendar struct {
+	Name     string
+	Location *time.Location
+	Events   []*Event
+}
+
+// Event is a VEVENT. An event with RRule set recurs; another event with the
+// same UID and a RecurrenceID replaces one of its occurrences.
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Start        DateTime
+	End          DateTime
+	RRule        string
+	ExDates      []DateTime
+	RecurrenceID *DateTime
+	Cancelled    bool
+}
+
+// DateTime is a time of day in the calendar's zone, or a whole day when
+// DateOnly is set.
+type DateTime struct {
+	Time     time.Time
+	DateOnly bool
+}
+
+func (d DateTime) property(name string, loc *time.Location) string {
+	if d.DateOnly {
+		return name + ";VALUE=DATE:" + d.Time.Format("20060102")
+	}
+	return name + ";TZID=" + loc.String() + ":" + d.Time.In(loc).Format("20060102T150405")
+}
+
+// Bytes renders the calendar as text/calendar content.
+func (c *Calendar) Bytes() []byte {
+	w := &writer{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + prodID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.line("X-WR-CALNAME:" + escape(c.Name))
+	w.line("X-WR-TIMEZONE:" + c.Location.String())
+	from, to, ok := c.span()
+	if ok {
+		writeTimeZone(w, c.Location, from, to)
+	}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, e := range c.Events {
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + e.UID)
+		w.line("DTSTAMP:" + stamp)
+		w.line(e.Start.property("DTSTART", c.Location))
+		w.line(e.End.property("DTEND", c.Location))
+		if e.RecurrenceID != nil {
+			w.line(e.RecurrenceID.property("RECURRENCE-ID", c.Location))
+		}
+		if e.RRule != "" {
+			w.line("RRULE:" + e.RRule)
+		}
+		for _, exDate := range e.ExDates {
+			w.line(exDate.property("EXDATE", c.Location))
+		}
+		w.line("SUMMARY:" + escape(e.Summary))
+		if e.Description != "" {
+			w.line("DESCRIPTION:" + escape(e.Description))
+		}
+		if e.Cancelled {
+			w.line("STATUS:CANCELLED")
+		} else {
+			w.line("STATUS:CONFIRMED")
+		}
+		w.line("END:VEVENT")
+	}
+	w.line("END:VCALENDAR")
+	return w.Bytes()
+}
+
+// span returns the earliest and latest times the calendar's timed events
+// need the zone defined for, reaching a year past the last start for
+// recurring events.
+func (c *Calendar) span() (time.Time, time.Time, bool) {
+	var from, to time.Time
+	found := false
+	for _, e := range c.Events {
+		if e.Start.DateOnly {
+			continue
+		}
+		end := e.End.Time
+		if e.RRule != "" {
+			end = end.AddDate(1, 0, 0)
+		}
+		if !found || e.Start.Time.Before(from) {
+			from = e.Start.Time
+		}
+		if !found || end.After(to) {
+			to = end
+		}
+		found = true
+	}
+	return from, to, found
+}
+
+type writer struct {
+	bytes.Buffer
+}
+
+// line writes a content line, folding it at 75 octets without splitting a
+// UTF-8 sequence.
+func (w *writer) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// the leading space of a continuation line counts against it
+		limit = 74
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,259 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse reads the events of an iCalendar file. Times keep the zone their TZID
+// names; floating times, and TZIDs that are not IANA names, are read in the
+// calendar's X-WR-TIMEZONE or else in loc.
+func Parse(r io.Reader, loc *time.Location) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	c := &Calendar{Location: loc}
+	var e *Event
+	var hasEnd bool
+	var duration time.Duration
+	// components nested in an event, like VALARM, are skipped
+	nested := 0
+	for i, line := range lines {
+		name, params, value := splitLine(line)
+		fail := func(err error) (*Calendar, error) {
+			return nil, errors.New("line " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		switch {
+		case name == "BEGIN" && value == "VEVENT" && e == nil:
+			e = &Event{}
+			hasEnd, duration = false, 0
+			continue
+		case name == "END" && value == "VEVENT" && e != nil && nested == 0:
+			if e.Start.Time.IsZero() {
+				return fail(errors.New("event " + e.UID + " has no DTSTART"))
+			}
+			if !hasEnd {
+				e.End = e.Start
+				if duration > 0 {
+					e.End.Time = e.Start.Time.Add(duration)
+				} else if e.Start.DateOnly {
+					e.End.Time = e.Start.Time.AddDate(0, 0, 1)
+				}
+			}
+			c.Events = append(c.Events, e)
+			e = nil
+			continue
+		case e != nil && name == "BEGIN":
+			nested++
+			continue
+		case e != nil && name == "END":
+			nested--
+			continue
+		}
+		if e == nil {
+			switch name {
+			case "X-WR-CALNAME":
+				c.Name = unescape(value)
+			case "X-WR-TIMEZONE":
+				if zone, err := time.LoadLocation(value); err == nil {
+					c.Location = zone
+				}
+			}
+			continue
+		}
+		if nested > 0 {
+			continue
+		}
+		switch name {
+		case "UID":
+			e.UID = value
+		case "SUMMARY":
+			e.Summary = unescape(value)
+		case "DESCRIPTION":
+			e.Description = unescape(value)
+		case "STATUS":
+			e.Cancelled = strings.EqualFold(value, "CANCELLED")
+		case "RRULE":
+			e.RRule = value
+		case "DTSTART":
+			e.Start, err = parseDateTime(params, value, c.Location)
+		case "DTEND":
+			e.End, err = parseDateTime(params, value, c.Location)
+			hasEnd = true
+		case "DURATION":
+			duration, err = parseDuration(value)
+		case "RECURRENCE-ID":
+			var id DateTime
+			id, err = parseDateTime(params, value, c.Location)
+			e.RecurrenceID = &id
+		case "EXDATE":
+			for _, item := range strings.Split(value, ",") {
+				var exDate DateTime
+				exDate, err = parseDateTime(params, item, c.Location)
+				if err != nil {
+					break
+				}
+				e.ExDates = append(e.ExDates, exDate)
+			}
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if e != nil {
+		return nil, errors.New("calendar ends inside an event")
+	}
+	return c, nil
+}
+
+// unfold joins continuation lines, which start with a space or a tab, onto
+// the line before them.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("failed to read calendar: " + err.Error())
+	}
+	return lines, nil
+}
+
+// splitLine splits a content line into its upper-cased name, its parameters
+// and its value. A colon inside a quoted parameter value does not end the
+// parameters.
+func splitLine(line string) (string, map[string]string, string) {
+	quoted := false
+	end := -1
+	for i, ch := range line {
+		if ch == '"' {
+			quoted = !quoted
+		}
+		if ch == ':' && !quoted {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+	parts := strings.Split(line[:end], ";")
+	params := make(map[string]string)
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[end+1:]
+}
+
+func parseDateTime(params map[string]string, value string, loc *time.Location) (DateTime, error) {
+	value = strings.TrimSpace(value)
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		date, err := time.Parse("20060102", value)
+		if err != nil {
+			return DateTime{}, errors.New("invalid date: " + value)
+		}
+		return DateTime{Time: date, DateOnly: true}, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return DateTime{}, errors.New("invalid date-time: " + value)
+		}
+		return DateTime{Time: t}, nil
+	}
+	if tzid, ok := params["TZID"]; ok {
+		if zone, err := time.LoadLocation(tzid); err == nil {
+			loc = zone
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return DateTime{}, errors.New("invalid date-time: " + value)
+	}
+	return DateTime{Time: t}, nil
+}
+
+// parseDuration reads the DURATION forms calendars use, like PT1H30M or P1D.
+func parseDuration(value string) (time.Duration, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(value), "+")
+	if !strings.HasPrefix(s, "P") {
+		return 0, errors.New("invalid duration: " + value)
+	}
+	s = s[1:]
+	var d time.Duration
+	inTime := false
+	number := ""
+	for _, ch := range s {
+		if ch >= '0' && ch <= '9' {
+			number += string(ch)
+			continue
+		}
+		if ch == 'T' {
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, errors.New("invalid duration: " + value)
+		}
+		number = ""
+		switch {
+		case ch == 'W':
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case ch == 'D':
+			d += time.Duration(n) * 24 * time.Hour
+		case ch == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case ch == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case ch == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, errors.New("invalid duration: " + value)
+		}
+	}
+	if number != "" {
+		return 0, errors.New("invalid duration: " + value)
+	}
+	return d, nil
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, ch := range s {
+		if !escaped {
+			if ch == '\\' {
+				escaped = true
+			} else {
+				b.WriteRune(ch)
+			}
+			continue
+		}
+		escaped = false
+		switch ch {
+		case 'n', 'N':
+			b.WriteRune('\n')
+		default:
+			b.WriteRune(ch)
+		}
+	}
+	return b.String()
+}
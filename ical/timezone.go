@@ -0,0 +1,74 @@
+package ical
+
+import (
+	"strconv"
+	"time"
+)
+
+// writeTimeZone writes a VTIMEZONE for loc with one observance per offset
+// change between from and to, found by looking at the zone day by day.
+// Clients that know the IANA name ignore it, but the RFC requires one for
+// every TZID used.
+func writeTimeZone(w *writer, loc *time.Location, from time.Time, to time.Time) {
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + loc.String())
+	start := from.Add(-24 * time.Hour).In(loc)
+	_, offset := start.Zone()
+	writeObservance(w, start, offset)
+	for day := start.Unix(); day < to.Unix(); day += 24 * 60 * 60 {
+		next := day + 24*60*60
+		if _, nextOffset := time.Unix(next, 0).In(loc).Zone(); nextOffset == offset {
+			continue
+		}
+		// the offset changes somewhere in this day; find the second it does
+		lo, hi := day, next
+		for hi-lo > 1 {
+			mid := lo + (hi-lo)/2
+			if _, midOffset := time.Unix(mid, 0).In(loc).Zone(); midOffset == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		change := time.Unix(hi, 0).In(loc)
+		writeObservance(w, change, offset)
+		_, offset = change.Zone()
+	}
+	w.line("END:VTIMEZONE")
+}
+
+// writeObservance writes the observance that starts at t, which the RFC
+// gives as the local time under the offset in force before it.
+func writeObservance(w *writer, t time.Time, offsetFrom int) {
+	name, offsetTo := t.Zone()
+	kind := "STANDARD"
+	if t.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	w.line("BEGIN:" + kind)
+	w.line("DTSTART:" + t.In(time.FixedZone("", offsetFrom)).Format("20060102T150405"))
+	w.line("TZOFFSETFROM:" + formatOffset(offsetFrom))
+	w.line("TZOFFSETTO:" + formatOffset(offsetTo))
+	w.line("TZNAME:" + name)
+	w.line("END:" + kind)
+}
+
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	s := sign + twoDigits(seconds/3600) + twoDigits(seconds/60%60)
+	if seconds%60 != 0 {
+		s += twoDigits(seconds % 60)
+	}
+	return s
+}
+
+func twoDigits(n int) string {
+	if n < 10 {
+		return "0" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
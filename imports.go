@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+
+	"hack/barns"
+	"hack/ical"
+	"hack/imports"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerImportRoutes(app *fiber.App, s stores) {
+	// takes a multipart form with the .ics file as "calendar" and an
+	// imports.Config as JSON in "config". Nothing is saved without
+	// ?commit=true, so the report can be checked first.
+	app.Post("/barn/:barnID/import", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		member, err := membership(c, s, barnID, barns.ManageSchedules)
+		if err != nil {
+			return err
+		}
+		if !member.Role.Can(barns.ManageRides) {
+			return fiber.NewError(fiber.StatusForbidden, "role "+string(member.Role)+" does not allow "+string(barns.ManageRides))
+		}
+		var config imports.Config
+		if raw := c.FormValue("config"); raw != "" {
+			err = json.Unmarshal([]byte(raw), &config)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "Failed to parse config: "+err.Error())
+			}
+		}
+		header, err := c.FormFile("calendar")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Failed to read calendar file: "+err.Error())
+		}
+		file, err := header.Open()
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Failed to read calendar file: "+err.Error())
+		}
+		defer file.Close()
+		barn, err := barns.GetBarn(barnID, s.barns)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get barn", err)
+		}
+		loc := utils.Location(barn.TimeZone)
+		calendar, err := ical.Parse(file, loc)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to parse calendar", err)
+		}
+		dryRun := c.Query("commit") != "true"
+		report, err := imports.Import(barnID, calendar, loc, config, dryRun, override(c), currentUser(c).ID, s.imports)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to import calendar", err)
+		}
+		return c.JSON(fiber.Map{
+			"report": report,
+		})
+	})
+}
@@ -0,0 +1,614 @@
+package imports
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"hack/horses"
+	"hack/ical"
+	"hack/recurrence"
+	"hack/riders"
+	"hack/rides"
+	"hack/utils"
+)
+
+// Store is everything an import needs from persistence.
+type Store interface {
+	rides.Store
+	GetHorsesByBarnID(barnID int64) ([]*horses.Horse, error)
+	GetRidersByBarnID(barnID int64) ([]*riders.Rider, error)
+}
+
+type Outcome string
+
+const (
+	// Ready is what a dry run reports for events that would be imported.
+	Ready       Outcome = "ready"
+	Imported    Outcome = "imported"
+	Unmatched   Outcome = "unmatched"
+	Conflicting Outcome = "conflict"
+	Unsupported Outcome = "unsupported"
+	Skipped     Outcome = "skipped"
+)
+
+type Kind string
+
+const (
+	RideItem      Kind = "ride"
+	ScheduleItem  Kind = "schedule"
+	ExceptionItem Kind = "exception"
+)
+
+// Item is what became of one event, or of one exception to a recurring
+// event.
+type Item struct {
+	UID       string            `json:"uid,omitempty"`
+	Summary   string            `json:"summary"`
+	Date      utils.Date        `json:"date"`
+	Kind      Kind              `json:"kind"`
+	Outcome   Outcome           `json:"outcome"`
+	Error     string            `json:"error,omitempty"`
+	Unmatched []string          `json:"unmatched,omitempty"`
+	Conflicts []*rides.Conflict `json:"conflicts,omitempty"`
+	// Violations are the workload limits of a horse that refused the event.
+	Violations []*rides.Violation `json:"violations,omitempty"`
+	RideID     int64              `json:"ride_id,omitempty"`
+	ScheduleID int64              `json:"schedule_id,omitempty"`
+}
+
+type Report struct {
+	DryRun bool `json:"dry_run"`
+	// Rides, Schedules and Exceptions count what was imported, or in a dry
+	// run what would be.
+	Rides      int `json:"rides"`
+	Schedules  int `json:"schedules"`
+	Exceptions int `json:"exceptions"`
+	Skipped    int `json:"skipped"`
+	// UnmatchedNames are the names, or without a pattern the summaries,
+	// that did not match anything in the barn.
+	UnmatchedNames []string `json:"unmatched_names"`
+	Items          []*Item  `json:"items"`
+}
+
+// Import maps the events of cal onto the horses, riders and event types of a
+// barn whose clock is loc. Recurring events become schedules, their EXDATEs
+// cancelled occurrences and their overrides moved ones; other events become
+// rides. A dry run only reports what would happen, checking each event
+// against what is already booked and the events before it in the file that
+// would be imported. Events that would double-book are skipped unless
+// override is set. Cancellations are recorded as made by actorID.
+func Import(barnID int64, cal *ical.Calendar, loc *time.Location, config Config, dryRun bool, override bool, actorID int64, store Store) (*Report, error) {
+	m, err := newMatcher(barnID, config, store)
+	if err != nil {
+		return nil, err
+	}
+	im := &importer{
+		report:   &Report{DryRun: dryRun, UnmatchedNames: []string{}, Items: []*Item{}},
+		loc:      loc,
+		dryRun:   dryRun,
+		override: override,
+		actorID:  actorID,
+		store:    store,
+		matcher:  m,
+		names:    make(map[string]bool),
+		pending:  &rides.Pending{},
+	}
+
+	var masters, singles []*ical.Event
+	hasMaster := make(map[string]bool)
+	for _, e := range cal.Events {
+		if e.RRule != "" && e.RecurrenceID == nil {
+			masters = append(masters, e)
+			hasMaster[e.UID] = true
+		}
+	}
+	overrides := make(map[string][]*ical.Event)
+	for _, e := range cal.Events {
+		switch {
+		case e.RRule != "" && e.RecurrenceID == nil:
+		case e.RecurrenceID != nil && hasMaster[e.UID]:
+			overrides[e.UID] = append(overrides[e.UID], e)
+		default:
+			singles = append(singles, e)
+		}
+	}
+
+	for _, e := range masters {
+		im.importSchedule(e, overrides[e.UID])
+	}
+	for _, e := range singles {
+		im.importRide(e)
+	}
+	for name := range im.names {
+		im.report.UnmatchedNames = append(im.report.UnmatchedNames, name)
+	}
+	sort.Strings(im.report.UnmatchedNames)
+	return im.report, nil
+}
+
+type importer struct {
+	report   *Report
+	loc      *time.Location
+	dryRun   bool
+	override bool
+	actorID  int64
+	store    Store
+	matcher  *matcher
+	names    map[string]bool
+	// pending is what a dry run would have imported so far.
+	pending *rides.Pending
+}
+
+func (im *importer) add(item *Item) {
+	switch item.Outcome {
+	case Ready, Imported:
+		switch item.Kind {
+		case RideItem:
+			im.report.Rides++
+		case ScheduleItem:
+			im.report.Schedules++
+		case ExceptionItem:
+			im.report.Exceptions++
+		}
+	default:
+		im.report.Skipped++
+	}
+	im.report.Items = append(im.report.Items, item)
+}
+
+func (im *importer) newItem(e *ical.Event, kind Kind) *Item {
+	date, _ := local(e.Start, im.loc)
+	return &Item{UID: e.UID, Summary: e.Summary, Date: date, Kind: kind}
+}
+
+// match fills in the item when the event's names do not all match, and
+// reports whether they did.
+func (im *importer) match(e *ical.Event, item *Item) (matched, bool) {
+	ids, unmatched, err := im.matcher.match(e)
+	if err != nil {
+		item.Outcome = Unmatched
+		item.Error = err.Error()
+		return ids, false
+	}
+	if len(unmatched) > 0 {
+		item.Outcome = Unmatched
+		for _, u := range unmatched {
+			item.Unmatched = append(item.Unmatched, u.kind)
+			im.names[u.name] = true
+		}
+		return ids, false
+	}
+	return ids, true
+}
+
+// finish records how checking or saving went.
+func (im *importer) finish(item *Item, conflicts []*rides.Conflict, err error) bool {
+	if conflictErr, ok := err.(*rides.ConflictError); ok {
+		conflicts, err = conflictErr.Conflicts, nil
+	}
+	if workloadErr, ok := err.(*rides.WorkloadError); ok {
+		item.Outcome = Conflicting
+		item.Violations = workloadErr.Violations
+		im.add(item)
+		return false
+	}
+	switch {
+	case err != nil:
+		item.Outcome = Unsupported
+		item.Error = err.Error()
+	case len(conflicts) > 0 && !im.override:
+		item.Outcome = Conflicting
+		item.Conflicts = conflicts
+	case im.dryRun:
+		item.Outcome = Ready
+		item.Conflicts = conflicts
+	default:
+		item.Outcome = Imported
+	}
+	im.add(item)
+	return item.Outcome == Ready || item.Outcome == Imported
+}
+
+func (im *importer) importRide(e *ical.Event) {
+	item := im.newItem(e, RideItem)
+	ids, ok := im.match(e, item)
+	if !ok {
+		im.add(item)
+		return
+	}
+	date, t := local(e.Start, im.loc)
+	r := &rides.Ride{
+		HorseID:         ids.horseID,
+		RiderID:         ids.riderID,
+		EventTypeID:     ids.eventTypeID,
+		Date:            date,
+		Time:            t,
+		Notes:           e.Description,
+		DurationMinutes: minutes(e),
+	}
+	if e.Cancelled {
+		r.Status = rides.Cancelled
+	}
+	if im.dryRun {
+		im.previewRide(item, e.UID, r)
+		return
+	}
+	err := r.Save(im.store, im.override)
+	item.RideID = r.ID
+	im.finish(item, nil, err)
+}
+
+// previewRide checks the ride of a dry run against what is booked and what
+// would be imported before it, and adds it to the latter if it would be
+// imported too.
+func (im *importer) previewRide(item *Item, source string, r *rides.Ride) {
+	conflicts, err := r.Check(im.store)
+	if err == nil {
+		var pending []*rides.Conflict
+		pending, err = im.pending.RideConflicts(r, im.store)
+		conflicts = append(conflicts, pending...)
+	}
+	if !im.finish(item, conflicts, err) {
+		return
+	}
+	err = im.pending.AddRide(source, r, im.store)
+	if err != nil {
+		item.Error = err.Error()
+	}
+}
+
+func (im *importer) importSchedule(e *ical.Event, overrides []*ical.Event) {
+	item := im.newItem(e, ScheduleItem)
+	skip := func(outcome Outcome, reason string) {
+		item.Outcome = outcome
+		item.Error = reason
+		im.add(item)
+		im.skipExceptions(e, overrides, "recurring event was not imported")
+	}
+	if e.Cancelled {
+		skip(Skipped, "event is cancelled")
+		return
+	}
+	ids, ok := im.match(e, item)
+	if !ok {
+		im.add(item)
+		im.skipExceptions(e, overrides, "recurring event was not imported")
+		return
+	}
+	rrule, err := localRule(e.RRule, e.Start, im.loc)
+	if err != nil {
+		skip(Unsupported, err.Error())
+		return
+	}
+	date, t := local(e.Start, im.loc)
+	s := &rides.Schedule{
+		HorseID:         ids.horseID,
+		RiderID:         ids.riderID,
+		EventType:       rides.EventType{ID: ids.eventTypeID},
+		StartDate:       date,
+		Time:            t,
+		DurationMinutes: minutes(e),
+		RRule:           rrule,
+	}
+	var saved bool
+	if im.dryRun {
+		conflicts, err := s.Check(im.store)
+		if err == nil {
+			var pending []*rides.Conflict
+			pending, err = im.pending.ScheduleConflicts(s, im.store)
+			conflicts = append(conflicts, pending...)
+		}
+		saved = im.finish(item, conflicts, err)
+		if saved {
+			err = im.pending.AddSchedule(e.UID, s, im.store)
+			if err != nil {
+				item.Error = err.Error()
+			}
+		}
+	} else {
+		err = s.Save(im.store, im.override)
+		item.ScheduleID = s.ID
+		saved = im.finish(item, nil, err)
+	}
+	if !saved {
+		im.skipExceptions(e, overrides, "recurring event was not imported")
+		return
+	}
+
+	for _, exDate := range e.ExDates {
+		occurrence, _ := local(exDate, im.loc)
+		im.cancelOccurrence(s, e, occurrence)
+	}
+	for _, o := range overrides {
+		occurrence, _ := local(*o.RecurrenceID, im.loc)
+		if o.Cancelled {
+			im.cancelOccurrence(s, o, occurrence)
+			continue
+		}
+		im.moveOccurrence(s, o, occurrence, ids)
+	}
+}
+
+func (im *importer) cancelOccurrence(s *rides.Schedule, e *ical.Event, occurrence utils.Date) {
+	item := &Item{UID: e.UID, Summary: e.Summary, Date: occurrence, Kind: ExceptionItem, ScheduleID: s.ID}
+	if !s.OccursOn(occurrence) {
+		im.finish(item, nil, rides.ErrNoOccurrence)
+		return
+	}
+	if im.dryRun {
+		im.pending.Remove(e.UID, occurrence)
+		im.finish(item, nil, nil)
+		return
+	}
+	r, err := rides.CancelOccurrence(s, occurrence, im.actorID, im.store)
+	if r != nil {
+		item.RideID = r.ID
+	}
+	im.finish(item, nil, err)
+}
+
+// moveOccurrence applies an override, which keeps the recurring event's
+// horse and rider unless it names others.
+func (im *importer) moveOccurrence(s *rides.Schedule, e *ical.Event, occurrence utils.Date, master matched) {
+	item := im.newItem(e, ExceptionItem)
+	item.ScheduleID = s.ID
+	ids, _, err := im.matcher.match(e)
+	if err != nil || ids.horseID == 0 || ids.riderID == 0 {
+		ids = master
+	}
+	if !s.OccursOn(occurrence) {
+		im.finish(item, nil, rides.ErrNoOccurrence)
+		return
+	}
+	date, t := local(e.Start, im.loc)
+	if im.dryRun {
+		r := &rides.Ride{
+			HorseID:         ids.horseID,
+			RiderID:         ids.riderID,
+			EventTypeID:     s.EventType.ID,
+			Date:            date,
+			Time:            t,
+			Notes:           e.Description,
+			DurationMinutes: minutes(e),
+		}
+		im.pending.Remove(e.UID, occurrence)
+		im.previewRide(item, e.UID, r)
+		return
+	}
+	change := rides.OccurrenceChange{
+		Date:            &date,
+		Time:            t,
+		DurationMinutes: minutes(e),
+		HorseID:         ids.horseID,
+		Notes:           &e.Description,
+	}
+	if t == nil {
+		// an all-day override drops the time
+		change.Time = &utils.Time{}
+	}
+	r, err := rides.MoveOccurrence(s, occurrence, change, im.override, im.actorID, im.store)
+	if r != nil {
+		item.RideID = r.ID
+	}
+	im.finish(item, nil, err)
+}
+
+func (im *importer) skipExceptions(e *ical.Event, overrides []*ical.Event, reason string) {
+	for _, exDate := range e.ExDates {
+		occurrence, _ := local(exDate, im.loc)
+		im.add(&Item{UID: e.UID, Summary: e.Summary, Date: occurrence, Kind: ExceptionItem, Outcome: Skipped, Error: reason})
+	}
+	for _, o := range overrides {
+		item := im.newItem(o, ExceptionItem)
+		item.Outcome = Skipped
+		item.Error = reason
+		im.add(item)
+	}
+}
+
+// local reads a time on the barn's clock. Whole-day events have no time.
+func local(d ical.DateTime, loc *time.Location) (utils.Date, *utils.Time) {
+	if d.DateOnly {
+		return utils.Date{Time: recurrence.Day(d.Time)}, nil
+	}
+	t := d.Time.In(loc)
+	clock := &utils.Time{}
+	clock.Valid = true
+	clock.Time = time.Date(0, 1, 1, t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return utils.Date{Time: recurrence.Day(t)}, clock
+}
+
+// minutes is how long a timed event lasts, or zero for the event type's
+// duration.
+func minutes(e *ical.Event) int {
+	if e.Start.DateOnly {
+		return 0
+	}
+	m := int(e.End.Time.Sub(e.Start.Time) / time.Minute)
+	if m < 0 {
+		return 0
+	}
+	return m
+}
+
+// localRule rewrites an UNTIL given as a date-time as the date it falls on
+// on the barn's clock, since schedules recur by date.
+func localRule(rrule string, start ical.DateTime, loc *time.Location) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(rrule, "RRULE:"), ";")
+	for i, part := range parts {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || strings.ToUpper(kv[0]) != "UNTIL" || len(kv[1]) <= len("20060102") {
+			continue
+		}
+		var until time.Time
+		var err error
+		if strings.HasSuffix(kv[1], "Z") {
+			until, err = time.Parse("20060102T150405Z", kv[1])
+		} else {
+			until, err = time.ParseInLocation("20060102T150405", kv[1], start.Time.Location())
+		}
+		if err != nil {
+			return "", errors.New("invalid UNTIL: " + kv[1])
+		}
+		parts[i] = "UNTIL=" + until.In(loc).Format("20060102")
+	}
+	rule := strings.Join(parts, ";")
+	_, err := recurrence.Parse(rule)
+	if err != nil {
+		return "", errors.New("unsupported recurrence rule: " + err.Error())
+	}
+	return rule, nil
+}
+
+type matched struct {
+	horseID     int64
+	riderID     int64
+	eventTypeID int64
+}
+
+type unmatchedName struct {
+	kind string
+	name string
+}
+
+type matcher struct {
+	config     Config
+	pattern    *regexp.Regexp
+	horses     *names
+	riders     *names
+	eventTypes *names
+}
+
+func newMatcher(barnID int64, config Config, store Store) (*matcher, error) {
+	pattern, err := compilePattern(config.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	barnHorses, err := store.GetHorsesByBarnID(barnID)
+	if err != nil {
+		return nil, err
+	}
+	horseIDs := make(map[string]int64)
+	for _, h := range barnHorses {
+		horseIDs[h.Name] = h.ID
+	}
+	barnRiders, err := store.GetRidersByBarnID(barnID)
+	if err != nil {
+		return nil, err
+	}
+	riderIDs := make(map[string]int64)
+	for _, r := range barnRiders {
+		riderIDs[r.Name] = r.ID
+	}
+	eventTypes, err := store.ListEventTypes()
+	if err != nil {
+		return nil, err
+	}
+	eventTypeIDs := make(map[string]int64)
+	for _, t := range eventTypes {
+		eventTypeIDs[t.Name] = t.ID
+	}
+
+	if config.DefaultHorseID != 0 && !hasID(horseIDs, config.DefaultHorseID) {
+		return nil, errors.New("default horse " + strconv.FormatInt(config.DefaultHorseID, 10) + " is not in this barn")
+	}
+	if config.DefaultRiderID != 0 && !hasID(riderIDs, config.DefaultRiderID) {
+		return nil, errors.New("default rider " + strconv.FormatInt(config.DefaultRiderID, 10) + " is not in this barn")
+	}
+	m := &matcher{config: config, pattern: pattern}
+	m.horses, err = newNames("horse", horseIDs, config.Horses)
+	if err != nil {
+		return nil, err
+	}
+	m.riders, err = newNames("rider", riderIDs, config.Riders)
+	if err != nil {
+		return nil, err
+	}
+	m.eventTypes, err = newNames("event type", eventTypeIDs, config.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func hasID(ids map[string]int64, id int64) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// match finds the horse, rider and event type an event is for, returning
+// what it read but could not find.
+func (m *matcher) match(e *ical.Event) (matched, []unmatchedName, error) {
+	ids := matched{
+		horseID:     m.config.DefaultHorseID,
+		riderID:     m.config.DefaultRiderID,
+		eventTypeID: m.config.DefaultEventTypeID,
+	}
+	var unmatched []unmatchedName
+	if m.pattern != nil {
+		groups := m.pattern.FindStringSubmatch(e.Summary)
+		if groups == nil {
+			return ids, []unmatchedName{{kind: "pattern", name: e.Summary}}, nil
+		}
+		for i, group := range m.pattern.SubexpNames() {
+			var n *names
+			var id *int64
+			switch group {
+			case "horse":
+				n, id = m.horses, &ids.horseID
+			case "rider":
+				n, id = m.riders, &ids.riderID
+			case "type":
+				n, id = m.eventTypes, &ids.eventTypeID
+			default:
+				continue
+			}
+			found, ok := n.lookup(groups[i])
+			if !ok {
+				unmatched = append(unmatched, unmatchedName{kind: n.kind + " " + strconv.Quote(strings.TrimSpace(groups[i])), name: strings.TrimSpace(groups[i])})
+				continue
+			}
+			*id = found
+		}
+	} else {
+		for _, search := range []struct {
+			n  *names
+			id *int64
+		}{{m.horses, &ids.horseID}, {m.riders, &ids.riderID}, {m.eventTypes, &ids.eventTypeID}} {
+			found, err := search.n.search(e.Summary)
+			if err == nil && found == 0 {
+				found, err = search.n.search(e.Description)
+			}
+			if err != nil {
+				return ids, nil, err
+			}
+			if found != 0 {
+				*search.id = found
+			}
+		}
+	}
+	if ids.horseID == 0 && !hasKind(unmatched, "horse") {
+		unmatched = append(unmatched, unmatchedName{kind: "horse", name: e.Summary})
+	}
+	if ids.riderID == 0 && !hasKind(unmatched, "rider") {
+		unmatched = append(unmatched, unmatchedName{kind: "rider", name: e.Summary})
+	}
+	return ids, unmatched, nil
+}
+
+func hasKind(unmatched []unmatchedName, kind string) bool {
+	for _, u := range unmatched {
+		if strings.HasPrefix(u.kind, kind+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package imports
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Config says how event summaries name horses, riders and event types.
+type Config struct {
+	// Pattern, when set, reads the names out of the summary, as in
+	// "{type}: {rider} on {horse}". Without one the summary, and then the
+	// description, is searched for names.
+	Pattern string `json:"pattern,omitempty"`
+	// Horses, Riders and EventTypes map other names the calendar uses, like
+	// nicknames, to the names in the barn.
+	Horses     map[string]string `json:"horses,omitempty"`
+	Riders     map[string]string `json:"riders,omitempty"`
+	EventTypes map[string]string `json:"event_types,omitempty"`
+	// The defaults are used for events that name no horse, rider or event
+	// type.
+	DefaultHorseID     int64 `json:"default_horse_id,omitempty"`
+	DefaultRiderID     int64 `json:"default_rider_id,omitempty"`
+	DefaultEventTypeID int64 `json:"default_event_type_id,omitempty"`
+}
+
+var placeholder = regexp.MustCompile(`\{(horse|rider|type)\}`)
+
+// compilePattern turns a pattern like "{rider} on {horse}" into a regular
+// expression matching whole summaries.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	var expr strings.Builder
+	expr.WriteString(`(?i)^\s*`)
+	last := 0
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(pattern, -1) {
+		expr.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		expr.WriteString(`(?P<` + pattern[loc[2]:loc[3]] + `>.+?)`)
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(pattern[last:]))
+	expr.WriteString(`\s*$`)
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, errors.New("invalid pattern: " + err.Error())
+	}
+	return re, nil
+}
+
+// names looks up one kind of thing by its name or an alias, ignoring case.
+type names struct {
+	kind string
+	ids  map[string]int64
+}
+
+func newNames(kind string, byName map[string]int64, aliases map[string]string) (*names, error) {
+	n := &names{kind: kind, ids: make(map[string]int64)}
+	for name, id := range byName {
+		n.ids[normalize(name)] = id
+	}
+	for alias, name := range aliases {
+		id, ok := n.ids[normalize(name)]
+		if !ok {
+			return nil, errors.New("alias " + alias + " is for unknown " + kind + " " + name)
+		}
+		n.ids[normalize(alias)] = id
+	}
+	return n, nil
+}
+
+// lookup finds the exact name read by a pattern.
+func (n *names) lookup(name string) (int64, bool) {
+	id, ok := n.ids[normalize(name)]
+	return id, ok
+}
+
+// search finds the one thing named in text, ignoring names that are part of
+// a longer name also found, so "Star" does not clash with "Star Dust".
+func (n *names) search(text string) (int64, error) {
+	text = normalize(text)
+	var found []string
+	for name := range n.ids {
+		if containsWord(text, name) {
+			found = append(found, name)
+		}
+	}
+	ids := make(map[int64]bool)
+	var matched []string
+	for _, name := range found {
+		if isPartOfAnother(name, found) {
+			continue
+		}
+		if !ids[n.ids[name]] {
+			matched = append(matched, name)
+		}
+		ids[n.ids[name]] = true
+	}
+	switch len(ids) {
+	case 0:
+		return 0, nil
+	case 1:
+		return n.ids[matched[0]], nil
+	}
+	sort.Strings(matched)
+	return 0, errors.New("more than one " + n.kind + " named: " + strings.Join(matched, ", "))
+}
+
+func isPartOfAnother(name string, found []string) bool {
+	for _, other := range found {
+		if other != name && containsWord(other, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWord reports whether word appears in text without letters or
+// digits on either side.
+func containsWord(text string, word string) bool {
+	if word == "" {
+		return false
+	}
+	for start := 0; ; {
+		i := strings.Index(text[start:], word)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(word)
+		if (i == 0 || !isWordRune(lastRune(text[:i]))) && (end == len(text) || !isWordRune(firstRune(text[end:]))) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
+
+func lastRune(s string) rune {
+	r := []rune(s)
+	return r[len(r)-1]
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"time"
+
+	"hack/barns"
+	"hack/recurrence"
+	"hack/rides"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerInstructorRoutes(app *fiber.App, s stores) {
+	app.Post("/barn/:barnID/instructors", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		var instructor rides.Instructor
+		err = parseBody(c, &instructor, "instructor")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ManageBarn)
+		if err != nil {
+			return err
+		}
+		err = requireMember(s, barnID, instructor.UserID)
+		if err != nil {
+			return err
+		}
+		instructor.ID = 0
+		instructor.BarnID = barnID
+		err = instructor.Save(s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save instructor", err)
+		}
+		saved, err := rides.GetInstructor(barnID, instructor.ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get instructor", err)
+		}
+		return c.JSON(fiber.Map{
+			"instructor": saved,
+		})
+	})
+
+	app.Get("/barn/:barnID/instructors", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		instructors, err := rides.ListInstructors(barnID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get instructors", err)
+		}
+		return c.JSON(fiber.Map{
+			"instructors": instructors,
+		})
+	})
+
+	// only the event types an instructor leads can change; a different member
+	// is a different instructor
+	app.Put("/barn/:barnID/instructors/:instructorID", func(c *fiber.Ctx) error {
+		var instructor rides.Instructor
+		err := parseBody(c, &instructor, "instructor")
+		if err != nil {
+			return err
+		}
+		existing, err := barnInstructor(c, s, barns.ManageBarn)
+		if err != nil {
+			return err
+		}
+		existing.EventTypeIDs = instructor.EventTypeIDs
+		err = existing.Save(s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save instructor", err)
+		}
+		return c.JSON(fiber.Map{
+			"instructor": existing,
+		})
+	})
+
+	app.Delete("/barn/:barnID/instructors/:instructorID", func(c *fiber.Ctx) error {
+		instructor, err := barnInstructor(c, s, barns.ManageBarn)
+		if err != nil {
+			return err
+		}
+		err = rides.DeleteInstructor(instructor.ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to delete instructor", err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+		})
+	})
+
+	// agenda is the instructor's day, or with view=week their week, on date,
+	// which defaults to today in the barn's time zone
+	app.Get("/barn/:barnID/instructors/:instructorID/agenda", func(c *fiber.Ctx) error {
+		instructor, err := barnInstructor(c, s, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		view := c.Query("view", "day")
+		if view != "day" && view != "week" {
+			return fiber.NewError(fiber.StatusBadRequest, "view must be day or week")
+		}
+		var date time.Time
+		if raw := c.Query("date"); raw != "" {
+			date, err = time.Parse("2006-01-02", raw)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "Failed to parse date: "+err.Error())
+			}
+		} else {
+			barn, err := barns.GetBarn(instructor.BarnID, s.barns)
+			if err != nil {
+				return failed(fiber.StatusInternalServerError, "Failed to get barn", err)
+			}
+			date = recurrence.Day(time.Now().In(utils.Location(barn.TimeZone)))
+		}
+		days, err := rides.GetInstructorAgenda(instructor.ID, utils.Date{Time: date}, view == "week", s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get agenda", err)
+		}
+		return c.JSON(fiber.Map{
+			"instructor": instructor,
+			"days":       days,
+		})
+	})
+}
+
+// barnInstructor resolves the :barnID and :instructorID params to an
+// instructor of that barn.
+func barnInstructor(c *fiber.Ctx, s stores, permission barns.Permission) (*rides.Instructor, error) {
+	barnID, err := paramID(c, "barnID")
+	if err != nil {
+		return nil, err
+	}
+	id, err := paramID(c, "instructorID")
+	if err != nil {
+		return nil, err
+	}
+	err = authorizeBarn(c, s, barnID, permission)
+	if err != nil {
+		return nil, err
+	}
+	instructor, err := rides.GetInstructor(barnID, id, s.rides)
+	if err != nil {
+		return nil, failed(fiber.StatusInternalServerError, "Failed to get instructor", err)
+	}
+	return instructor, nil
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"hack/barns"
+	"hack/riders"
+	"hack/rides"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerLessonRoutes(app *fiber.App, s stores) {
+	app.Post("/barn/:barnID/lessons", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		var lesson rides.Lesson
+		err = parseBody(c, &lesson, "lesson")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+		lesson.ID = 0
+		lesson.BarnID = barnID
+		return saveLesson(c, s, &lesson)
+	})
+
+	app.Get("/barn/:barnID/lessons", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		from, to, err := dateRange(c)
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		lessons, err := rides.ListLessons(barnID, from, to, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get lessons", err)
+		}
+		return c.JSON(fiber.Map{
+			"lessons": lessons,
+		})
+	})
+
+	app.Get("/barn/:barnID/lessons/:lessonID", func(c *fiber.Ctx) error {
+		lesson, err := barnLesson(c, s, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{
+			"lesson": lesson,
+		})
+	})
+
+	// moving a lesson moves every ride on its roster, so it is refused when
+	// any of them would be, unless overridden
+	app.Put("/barn/:barnID/lessons/:lessonID", func(c *fiber.Ctx) error {
+		var lesson rides.Lesson
+		err := parseBody(c, &lesson, "lesson")
+		if err != nil {
+			return err
+		}
+		existing, err := barnLesson(c, s, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+		lesson.ID = existing.ID
+		lesson.BarnID = existing.BarnID
+		return saveLesson(c, s, &lesson)
+	})
+
+	// cancel cancels the lesson for everyone on its roster at once
+	app.Put("/barn/:barnID/lessons/:lessonID/cancel", func(c *fiber.Ctx) error {
+		lesson, err := barnLesson(c, s, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+		err = lesson.Cancel(currentUser(c).ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to cancel lesson", err)
+		}
+		lesson, err = rides.GetLesson(lesson.BarnID, lesson.ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get lesson", err)
+		}
+		return c.JSON(fiber.Map{
+			"lesson": lesson,
+		})
+	})
+
+	// roster adds a horse and rider to the lesson as a ride of their own,
+	// which leaves the lesson again through PUT /ride/cancel
+	app.Post("/barn/:barnID/lessons/:lessonID/roster", func(c *fiber.Ctx) error {
+		var ride rides.Ride
+		err := parseBody(c, &ride, "ride")
+		if err != nil {
+			return err
+		}
+		lesson, err := barnLesson(c, s, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+		ride.ID = 0
+		ride.ScheduleID = nil
+		ride.OccurrenceDate = nil
+		ride.LessonID = &lesson.ID
+		err = authorizeRide(c, s, &ride)
+		if err != nil {
+			return err
+		}
+		err = ride.Save(s.rides, override(c))
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to join lesson", err)
+		}
+		return c.JSON(fiber.Map{
+			"ride": ride,
+		})
+	})
+
+	// the waitlist is offered spots in order as they open up, each held for
+	// the lesson's hold before it goes to the next rider
+	app.Get("/barn/:barnID/lessons/:lessonID/waitlist", func(c *fiber.Ctx) error {
+		lesson, err := barnLesson(c, s, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		entries, err := rides.GetWaitlist(lesson.ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get waitlist", err)
+		}
+		return c.JSON(fiber.Map{
+			"waitlist": entries,
+		})
+	})
+
+	app.Post("/barn/:barnID/lessons/:lessonID/waitlist", func(c *fiber.Ctx) error {
+		var entry rides.WaitlistEntry
+		err := parseBody(c, &entry, "waitlist entry")
+		if err != nil {
+			return err
+		}
+		lesson, err := barnLesson(c, s, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+		err = authorizeHorseAndRider(c, s, entry.HorseID, entry.RiderID, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+		err = authorizeLesson(s, &lesson.ID, entry.HorseID)
+		if err != nil {
+			return err
+		}
+		entry.LessonID = lesson.ID
+		err = rides.JoinWaitlist(&entry, s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to join waitlist", err)
+		}
+		return c.JSON(fiber.Map{
+			"entry": entry,
+		})
+	})
+
+	// confirm takes the spot on offer; the rider's own account and their
+	// guardians can confirm as well as the barn
+	app.Post("/barn/:barnID/lessons/:lessonID/waitlist/:entryID/confirm", func(c *fiber.Ctx) error {
+		entry, err := waitlistEntry(c, s)
+		if err != nil {
+			return err
+		}
+		ride, err := entry.Confirm(s.rides, override(c))
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to confirm spot", err)
+		}
+		return c.JSON(fiber.Map{
+			"entry": entry,
+			"ride":  ride,
+		})
+	})
+
+	app.Post("/barn/:barnID/lessons/:lessonID/waitlist/:entryID/decline", func(c *fiber.Ctx) error {
+		entry, err := waitlistEntry(c, s)
+		if err != nil {
+			return err
+		}
+		err = entry.Decline(s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to decline spot", err)
+		}
+		return c.JSON(fiber.Map{
+			"entry": entry,
+		})
+	})
+}
+
+// waitlistEntry resolves the :barnID, :lessonID and :entryID params to an
+// entry of that lesson's waitlist, which the barn's staff and the accounts
+// linked to its rider may act on. Holds are only dealt with once the caller
+// is authorized, since that writes.
+func waitlistEntry(c *fiber.Ctx, s stores) (*rides.WaitlistEntry, error) {
+	barnID, err := paramID(c, "barnID")
+	if err != nil {
+		return nil, err
+	}
+	lessonID, err := paramID(c, "lessonID")
+	if err != nil {
+		return nil, err
+	}
+	id, err := paramID(c, "entryID")
+	if err != nil {
+		return nil, err
+	}
+	denied := authorizeBarn(c, s, barnID, barns.ManageRides)
+	if denied != nil {
+		linked, err := entryLinked(c, s, lessonID, id)
+		if err != nil {
+			return nil, err
+		}
+		if !linked {
+			return nil, denied
+		}
+	}
+	lesson, err := s.rides.GetLesson(lessonID)
+	if err == utils.ErrNotFound || err == nil && lesson.BarnID != barnID {
+		return nil, fiber.NewError(fiber.StatusNotFound, "lesson not found")
+	}
+	if err != nil {
+		return nil, failed(fiber.StatusInternalServerError, "Failed to get lesson", err)
+	}
+	entry, err := rides.GetWaitlistEntry(lessonID, id, s.rides)
+	if err != nil {
+		return nil, failed(fiber.StatusInternalServerError, "Failed to get waitlist entry", err)
+	}
+	return entry, nil
+}
+
+// entryLinked reports whether the entry is on lessonID's waitlist for a
+// rider the current user is linked to. It only reads, so it is safe before
+// the caller is authorized.
+func entryLinked(c *fiber.Ctx, s stores, lessonID int64, id int64) (bool, error) {
+	entry, err := s.rides.GetWaitlistEntry(id)
+	if err == utils.ErrNotFound || err == nil && entry.LessonID != lessonID {
+		return false, nil
+	}
+	if err != nil {
+		return false, failed(fiber.StatusInternalServerError, "Failed to get waitlist entry", err)
+	}
+	rider, err := riders.GetRider(entry.RiderID, s.riders)
+	if err != nil {
+		return false, failed(fiber.StatusInternalServerError, "Failed to get rider", err)
+	}
+	linked, err := riderLinked(c, s, rider)
+	if err != nil {
+		return false, failed(fiber.StatusInternalServerError, "Failed to check rider link", err)
+	}
+	return linked, nil
+}
+
+// saveLesson checks that the lesson's instructor and resource are in its
+// barn, saves it and responds with it as it now reads.
+func saveLesson(c *fiber.Ctx, s stores, lesson *rides.Lesson) error {
+	err := authorizeInstructorAndResource(s, lesson.BarnID, lesson.InstructorID, lesson.ResourceID)
+	if err != nil {
+		return err
+	}
+	err = lesson.Save(s.rides, override(c))
+	if err != nil {
+		return failed(fiber.StatusBadRequest, "Failed to save lesson", err)
+	}
+	saved, err := rides.GetLesson(lesson.BarnID, lesson.ID, s.rides)
+	if err != nil {
+		return failed(fiber.StatusInternalServerError, "Failed to get lesson", err)
+	}
+	return c.JSON(fiber.Map{
+		"lesson": saved,
+	})
+}
+
+// barnLesson resolves the :barnID and :lessonID params to a lesson of that
+// barn, with its roster.
+func barnLesson(c *fiber.Ctx, s stores, permission barns.Permission) (*rides.Lesson, error) {
+	barnID, err := paramID(c, "barnID")
+	if err != nil {
+		return nil, err
+	}
+	id, err := paramID(c, "lessonID")
+	if err != nil {
+		return nil, err
+	}
+	err = authorizeBarn(c, s, barnID, permission)
+	if err != nil {
+		return nil, err
+	}
+	lesson, err := rides.GetLesson(barnID, id, s.rides)
+	if err != nil {
+		return nil, failed(fiber.StatusInternalServerError, "Failed to get lesson", err)
+	}
+	return lesson, nil
+}
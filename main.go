@@ -4,15 +4,24 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"hack/barns"
+	"hack/booking"
+	"hack/feeds"
 	"hack/horses"
+	"hack/imports"
+	"hack/matching"
+	"hack/migrations"
 	"hack/riders"
 	"hack/rides"
+	"hack/sqlstore"
 	"hack/users"
 	"hack/utils"
 
@@ -20,35 +29,198 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stytchauth/stytch-go/v4/stytch"
-	"github.com/stytchauth/stytch-go/v4/stytch/stytchapi"
 )
 
+type database struct {
+	*sql.DB
+	migrations fs.FS
+	store      *sqlstore.Store
+}
+
+// openDB connects to the database picked by DB_DRIVER: "mysql" (the default)
+// with DSN as the connection string, or "sqlite" with DSN as the file path.
+func openDB() (*database, error) {
+	driver := os.Getenv("DB_DRIVER")
+	dsn := os.Getenv("DSN")
+	switch driver {
+	case "", "mysql":
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			return nil, errors.New("Failed to connect to database: " + err.Error())
+		}
+		return &database{DB: db, migrations: migrations.MySQL(), store: sqlstore.NewMySQL(db)}, nil
+	case "sqlite":
+		if dsn == "" {
+			dsn = "hack.db"
+		}
+		if !strings.Contains(dsn, "?") {
+			dsn += "?_foreign_keys=on"
+		}
+		db, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			return nil, errors.New("Failed to open sqlite database: " + err.Error())
+		}
+		// sqlite allows a single writer; sharing one connection avoids
+		// "database is locked" errors and keeps :memory: databases intact
+		db.SetMaxOpenConns(1)
+		return &database{DB: db, migrations: migrations.SQLite(), store: sqlstore.NewSQLite(db)}, nil
+	default:
+		return nil, errors.New("unknown DB_DRIVER: " + driver + " (expected mysql or sqlite)")
+	}
+}
+
+func migrate(args []string) error {
+	godotenv.Load()
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := migrations.New(db.DB, db.migrations)
+	if err != nil {
+		return errors.New("Failed to load migrations: " + err.Error())
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "up":
+		ran, err := migrator.Up()
+		for _, m := range ran {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New("down takes a positive number of steps")
+			}
+		}
+		ran, err := migrator.Down(steps)
+		for _, m := range ran {
+			fmt.Printf("rolled back %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return errors.New("unknown migrate command: " + command + " (expected up, down [steps] or status)")
+	}
+}
+
+// newAuthProvider picks the identity service from AUTH_PROVIDER: "stytch"
+// (the default) or "local", which keeps passcodes and sessions in our own
+// database and signs session tokens with AUTH_SECRET.
+func newAuthProvider(store users.LocalAuthStore) (users.AuthProvider, error) {
+	switch provider := os.Getenv("AUTH_PROVIDER"); provider {
+	case "", "stytch":
+		env := stytch.EnvTest
+		if os.Getenv("STYTCH_ENV") == "live" {
+			env = stytch.EnvLive
+		}
+		auth, err := users.NewStytchProvider(env, os.Getenv("STYTCH_PROJECT_ID"), os.Getenv("STYTCH_PROJECT_SECRET"))
+		if err != nil {
+			return nil, errors.New("Failed to create stytch provider: " + err.Error())
+		}
+		return auth, nil
+	case "local":
+		auth, err := users.NewLocalProvider(store, users.LogSender{}, os.Getenv("AUTH_SECRET"))
+		if err != nil {
+			return nil, errors.New("Failed to create local auth provider: " + err.Error())
+		}
+		return auth, nil
+	default:
+		return nil, errors.New("unknown AUTH_PROVIDER: " + provider + " (expected stytch or local)")
+	}
+}
+
 func setup() error {
 	godotenv.Load()
 	apiKey := os.Getenv("API_KEY")
 	if apiKey == "" {
 		return errors.New("API_KEY not set")
 	}
-	db, err := sql.Open("mysql", os.Getenv("DSN"))
+	db, err := openDB()
 	if err != nil {
-		return errors.New("Failed to connect to database: " + err.Error())
+		return err
+	}
+
+	if os.Getenv("AUTO_MIGRATE") != "false" {
+		migrator, err := migrations.New(db.DB, db.migrations)
+		if err != nil {
+			return errors.New("Failed to load migrations: " + err.Error())
+		}
+		ran, err := migrator.Up()
+		if err != nil {
+			return errors.New("Failed to migrate database: " + err.Error())
+		}
+		for _, m := range ran {
+			fmt.Printf("applied migration %d_%s\n", m.Version, m.Name)
+		}
 	}
 
-	// stytch
-	client, err := stytchapi.NewAPIClient(
-		stytch.EnvTest,
-		os.Getenv("STYTCH_PROJECT_ID"),
-		os.Getenv("STYTCH_PROJECT_SECRET"),
-	)
+	sqlStore := db.store
+
+	auth, err := newAuthProvider(sqlStore)
 	if err != nil {
-		return errors.New("Failed to create stytch client: " + err.Error())
+		return err
 	}
 
-	app := fiber.New()
+	app := newApp(apiKey, auth, stores{
+		barns:    sqlStore,
+		booking:  sqlStore,
+		feeds:    sqlStore,
+		horses:   sqlStore,
+		imports:  sqlStore,
+		matching: sqlStore,
+		riders:   sqlStore,
+		rides:    sqlStore,
+		users:    sqlStore,
+	})
+	return app.Listen(":8000")
+}
+
+// stores groups the persistence the handlers depend on, so tests can swap in
+// fakes for any of them.
+type stores struct {
+	barns    barns.BarnStore
+	booking  booking.Store
+	feeds    feeds.Store
+	horses   horses.Store
+	imports  imports.Store
+	matching matching.Store
+	riders   riders.RiderStore
+	rides    rides.Store
+	users    users.UserStore
+}
+
+func newApp(apiKey string, auth users.AuthProvider, s stores) *fiber.App {
+	app := fiber.New(fiber.Config{
+		ErrorHandler: errorHandler,
+	})
+	app.Use(recover.New())
 	app.Use(logger.New())
 	app.Use(cors.New())
+	registerPublicFeedRoutes(app, s)
 	app.Use(func(c *fiber.Ctx) error {
 		providedKey := c.Get("x-api-key")
 		if providedKey != apiKey {
@@ -75,7 +247,7 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		err = user.Login(client, db)
+		err = user.Login(auth, s.users)
 		if err != nil {
 			msg := "error logging in user: " + err.Error()
 			fmt.Println(msg)
@@ -98,7 +270,7 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		err = users.Logout(session.Token, client)
+		err = users.Logout(session.Token, auth)
 		if err != nil {
 			msg := "error logging out user: " + err.Error()
 			fmt.Println(msg)
@@ -119,7 +291,7 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		err = user.Signup(client, db)
+		err = user.Signup(auth, s.users)
 		if err != nil {
 			msg := "error signing up user: " + err.Error()
 			fmt.Println(msg)
@@ -140,7 +312,7 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		user, err := userAuth.AuthenticatePasscode(client, db)
+		user, err := userAuth.AuthenticatePasscode(auth, s.users)
 		if err != nil {
 			msg := "error authenticating passcode: " + err.Error()
 			fmt.Println(msg)
@@ -156,7 +328,7 @@ func setup() error {
 		session := users.Session{
 			Token: sessionToken,
 		}
-		err = session.Validate(client)
+		err := session.Validate(auth)
 		if err != nil {
 			msg := "error validating session: " + err.Error()
 			fmt.Println(msg)
@@ -164,13 +336,22 @@ func setup() error {
 				"error": msg,
 			})
 		}
+		user, err := s.users.GetUserByAuthUserID(session.AuthUserID)
+		if err != nil {
+			msg := "error resolving session user: " + err.Error()
+			fmt.Println(msg)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		c.Locals(userKey, user)
 		return c.Next()
 	})
 
 	app.Post("/barn", func(c *fiber.Ctx) error {
 		type barnRequest struct {
-			Name   string `json:"name"`
-			UserID int64  `json:"user_id"`
+			Name     string `json:"name"`
+			TimeZone string `json:"time_zone"`
 		}
 		var req barnRequest
 		err := c.BodyParser(&req)
@@ -182,15 +363,53 @@ func setup() error {
 			})
 		}
 		barn := barns.Barn{
-			Name: req.Name,
+			Name:     req.Name,
+			TimeZone: req.TimeZone,
 		}
-		err = barn.Save(req.UserID, db)
+		err = barn.Save(currentUser(c).ID, s.barns)
 		if err != nil {
-			msg := "Failed to save barn: " + err.Error()
-			fmt.Println(msg)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": msg,
-			})
+			return failed(fiber.StatusBadRequest, "Failed to save barn", err)
+		}
+		return c.JSON(fiber.Map{
+			"barn": barn,
+		})
+	})
+
+	app.Put("/barn/:barnID", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		var req barns.Barn
+		err = parseBody(c, &req, "barn")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ManageBarn)
+		if err != nil {
+			return err
+		}
+		barn, err := barns.GetBarn(barnID, s.barns)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get barn", err)
+		}
+		timeZone := barn.TimeZone
+		if req.Name != "" {
+			barn.Name = req.Name
+		}
+		if req.TimeZone != "" {
+			barn.TimeZone = req.TimeZone
+		}
+		err = barns.UpdateBarn(barn, s.barns)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to update barn", err)
+		}
+		// rides keep their dates and times on the barn's clock
+		if barn.TimeZone != timeZone {
+			err = rides.Relocate(barnID, barn.TimeZone, s.rides)
+			if err != nil {
+				return failed(fiber.StatusInternalServerError, "Failed to move rides to the new time zone", err)
+			}
 		}
 		return c.JSON(fiber.Map{
 			"barn": barn,
@@ -198,8 +417,18 @@ func setup() error {
 	})
 
 	app.Get("/user/:userID/barns", func(c *fiber.Ctx) error {
-		userID := c.Params("userID")
-		barns, err := barns.GetBarnsByUserID(userID, db)
+		userID, err := strconv.ParseInt(c.Params("userID"), 10, 64)
+		if err != nil {
+			msg := "Failed to parse user ID: " + err.Error()
+			fmt.Println(msg)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		if userID != currentUser(c).ID {
+			return fiber.NewError(fiber.StatusForbidden, "cannot list another user's barns")
+		}
+		barns, err := barns.GetBarnsByUserID(userID, s.barns)
 		if err != nil {
 			msg := "Failed to get barns: " + err.Error()
 			fmt.Println(msg)
@@ -222,7 +451,11 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		horses, err := horses.GetHorsesByBarnID(barnID, db)
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		horses, err := horses.GetHorsesByBarnID(barnID, s.horses)
 		if err != nil {
 			msg := "Failed to get horses: " + err.Error()
 			logger.Printf(msg)
@@ -245,7 +478,11 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		riders, err := riders.GetRidersByBarnID(barnID, db)
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		riders, err := riders.GetRidersByBarnID(barnID, s.riders)
 		if err != nil {
 			msg := "Failed to get riders: " + err.Error()
 			logger.Printf(msg)
@@ -268,13 +505,20 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		err = horse.Save(db)
+		if horse.ID > 0 {
+			existing, err := authorizeHorse(c, s, horse.ID, barns.ManageHorses)
+			if err != nil {
+				return err
+			}
+			horse.BarnID = existing.BarnID
+		}
+		err = authorizeBarn(c, s, horse.BarnID, barns.ManageHorses)
 		if err != nil {
-			msg := "Failed to save horse: " + err.Error()
-			fmt.Println(msg)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": msg,
-			})
+			return err
+		}
+		err = horse.Save(s.horses)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save horse", err)
 		}
 		return c.JSON(fiber.Map{
 			"horse": horse,
@@ -282,7 +526,7 @@ func setup() error {
 	})
 
 	app.Get("/horses", func(c *fiber.Ctx) error {
-		horses, err := horses.GetHorses(db)
+		horses, err := horses.GetHorsesByUserID(currentUser(c).ID, s.horses)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to get horses: " + err.Error(),
@@ -301,7 +545,19 @@ func setup() error {
 				"error": "Failed to parse rider: " + err.Error(),
 			})
 		}
-		err = rider.Save(db)
+		if rider.ID > 0 {
+			existing, err := authorizeRider(c, s, rider.ID, barns.ManageRiders)
+			if err != nil {
+				return err
+			}
+			rider.BarnID = existing.BarnID
+			rider.UserID = existing.UserID
+		}
+		err = authorizeBarn(c, s, rider.BarnID, barns.ManageRiders)
+		if err != nil {
+			return err
+		}
+		err = rider.Save(s.riders)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to save rider: " + err.Error(),
@@ -313,7 +569,7 @@ func setup() error {
 	})
 
 	app.Get("/riders", func(c *fiber.Ctx) error {
-		riders, err := riders.GetRiders(db)
+		riders, err := riders.GetRidersByUserID(currentUser(c).ID, s.riders)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to get riders: " + err.Error(),
@@ -334,13 +590,19 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		err = ride.Save(db)
+		err = authorizeRide(c, s, &ride)
 		if err != nil {
-			msg := "Failed to save ride: " + err.Error()
-			fmt.Println(msg)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": msg,
-			})
+			return err
+		}
+		err = ride.Save(s.rides, override(c))
+		if conflict, ok := err.(*rides.ConflictError); ok {
+			return conflict
+		}
+		if workload, ok := err.(*rides.WorkloadError); ok {
+			return workload
+		}
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to save ride", err)
 		}
 		return c.JSON(fiber.Map{
 			"ride": ride,
@@ -357,13 +619,13 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		err = ride.Cancel(db)
+		err = authorizeRide(c, s, &ride)
 		if err != nil {
-			msg := "Failed to cancel ride: " + err.Error()
-			fmt.Println(msg)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": msg,
-			})
+			return err
+		}
+		err = ride.Cancel(currentUser(c).ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to cancel ride", err)
 		}
 		return c.JSON(fiber.Map{
 			"ride": ride,
@@ -380,7 +642,7 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		err = eventType.Save(db)
+		err = eventType.Save(s.rides)
 		if err != nil {
 			msg := "Failed to save event type: " + err.Error()
 			fmt.Println(msg)
@@ -394,7 +656,7 @@ func setup() error {
 	})
 
 	app.Get("/event/types", func(c *fiber.Ctx) error {
-		types, err := rides.ListEventTypes(db)
+		types, err := rides.ListEventTypes(s.rides)
 		if err != nil {
 			msg := "Failed to get event types: " + err.Error()
 			fmt.Println(msg)
@@ -417,7 +679,17 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		err = schedule.Save(db)
+		err = authorizeSchedule(c, s, &schedule)
+		if err != nil {
+			return err
+		}
+		err = schedule.Save(s.rides, override(c))
+		if conflict, ok := err.(*rides.ConflictError); ok {
+			return conflict
+		}
+		if workload, ok := err.(*rides.WorkloadError); ok {
+			return workload
+		}
 		if err != nil {
 			msg := "Failed to save schedule: " + err.Error()
 			fmt.Println(msg)
@@ -425,9 +697,13 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		return c.JSON(fiber.Map{
+		response := fiber.Map{
 			"success": true,
-		})
+		}
+		if len(schedule.Warnings) > 0 {
+			response["warnings"] = schedule.Warnings
+		}
+		return c.JSON(response)
 	})
 
 	app.Delete("/schedule/:id", func(c *fiber.Ctx) error {
@@ -439,7 +715,11 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		err = rides.DeleteSchedule(id, db)
+		_, err = authorizeScheduleID(c, s, id, barns.ManageSchedules)
+		if err != nil {
+			return err
+		}
+		err = rides.DeleteSchedule(id, s.rides)
 		if err != nil {
 			msg := "Failed to delete schedule: " + err.Error()
 			fmt.Println(msg)
@@ -468,7 +748,11 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		rides, err := rides.GetScheduleByDay(barnID, utils.Date{Time: date}, db)
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		day, err := rides.GetScheduleByDay(barnID, utils.Date{Time: date}, s.rides)
 		if err != nil {
 			msg := "Failed to get ride schedule: " + err.Error()
 			fmt.Println(msg)
@@ -477,7 +761,8 @@ func setup() error {
 			})
 		}
 		return c.JSON(fiber.Map{
-			"rides": rides,
+			"rides":   day.Rides,
+			"lessons": day.Lessons,
 		})
 	})
 
@@ -498,7 +783,11 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		schedule, err := rides.GetHorseScheduleByDay(id, utils.Date{Time: date}, db)
+		_, err = authorizeHorse(c, s, id, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		schedule, err := rides.GetHorseScheduleByDay(id, utils.Date{Time: date}, s.rides)
 		if err != nil {
 			msg := "Failed to get schedule: " + err.Error()
 			fmt.Println(msg)
@@ -520,7 +809,11 @@ func setup() error {
 				"error": msg,
 			})
 		}
-		schedules, err := rides.ListSchedules(barnID, db)
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		schedules, err := rides.ListSchedules(barnID, s.rides)
 		if err != nil {
 			msg := "Failed to list recurring schedules: " + err.Error()
 			fmt.Println(msg)
@@ -533,10 +826,34 @@ func setup() error {
 		})
 	})
 
-	return app.Listen(":8000")
+	registerMemberRoutes(app, s)
+	registerOccurrenceRoutes(app, s)
+	registerRideStatusRoutes(app, s)
+	registerHealthRoutes(app, s)
+	registerWorkloadRoutes(app, s)
+	registerUnavailabilityRoutes(app, s)
+	registerRiderRoutes(app, s)
+	registerHorseRoutes(app, s)
+	registerMatchRoutes(app, s)
+	registerResourceRoutes(app, s)
+	registerInstructorRoutes(app, s)
+	registerLessonRoutes(app, s)
+	registerBookingRoutes(app, s)
+	registerCalendarRoutes(app, s)
+	registerFeedRoutes(app, s)
+	registerImportRoutes(app, s)
+
+	return app
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrate(os.Args[2:])
+		if err != nil {
+			log.Fatal("Failed to migrate: " + err.Error())
+		}
+		return
+	}
 	err := setup()
 	if err != nil {
 		log.Fatal("Failed to setup app: " + err.Error())
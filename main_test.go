@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hack/barns"
+	"hack/horses"
+	"hack/riders"
+	"hack/rides"
+	"hack/users"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// The fakes below embed the store interfaces they stand in for and only
+// implement what the routes under test reach; anything else panics.
+
+// fakeAuth takes a session token to be the provider's user ID.
+type fakeAuth struct {
+	users.AuthProvider
+}
+
+func (fakeAuth) ValidateSession(sessionToken string) (string, error) {
+	if sessionToken == "" {
+		return "", errors.New("no session")
+	}
+	return sessionToken, nil
+}
+
+type fakeUsers struct {
+	users.UserStore
+	byAuthUserID map[string]*users.User
+}
+
+func (f *fakeUsers) GetUserByAuthUserID(authUserID string) (*users.User, error) {
+	u, ok := f.byAuthUserID[authUserID]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	return u, nil
+}
+
+// fakeBarns knows each user's role in each barn.
+type fakeBarns struct {
+	barns.BarnStore
+	roles map[int64]map[int64]barns.Role
+}
+
+func (f *fakeBarns) GetBarnOwner(barnID int64, userID int64) (*barns.BarnOwner, error) {
+	role, ok := f.roles[barnID][userID]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	return &barns.BarnOwner{BarnID: barnID, OwnerID: userID, Role: role}, nil
+}
+
+func (f *fakeBarns) ListBarnMembers(barnID int64) ([]*barns.Member, error) {
+	var members []*barns.Member
+	for userID, role := range f.roles[barnID] {
+		members = append(members, &barns.Member{ID: userID, BarnID: barnID, UserID: userID, Email: "member@example.com", Phone: "+15550000000", Role: role})
+	}
+	return members, nil
+}
+
+type fakeHorses struct {
+	horses.Store
+	horses  map[int64]*horses.Horse
+	updated []*horses.Horse
+}
+
+func (f *fakeHorses) GetHorse(id int64) (*horses.Horse, error) {
+	h, ok := f.horses[id]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	horse := *h
+	return &horse, nil
+}
+
+func (f *fakeHorses) UpdateHorse(h *horses.Horse) error {
+	f.updated = append(f.updated, h)
+	return nil
+}
+
+type fakeRiders struct {
+	riders.RiderStore
+	riders map[int64]*riders.Rider
+}
+
+func (f *fakeRiders) GetRider(id int64) (*riders.Rider, error) {
+	r, ok := f.riders[id]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	rider := *r
+	return &rider, nil
+}
+
+func (f *fakeRiders) ListGuardians(riderID int64) ([]*riders.Guardian, error) {
+	return nil, nil
+}
+
+type fakeRides struct {
+	rides.Store
+	rides   map[int64]*rides.Ride
+	changes []*rides.StatusChange
+}
+
+func (f *fakeRides) GetRide(id int64) (*rides.Ride, error) {
+	r, ok := f.rides[id]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	ride := *r
+	return &ride, nil
+}
+
+func (f *fakeRides) UpdateRideStatus(id int64, status rides.Status) error {
+	f.rides[id].Status = status
+	return nil
+}
+
+func (f *fakeRides) InsertStatusChange(change *rides.StatusChange) error {
+	f.changes = append(f.changes, change)
+	return nil
+}
+
+const testAPIKey = "test-key"
+
+// testBarn is barn 1 with an owner (session "owner"), a rider (session
+// "rider") and an outsider who belongs to barn 2 only (session
+// "outsider"). Horse 1 and riders 1 and 2 are in barn 1; rider 1 is the
+// rider's account and rider 2 the outsider's, linked while they were still a
+// member. Ride 1 is scheduled and ride 2 cancelled.
+type testBarn struct {
+	app    *fiber.App
+	horses *fakeHorses
+	rides  *fakeRides
+}
+
+func newTestBarn() *testBarn {
+	horseStore := &fakeHorses{horses: map[int64]*horses.Horse{
+		1: {ID: 1, Name: "Biscuit", BarnID: 1, Gender: horses.Mare},
+	}}
+	rideStore := &fakeRides{rides: map[int64]*rides.Ride{
+		1: {ID: 1, HorseID: 1, RiderID: 1, Status: rides.Scheduled},
+		2: {ID: 2, HorseID: 1, RiderID: 1, Status: rides.Cancelled},
+	}}
+	riderUserID, outsiderUserID := int64(2), int64(3)
+	s := stores{
+		barns: &fakeBarns{roles: map[int64]map[int64]barns.Role{
+			1: {1: barns.RoleOwner, 2: barns.RoleRider},
+			2: {3: barns.RoleOwner},
+		}},
+		horses: horseStore,
+		riders: &fakeRiders{riders: map[int64]*riders.Rider{
+			1: {ID: 1, Name: "Ann", BarnID: 1, UserID: &riderUserID, MedicalNotes: "asthma"},
+			2: {ID: 2, Name: "Bea", BarnID: 1, UserID: &outsiderUserID, MedicalNotes: "asthma"},
+		}},
+		rides: rideStore,
+		users: &fakeUsers{byAuthUserID: map[string]*users.User{
+			"owner":    {ID: 1, Name: "Owner"},
+			"rider":    {ID: 2, Name: "Rider"},
+			"outsider": {ID: 3, Name: "Outsider"},
+		}},
+	}
+	return &testBarn{app: newApp(testAPIKey, fakeAuth{}, s), horses: horseStore, rides: rideStore}
+}
+
+// do sends a request as the user signed in with session and returns the
+// status and the decoded body.
+func (b *testBarn) do(t *testing.T, method string, path string, session string, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", testAPIKey)
+	req.Header.Set("x-session-token", session)
+	resp, err := b.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if len(raw) > 0 && json.Unmarshal(raw, &decoded) != nil {
+		t.Fatalf("%s %s: body is not JSON: %s", method, path, raw)
+	}
+	return resp.StatusCode, decoded
+}
+
+func TestHorseRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		session string
+		body    string
+		status  int
+	}{
+		{"owner views", "GET", "/horse/1", "owner", "", fiber.StatusOK},
+		{"rider views", "GET", "/horse/1", "rider", "", fiber.StatusOK},
+		{"outsider views", "GET", "/horse/1", "outsider", "", fiber.StatusForbidden},
+		{"no session", "GET", "/horse/1", "", "", fiber.StatusUnauthorized},
+		{"missing horse", "GET", "/horse/99", "owner", "", fiber.StatusNotFound},
+		{"bad id", "GET", "/horse/x", "owner", "", fiber.StatusBadRequest},
+		{"owner updates", "PUT", "/horse/1", "owner", `{"name":"Biscuit","gender":"gelding"}`, fiber.StatusOK},
+		{"rider updates", "PUT", "/horse/1", "rider", `{"name":"Biscuit"}`, fiber.StatusForbidden},
+		{"outsider updates", "PUT", "/horse/1", "outsider", `{"name":"Biscuit"}`, fiber.StatusForbidden},
+		{"update missing horse", "PUT", "/horse/99", "owner", `{"name":"Biscuit"}`, fiber.StatusNotFound},
+		{"invalid gender", "PUT", "/horse/1", "owner", `{"name":"Biscuit","gender":"colt"}`, fiber.StatusBadRequest},
+		{"outsider moves horse in", "POST", "/horse", "outsider", `{"id":1,"name":"Biscuit","barn_id":2}`, fiber.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBarn()
+			status, body := b.do(t, tt.method, tt.path, tt.session, tt.body)
+			if status != tt.status {
+				t.Fatalf("status %d, want %d: %v", status, tt.status, body)
+			}
+			if status != fiber.StatusOK && len(b.horses.updated) > 0 {
+				t.Fatalf("horse saved on a %d", status)
+			}
+		})
+	}
+}
+
+func TestUpdateHorseKeepsBarn(t *testing.T) {
+	b := newTestBarn()
+	status, body := b.do(t, "PUT", "/horse/1", "owner", `{"name":"Biscuit","barn_id":2}`)
+	if status != fiber.StatusOK {
+		t.Fatalf("status %d: %v", status, body)
+	}
+	if len(b.horses.updated) != 1 || b.horses.updated[0].BarnID != 1 {
+		t.Fatalf("horse saved as %+v, want it kept in barn 1", b.horses.updated)
+	}
+}
+
+func TestRideStatusRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		session string
+		status  int
+		want    rides.Status
+	}{
+		{"check in", "/ride/1/check-in", "owner", fiber.StatusOK, rides.CheckedIn},
+		{"rider cannot manage rides", "/ride/1/check-in", "rider", fiber.StatusForbidden, rides.Scheduled},
+		{"outsider", "/ride/1/no-show", "outsider", fiber.StatusForbidden, rides.Scheduled},
+		{"missing ride", "/ride/99/check-in", "owner", fiber.StatusNotFound, ""},
+		{"cancelled ride", "/ride/2/check-in", "owner", fiber.StatusConflict, rides.Cancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBarn()
+			status, body := b.do(t, "POST", tt.path, tt.session, "")
+			if status != tt.status {
+				t.Fatalf("status %d, want %d: %v", status, tt.status, body)
+			}
+			if tt.want == "" {
+				return
+			}
+			id := int64(1)
+			if strings.HasPrefix(tt.path, "/ride/2/") {
+				id = 2
+			}
+			if got := b.rides.rides[id].Status; got != tt.want {
+				t.Fatalf("ride is %s, want %s", got, tt.want)
+			}
+			if status != fiber.StatusOK && len(b.rides.changes) > 0 {
+				t.Fatalf("status change recorded on a %d", status)
+			}
+		})
+	}
+}
+
+func TestRiderView(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		session string
+		status  int
+		medical bool
+	}{
+		{"owner", "/rider/1", "owner", fiber.StatusOK, true},
+		{"own account", "/rider/1", "rider", fiber.StatusOK, true},
+		{"other rider of the barn", "/rider/2", "rider", fiber.StatusOK, false},
+		{"account of a former member", "/rider/2", "outsider", fiber.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBarn()
+			status, body := b.do(t, "GET", tt.path, tt.session, "")
+			if status != tt.status {
+				t.Fatalf("status %d, want %d: %v", status, tt.status, body)
+			}
+			if status != fiber.StatusOK {
+				return
+			}
+			rider := body["rider"].(map[string]interface{})
+			if got := rider["medical_notes"] != nil && rider["medical_notes"] != ""; got != tt.medical {
+				t.Fatalf("medical notes shown %v, want %v", got, tt.medical)
+			}
+		})
+	}
+}
+
+func TestMemberContacts(t *testing.T) {
+	tests := []struct {
+		name     string
+		session  string
+		status   int
+		contacts bool
+	}{
+		{"owner", "owner", fiber.StatusOK, true},
+		{"rider", "rider", fiber.StatusOK, false},
+		{"outsider", "outsider", fiber.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBarn()
+			status, body := b.do(t, "GET", "/barn/1/members", tt.session, "")
+			if status != tt.status {
+				t.Fatalf("status %d, want %d: %v", status, tt.status, body)
+			}
+			if status != fiber.StatusOK {
+				return
+			}
+			for _, m := range body["members"].([]interface{}) {
+				member := m.(map[string]interface{})
+				if got := member["email"] != "" || member["phone"] != ""; got != tt.contacts {
+					t.Fatalf("contacts shown %v, want %v: %v", got, tt.contacts, member)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"hack/barns"
+	"hack/matching"
+	"hack/rides"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerMatchRoutes(app *fiber.App, s stores) {
+	// takes the ride being created, without a horse, and ranks the horses of
+	// the rider's barn for it. ?discipline= asks for horses that do it.
+	app.Post("/ride/matches", func(c *fiber.Ctx) error {
+		var ride rides.Ride
+		err := parseBody(c, &ride, "ride")
+		if err != nil {
+			return err
+		}
+		_, err = authorizeRider(c, s, ride.RiderID, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+		ranking, err := matching.Rank(ride, c.Query("discipline"), s.matching)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to match horses", err)
+		}
+		return c.JSON(ranking)
+	})
+}
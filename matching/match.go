@@ -0,0 +1,199 @@
+package matching
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"hack/horses"
+	"hack/riders"
+	"hack/rides"
+)
+
+// Store is everything matching needs from persistence.
+type Store interface {
+	rides.Store
+	GetHorsesByBarnID(barnID int64) ([]*horses.Horse, error)
+	GetRider(id int64) (*riders.Rider, error)
+}
+
+// baseScore is the score of a horse with nothing for or against it.
+const baseScore = 100
+
+// Match is how well a horse suits the rider of a ride and whether it is free
+// to take it.
+type Match struct {
+	Horse *horses.Horse `json:"horse"`
+	Score int           `json:"score"`
+	// Suitable is false when the horse needs a more skilled rider, cannot
+	// carry the rider or does not do the discipline asked for.
+	Suitable bool `json:"suitable"`
+	// Available is false when the horse is blocked or already booked at the
+	// time, or a blocking workload rule keeps it from the ride.
+	Available  bool               `json:"available"`
+	Reasons    []string           `json:"reasons"`
+	Conflicts  []*rides.Conflict  `json:"conflicts,omitempty"`
+	Violations []*rides.Violation `json:"violations,omitempty"`
+	// DayRides and WeekMinutes are how hard the horse is already worked on
+	// the day of the ride and in its week.
+	DayRides    int `json:"day_rides"`
+	WeekMinutes int `json:"week_minutes"`
+}
+
+// Ranking is the barn's horses for a ride, best first, with the conflicts
+// the ride has whichever horse it gets.
+type Ranking struct {
+	Rider     *riders.Rider     `json:"rider"`
+	Conflicts []*rides.Conflict `json:"conflicts"`
+	Matches   []*Match          `json:"matches"`
+}
+
+// Rank scores every horse in the rider's barn for the ride, whose horse is
+// ignored. Suitable, available horses come first; among them, horses that
+// suit the rider's level and the discipline, when one is given, score
+// higher and horses worked more that day and week score lower.
+func Rank(ride rides.Ride, discipline string, store Store) (*Ranking, error) {
+	if ride.Date.IsZero() {
+		return nil, errors.New("a ride needs a date")
+	}
+	rider, err := store.GetRider(ride.RiderID)
+	if err != nil {
+		return nil, err
+	}
+	// the ranking goes to whoever manages rides, who may not see these
+	rider.MedicalNotes = ""
+	barnHorses, err := store.GetHorsesByBarnID(rider.BarnID)
+	if err != nil {
+		return nil, err
+	}
+	workloads, err := rides.GetWeekWorkload(rider.BarnID, ride.Date, store)
+	if err != nil {
+		return nil, err
+	}
+	ranking := &Ranking{Rider: rider, Conflicts: []*rides.Conflict{}, Matches: []*Match{}}
+	for i, h := range barnHorses {
+		m := &Match{Horse: h, Score: baseScore, Suitable: true, Available: true, Reasons: []string{}}
+		m.suit(rider, discipline)
+		if w, ok := workloads[h.ID]; ok {
+			m.load(w, ride)
+		}
+		r := ride
+		r.HorseID = h.ID
+		conflicts, err := r.Check(store)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range conflicts {
+			// the rider's and instructor's other bookings and a full
+			// resource are in the way whichever horse the ride gets
+			if c.Kind != rides.HorseConflict && c.Kind != rides.HorseUnavailable {
+				if i == 0 {
+					ranking.Conflicts = append(ranking.Conflicts, c)
+				}
+				continue
+			}
+			m.Available = false
+			m.Conflicts = append(m.Conflicts, c)
+			if c.Block != nil {
+				m.Reasons = append(m.Reasons, "unavailable: "+string(c.Block.Reason))
+			} else {
+				m.Reasons = append(m.Reasons, "already booked at that time")
+			}
+		}
+		rule, violations, err := r.FindViolations(store)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range violations {
+			m.Score -= 15
+			m.Reasons = append(m.Reasons, "would go over "+string(v.Limit))
+		}
+		m.Violations = violations
+		if len(violations) > 0 && rule.Enforcement == rides.Block {
+			m.Available = false
+		}
+		ranking.Matches = append(ranking.Matches, m)
+	}
+	sort.SliceStable(ranking.Matches, func(i, j int) bool {
+		a, b := ranking.Matches[i], ranking.Matches[j]
+		aFits, bFits := a.Available && a.Suitable, b.Available && b.Suitable
+		if aFits != bFits {
+			return aFits
+		}
+		if a.Available != b.Available {
+			return a.Available
+		}
+		if a.Suitable != b.Suitable {
+			return a.Suitable
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Horse.Name < b.Horse.Name
+	})
+	return ranking, nil
+}
+
+// suit checks the horse against the rider's skill and weight and the
+// discipline, and scores its temperament for the rider.
+func (m *Match) suit(rider *riders.Rider, discipline string) {
+	h := m.Horse
+	rank := rider.SkillLevel.Rank()
+	if required := h.MinSkillLevel.Rank(); required > 1 && rank < required {
+		m.Suitable = false
+		m.Reasons = append(m.Reasons, "needs a rider who is "+string(h.MinSkillLevel)+" or better")
+		if rank == 0 {
+			m.Reasons = append(m.Reasons, "the rider's skill level is not set")
+		}
+	}
+	if h.MaxRiderWeightPounds > 0 {
+		if rider.WeightPounds == 0 {
+			m.Reasons = append(m.Reasons, "the rider's weight is not set")
+		} else if rider.WeightPounds > h.MaxRiderWeightPounds {
+			m.Suitable = false
+			m.Reasons = append(m.Reasons, "carries riders up to "+strconv.Itoa(h.MaxRiderWeightPounds)+" pounds")
+		}
+	}
+	if discipline != "" {
+		if h.Does(discipline) {
+			m.Score += 10
+			m.Reasons = append(m.Reasons, "does "+discipline)
+		} else if len(h.Disciplines) > 0 {
+			m.Suitable = false
+			m.Reasons = append(m.Reasons, "does not do "+discipline)
+		}
+	}
+	// riders without a level are treated as less experienced
+	switch {
+	case h.Temperament == horses.Calm && rank <= 2:
+		m.Score += 20
+		m.Reasons = append(m.Reasons, "calm horse for a less experienced rider")
+	case h.Temperament == horses.Steady:
+		m.Score += 10
+		m.Reasons = append(m.Reasons, "steady horse")
+	case h.Temperament == horses.Spirited && rank <= 2:
+		m.Score -= 20
+		m.Reasons = append(m.Reasons, "spirited horse for a less experienced rider")
+	case h.Temperament == horses.Spirited && rank >= 4:
+		m.Score += 10
+		m.Reasons = append(m.Reasons, "spirited horse for an experienced rider")
+	}
+}
+
+// load marks the horse down for the rides it already has on the ride's day
+// and the minutes it is worked in the ride's week.
+func (m *Match) load(w *rides.HorseWorkload, ride rides.Ride) {
+	m.WeekMinutes = w.Minutes
+	for _, day := range w.Days {
+		if day.Date.Equal(ride.Date.Time) {
+			m.DayRides = day.Rides
+		}
+	}
+	m.Score -= 5*m.DayRides + m.WeekMinutes/30
+	switch {
+	case m.DayRides == 1:
+		m.Reasons = append(m.Reasons, "already ridden once that day")
+	case m.DayRides > 1:
+		m.Reasons = append(m.Reasons, "already ridden "+strconv.Itoa(m.DayRides)+" times that day")
+	}
+}
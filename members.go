@@ -0,0 +1,229 @@
+package main
+
+import (
+	"hack/barns"
+	"hack/rides"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerMemberRoutes(app *fiber.App, s stores) {
+	app.Get("/barn/:barnID/members", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		actor, err := membership(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		members, err := barns.ListMembers(barnID, s.barns)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to list members", err)
+		}
+		// contact details are for those who manage the members
+		if !actor.Role.Can(barns.ManageMembers) {
+			for _, m := range members {
+				m.Email, m.Phone = "", ""
+			}
+		}
+		return c.JSON(fiber.Map{
+			"members": members,
+		})
+	})
+
+	app.Put("/barn/:barnID/members/:memberID", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		memberID, err := paramID(c, "memberID")
+		if err != nil {
+			return err
+		}
+		var req struct {
+			Role barns.Role `json:"role"`
+		}
+		err = parseBody(c, &req, "member")
+		if err != nil {
+			return err
+		}
+		actor, err := membership(c, s, barnID, barns.ManageMembers)
+		if err != nil {
+			return err
+		}
+		member, err := s.barns.GetBarnOwnerByID(memberID)
+		if err != nil || member.BarnID != barnID {
+			return fiber.NewError(fiber.StatusNotFound, "member not found")
+		}
+		if !actor.Role.CanAssign(member.Role) || !actor.Role.CanAssign(req.Role) {
+			return fiber.NewError(fiber.StatusForbidden, "role "+string(actor.Role)+" cannot change "+string(member.Role)+" to "+string(req.Role))
+		}
+		member, err = barns.ChangeRole(barnID, memberID, req.Role, s.barns)
+		if err == barns.ErrLastOwner {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to change role", err)
+		}
+		return c.JSON(fiber.Map{
+			"member": member,
+		})
+	})
+
+	app.Delete("/barn/:barnID/members/:memberID", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		memberID, err := paramID(c, "memberID")
+		if err != nil {
+			return err
+		}
+		actor, err := membership(c, s, barnID, barns.ManageMembers)
+		if err != nil {
+			return err
+		}
+		member, err := s.barns.GetBarnOwnerByID(memberID)
+		if err != nil || member.BarnID != barnID {
+			return fiber.NewError(fiber.StatusNotFound, "member not found")
+		}
+		if !actor.Role.CanAssign(member.Role) {
+			return fiber.NewError(fiber.StatusForbidden, "role "+string(actor.Role)+" cannot remove a member with role "+string(member.Role))
+		}
+		members, err := barns.ListMembers(barnID, s.barns)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get members", err)
+		}
+		var userID int64
+		for _, m := range members {
+			if m.ID == memberID {
+				userID = m.UserID
+			}
+		}
+		err = barns.RemoveMember(barnID, memberID, s.barns)
+		if err == barns.ErrLastOwner {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to remove member", err)
+		}
+		err = rides.DeleteMemberInstructor(barnID, userID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to remove instructor", err)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	app.Post("/barn/:barnID/leave", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		err = barns.Leave(barnID, currentUser(c).ID, s.barns)
+		if err == barns.ErrLastOwner {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to leave barn", err)
+		}
+		err = rides.DeleteMemberInstructor(barnID, currentUser(c).ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to remove instructor", err)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	app.Post("/barn/:barnID/invitations", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		var invitation barns.Invitation
+		err = parseBody(c, &invitation, "invitation")
+		if err != nil {
+			return err
+		}
+		actor, err := membership(c, s, barnID, barns.ManageMembers)
+		if err != nil {
+			return err
+		}
+		if !actor.Role.CanAssign(invitation.Role) {
+			return fiber.NewError(fiber.StatusForbidden, "role "+string(actor.Role)+" cannot invite someone as "+string(invitation.Role))
+		}
+		invitation = barns.Invitation{
+			BarnID:    barnID,
+			Role:      invitation.Role,
+			Phone:     invitation.Phone,
+			Email:     invitation.Email,
+			InvitedBy: currentUser(c).ID,
+		}
+		err = invitation.Save(s.barns)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to create invitation", err)
+		}
+		return c.JSON(fiber.Map{
+			"invitation": invitation,
+		})
+	})
+
+	app.Get("/barn/:barnID/invitations", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ManageMembers)
+		if err != nil {
+			return err
+		}
+		invitations, err := barns.ListPendingInvitations(barnID, s.barns)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to list invitations", err)
+		}
+		return c.JSON(fiber.Map{
+			"invitations": invitations,
+		})
+	})
+
+	app.Delete("/barn/:barnID/invitations/:id", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ManageMembers)
+		if err != nil {
+			return err
+		}
+		err = barns.RevokeInvitation(barnID, id, s.barns)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to revoke invitation", err)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	app.Post("/invitations/accept", func(c *fiber.Ctx) error {
+		var req struct {
+			Code string `json:"code"`
+		}
+		err := parseBody(c, &req, "invitation code")
+		if err != nil {
+			return err
+		}
+		user := currentUser(c)
+		member, err := barns.AcceptInvitation(req.Code, user.ID, user.Phone, user.Email, s.barns)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to accept invitation", err)
+		}
+		return c.JSON(fiber.Map{
+			"member": member,
+		})
+	})
+}
@@ -0,0 +1,257 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed mysql/*.sql sqlite/*.sql
+var files embed.FS
+
+// Migration is one numbered schema change. Files are named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func MySQL() fs.FS {
+	sub, _ := fs.Sub(files, "mysql")
+	return sub
+}
+
+func SQLite() fs.FS {
+	sub, _ := fs.Sub(files, "sqlite")
+	return sub
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, errors.New("failed to list migration files: " + err.Error())
+	}
+	byVersion := map[int64]*Migration{}
+	for _, name := range names {
+		base := path.Base(name)
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, errors.New("migration file must end in .up.sql or .down.sql: " + base)
+		}
+		stem := strings.TrimSuffix(base, "."+direction+".sql")
+		parts := strings.SplitN(stem, "_", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("migration file must be named <version>_<name>: " + base)
+		}
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, errors.New("failed to parse migration version of " + base + ": " + err.Error())
+		}
+		contents, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, errors.New("failed to read migration " + base + ": " + err.Error())
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		} else if m.Name != parts[1] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, parts[1])
+		}
+		if direction == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func (m *Migrator) ensureVersionTable() error {
+	query := "create table if not exists schema_migrations (version bigint not null primary key, name varchar(255) not null, applied_at datetime not null)"
+	_, err := m.db.Exec(query)
+	if err != nil {
+		return errors.New("failed to create schema_migrations table: " + err.Error())
+	}
+	return nil
+}
+
+func (m *Migrator) applied() (map[int64]time.Time, error) {
+	err := m.ensureVersionTable()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := m.db.Query("select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, errors.New("failed to select applied migrations: " + err.Error())
+	}
+	defer rows.Close()
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		err := rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, errors.New("failed to scan row: " + err.Error())
+		}
+		applied[version] = appliedAt
+	}
+	return applied, nil
+}
+
+// Up applies every migration that has not been applied yet, oldest first,
+// and returns the ones it ran.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		query := "insert into schema_migrations (version, name, applied_at) values (?, ?, ?)"
+		err := m.run(migration.Up, query, migration.Version, migration.Name, time.Now().UTC())
+		if err != nil {
+			return ran, fmt.Errorf("failed to apply migration %d_%s: %s", migration.Version, migration.Name, err.Error())
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// Down rolls back the most recently applied migrations, newest first.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(ran) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return ran, fmt.Errorf("migration %d_%s cannot be rolled back: no down file", migration.Version, migration.Name)
+		}
+		err := m.run(migration.Down, "delete from schema_migrations where version = ?", migration.Version)
+		if err != nil {
+			return ran, fmt.Errorf("failed to roll back migration %d_%s: %s", migration.Version, migration.Name, err.Error())
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var statuses []Status
+	for _, migration := range m.migrations {
+		s := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// run executes a migration file statement by statement, since the MySQL
+// driver rejects multi-statement queries unless the DSN opts in, then runs
+// record with args to note the change in schema_migrations, all in one
+// transaction. On SQLite a failure leaves neither the schema nor
+// schema_migrations changed. MySQL commits DDL as it runs it, so a failed
+// MySQL migration keeps the statements before the one that failed, goes
+// unrecorded and has to be cleaned up by hand before it is run again.
+func (m *Migrator) run(script string, record string, args ...interface{}) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return errors.New("failed to begin transaction: " + err.Error())
+	}
+	for _, statement := range splitStatements(script) {
+		_, err := tx.Exec(statement)
+		if err != nil {
+			tx.Rollback()
+			return errors.New(err.Error() + " in: " + statement)
+		}
+	}
+	_, err = tx.Exec(record, args...)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("failed to record migration: " + err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to commit transaction: " + err.Error())
+	}
+	return nil
+}
+
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		statements = append(statements, strings.TrimSpace(current.String()))
+	}
+	return statements
+}
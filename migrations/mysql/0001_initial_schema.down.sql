@@ -0,0 +1,9 @@
+drop table if exists schedules;
+drop table if exists rides;
+drop table if exists event_types;
+drop table if exists riders;
+drop table if exists horses;
+drop table if exists barn_owners;
+drop table if exists barns;
+drop table if exists owners;
+drop table if exists users;
@@ -0,0 +1,96 @@
+create table if not exists users (
+    id bigint not null auto_increment primary key,
+    name varchar(255) not null default '',
+    email varchar(255) not null default '',
+    phone varchar(32) not null,
+    stytch_user_id varchar(255) not null,
+    stytch_method_id varchar(255) null,
+    unique key users_phone (phone),
+    unique key users_stytch_user_id (stytch_user_id)
+);
+
+create table if not exists owners (
+    id bigint not null auto_increment primary key,
+    name varchar(255) null,
+    user_id bigint not null,
+    unique key owners_user_id (user_id),
+    constraint owners_user_fk foreign key (user_id) references users (id)
+);
+
+create table if not exists barns (
+    id bigint not null auto_increment primary key,
+    name varchar(255) not null
+);
+
+create table if not exists barn_owners (
+    id bigint not null auto_increment primary key,
+    barn_id bigint not null,
+    owner_id bigint not null,
+    is_primary_barn boolean not null default false,
+    unique key barn_owners_barn_owner (barn_id, owner_id),
+    constraint barn_owners_barn_fk foreign key (barn_id) references barns (id) on delete cascade,
+    constraint barn_owners_owner_fk foreign key (owner_id) references owners (id) on delete cascade
+);
+
+create table if not exists horses (
+    id bigint not null auto_increment primary key,
+    name varchar(255) not null,
+    dob date not null,
+    gender enum('mare', 'gelding', 'stallion') not null,
+    barn_id bigint not null,
+    key horses_barn_id (barn_id),
+    constraint horses_barn_fk foreign key (barn_id) references barns (id)
+);
+
+create table if not exists riders (
+    id bigint not null auto_increment primary key,
+    name varchar(255) not null,
+    barn_id bigint not null,
+    key riders_barn_id (barn_id),
+    constraint riders_barn_fk foreign key (barn_id) references barns (id)
+);
+
+create table if not exists event_types (
+    id bigint not null auto_increment primary key,
+    name varchar(255) not null
+);
+
+-- rides.Ride.Save falls back to event type 10 when none is given
+insert ignore into event_types (id, name) values (10, 'Generic');
+
+create table if not exists rides (
+    id bigint not null auto_increment primary key,
+    horse_id bigint not null,
+    rider_id bigint not null,
+    event_type_id bigint not null,
+    date date not null,
+    time time null,
+    notes text null,
+    status enum('scheduled', 'cancelled', 'completed') not null default 'scheduled',
+    key rides_date (date),
+    key rides_horse_date (horse_id, date),
+    constraint rides_horse_fk foreign key (horse_id) references horses (id),
+    constraint rides_rider_fk foreign key (rider_id) references riders (id),
+    constraint rides_event_type_fk foreign key (event_type_id) references event_types (id)
+);
+
+create table if not exists schedules (
+    id bigint not null auto_increment primary key,
+    horse_id bigint not null,
+    rider_id bigint not null,
+    event_type_id bigint not null,
+    start_date date not null,
+    end_date date null,
+    time time null,
+    sunday boolean not null default false,
+    monday boolean not null default false,
+    tuesday boolean not null default false,
+    wednesday boolean not null default false,
+    thursday boolean not null default false,
+    friday boolean not null default false,
+    saturday boolean not null default false,
+    key schedules_horse_id (horse_id),
+    constraint schedules_horse_fk foreign key (horse_id) references horses (id),
+    constraint schedules_rider_fk foreign key (rider_id) references riders (id),
+    constraint schedules_event_type_fk foreign key (event_type_id) references event_types (id)
+);
@@ -0,0 +1,29 @@
+alter table users rename column stytch_user_id to auth_user_id;
+alter table users rename column stytch_method_id to auth_method_id;
+alter table users rename index users_stytch_user_id to users_auth_user_id;
+
+create table local_auth_identities (
+    id bigint not null auto_increment primary key,
+    phone varchar(32) not null,
+    created_at datetime not null,
+    unique key local_auth_identities_phone (phone)
+);
+
+create table local_auth_otps (
+    method_id varchar(64) not null primary key,
+    identity_id bigint not null,
+    code_hash char(64) not null,
+    expires_at datetime not null,
+    attempts int not null default 0,
+    used_at datetime null,
+    constraint local_auth_otps_identity_fk foreign key (identity_id) references local_auth_identities (id) on delete cascade
+);
+
+create table local_auth_sessions (
+    id varchar(64) not null primary key,
+    identity_id bigint not null,
+    created_at datetime not null,
+    expires_at datetime not null,
+    revoked_at datetime null,
+    constraint local_auth_sessions_identity_fk foreign key (identity_id) references local_auth_identities (id) on delete cascade
+);
@@ -0,0 +1,22 @@
+-- everyone linked to a barn before roles existed owns it
+alter table barn_owners add column role varchar(16) not null default 'owner';
+
+create table barn_invitations (
+    id bigint not null auto_increment primary key,
+    barn_id bigint not null,
+    role varchar(16) not null,
+    phone varchar(32) null,
+    email varchar(255) null,
+    code_hash char(64) not null,
+    invited_by bigint not null,
+    created_at datetime not null,
+    expires_at datetime not null,
+    accepted_at datetime null,
+    accepted_by bigint null,
+    revoked_at datetime null,
+    unique key barn_invitations_code_hash (code_hash),
+    key barn_invitations_barn_id (barn_id),
+    constraint barn_invitations_barn_fk foreign key (barn_id) references barns (id) on delete cascade,
+    constraint barn_invitations_invited_by_fk foreign key (invited_by) references users (id),
+    constraint barn_invitations_accepted_by_fk foreign key (accepted_by) references users (id)
+);
@@ -0,0 +1,19 @@
+-- weekday flags become an RFC 5545 rule; a schedule with no day set never
+-- occurred, so it gets a rule that ends before it starts
+alter table schedules add column rrule varchar(255) not null default '';
+
+update schedules set rrule = case
+    when sunday or monday or tuesday or wednesday or thursday or friday or saturday then concat('FREQ=WEEKLY;BYDAY=', concat_ws(',',
+        if(sunday, 'SU', null), if(monday, 'MO', null), if(tuesday, 'TU', null), if(wednesday, 'WE', null),
+        if(thursday, 'TH', null), if(friday, 'FR', null), if(saturday, 'SA', null)))
+    else concat('FREQ=WEEKLY;UNTIL=', date_format(start_date - interval 1 day, '%Y%m%d'))
+end;
+
+alter table schedules
+    drop column sunday,
+    drop column monday,
+    drop column tuesday,
+    drop column wednesday,
+    drop column thursday,
+    drop column friday,
+    drop column saturday;
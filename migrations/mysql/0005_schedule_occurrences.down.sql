@@ -0,0 +1,5 @@
+alter table rides drop foreign key rides_schedule_fk;
+alter table rides
+    drop index rides_occurrence,
+    drop column occurrence_date,
+    drop column schedule_id;
@@ -0,0 +1,7 @@
+-- a ride linked to a schedule occurrence replaces that occurrence: it is how
+-- one date of a schedule gets cancelled, moved or otherwise edited
+alter table rides
+    add column schedule_id bigint null,
+    add column occurrence_date date null,
+    add unique key rides_occurrence (schedule_id, occurrence_date),
+    add constraint rides_schedule_fk foreign key (schedule_id) references schedules (id) on delete set null;
@@ -0,0 +1,3 @@
+alter table schedules drop column duration_minutes;
+alter table rides drop column duration_minutes;
+alter table event_types drop column duration_minutes;
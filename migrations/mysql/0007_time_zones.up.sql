@@ -0,0 +1,6 @@
+-- dates and times stay the barn's wall clock; starts_at pins a timed ride to
+-- an instant in UTC. Existing barns are taken to be on UTC.
+alter table barns add column time_zone varchar(64) not null default 'UTC';
+alter table rides add column starts_at datetime null;
+
+update rides set starts_at = timestamp(date, time) where time is not null;
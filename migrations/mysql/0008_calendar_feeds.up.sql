@@ -0,0 +1,18 @@
+create table calendar_feeds (
+    id bigint not null auto_increment primary key,
+    barn_id bigint not null,
+    scope varchar(16) not null,
+    horse_id bigint null,
+    rider_id bigint null,
+    name varchar(255) not null,
+    token_hash char(64) not null,
+    created_by bigint not null,
+    created_at datetime not null,
+    revoked_at datetime null,
+    unique key calendar_feeds_token_hash (token_hash),
+    key calendar_feeds_barn_id (barn_id),
+    constraint calendar_feeds_barn_fk foreign key (barn_id) references barns (id) on delete cascade,
+    constraint calendar_feeds_horse_fk foreign key (horse_id) references horses (id) on delete cascade,
+    constraint calendar_feeds_rider_fk foreign key (rider_id) references riders (id) on delete cascade,
+    constraint calendar_feeds_created_by_fk foreign key (created_by) references users (id)
+);
@@ -0,0 +1,11 @@
+drop table if exists ride_status_changes;
+
+alter table rides drop column behavior_rating;
+alter table rides drop column trainer_notes;
+alter table rides drop column intensity;
+alter table rides drop column actual_minutes;
+
+-- the statuses that go away fall back to the nearest one that stays
+update rides set status = 'scheduled' where status = 'checked_in';
+update rides set status = 'cancelled' where status = 'no_show';
+alter table rides modify status enum('scheduled', 'cancelled', 'completed') not null default 'scheduled';
@@ -0,0 +1,19 @@
+-- rides are checked in before they are completed or marked a no-show, and
+-- every status change is kept with who made it
+alter table rides modify status enum('scheduled', 'checked_in', 'completed', 'no_show', 'cancelled') not null default 'scheduled';
+alter table rides add column actual_minutes int null;
+alter table rides add column intensity varchar(16) null;
+alter table rides add column trainer_notes text null;
+alter table rides add column behavior_rating int null;
+
+create table ride_status_changes (
+    id bigint not null auto_increment primary key,
+    ride_id bigint not null,
+    from_status varchar(16) not null,
+    to_status varchar(16) not null,
+    actor_id bigint not null,
+    changed_at datetime not null,
+    key ride_status_changes_ride_id (ride_id),
+    constraint ride_status_changes_ride_fk foreign key (ride_id) references rides (id) on delete cascade,
+    constraint ride_status_changes_actor_fk foreign key (actor_id) references users (id)
+);
@@ -0,0 +1,15 @@
+create table health_records (
+    id bigint not null auto_increment primary key,
+    horse_id bigint not null,
+    kind enum('vaccination', 'coggins', 'vet_exam', 'farrier', 'dental', 'deworming') not null,
+    name varchar(255) null,
+    date date not null,
+    provider varchar(255) null,
+    notes text null,
+    next_due date null,
+    created_by bigint not null,
+    created_at datetime not null,
+    key health_records_horse_id (horse_id, date),
+    constraint health_records_horse_fk foreign key (horse_id) references horses (id) on delete cascade,
+    constraint health_records_created_by_fk foreign key (created_by) references users (id)
+);
@@ -0,0 +1,11 @@
+-- one rule per horse; a null limit is no limit
+create table workload_rules (
+    horse_id bigint not null primary key,
+    max_rides_per_day int null,
+    max_minutes_per_day int null,
+    max_minutes_per_week int null,
+    rest_days varchar(64) null,
+    min_gap_minutes int null,
+    enforcement enum('warn', 'block') not null default 'warn',
+    constraint workload_rules_horse_fk foreign key (horse_id) references horses (id) on delete cascade
+);
@@ -0,0 +1,13 @@
+create table horse_unavailability (
+    id bigint not null auto_increment primary key,
+    horse_id bigint not null,
+    reason enum('lameness', 'layup', 'off_site', 'other') not null,
+    notes text null,
+    start_date date not null,
+    end_date date null,
+    created_by bigint not null,
+    created_at datetime not null,
+    key horse_unavailability_horse_id (horse_id, start_date),
+    constraint horse_unavailability_horse_fk foreign key (horse_id) references horses (id) on delete cascade,
+    constraint horse_unavailability_created_by_fk foreign key (created_by) references users (id)
+);
@@ -0,0 +1,21 @@
+-- user_id is the account of the person who rides; emergency_contacts is a
+-- JSON list
+alter table riders add column user_id bigint null;
+alter table riders add column skill_level enum('beginner', 'novice', 'intermediate', 'advanced', 'professional') null;
+alter table riders add column height_inches int null;
+alter table riders add column weight_pounds int null;
+alter table riders add column dob date null;
+alter table riders add column emergency_contacts text null;
+alter table riders add column medical_notes text null;
+alter table riders add constraint riders_user_fk foreign key (user_id) references users (id) on delete set null;
+
+create table rider_guardians (
+    rider_id bigint not null,
+    user_id bigint not null,
+    relationship varchar(64) null,
+    created_at datetime not null,
+    primary key (rider_id, user_id),
+    key rider_guardians_user_id (user_id),
+    constraint rider_guardians_rider_fk foreign key (rider_id) references riders (id) on delete cascade,
+    constraint rider_guardians_user_fk foreign key (user_id) references users (id) on delete cascade
+);
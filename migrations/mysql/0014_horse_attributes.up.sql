@@ -0,0 +1,5 @@
+-- disciplines is a comma separated list
+alter table horses add column temperament enum('calm', 'steady', 'spirited') null;
+alter table horses add column min_skill_level enum('beginner', 'novice', 'intermediate', 'advanced', 'professional') null;
+alter table horses add column max_rider_weight_pounds int null;
+alter table horses add column disciplines varchar(255) null;
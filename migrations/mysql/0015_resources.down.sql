@@ -0,0 +1,8 @@
+alter table schedules drop foreign key schedules_resource_fk;
+alter table schedules drop key schedules_resource_id;
+alter table schedules drop column resource_id;
+alter table rides drop foreign key rides_resource_fk;
+alter table rides drop key rides_resource_id;
+alter table rides drop column resource_id;
+
+drop table if exists resources;
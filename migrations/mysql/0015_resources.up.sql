@@ -0,0 +1,16 @@
+create table resources (
+    id bigint not null auto_increment primary key,
+    barn_id bigint not null,
+    name varchar(255) not null,
+    kind enum('arena', 'round_pen', 'trail', 'other') not null,
+    capacity int not null,
+    key resources_barn_id (barn_id),
+    constraint resources_barn_fk foreign key (barn_id) references barns (id) on delete cascade
+);
+
+alter table rides add column resource_id bigint null;
+alter table rides add key rides_resource_id (resource_id, date);
+alter table rides add constraint rides_resource_fk foreign key (resource_id) references resources (id) on delete set null;
+alter table schedules add column resource_id bigint null;
+alter table schedules add key schedules_resource_id (resource_id);
+alter table schedules add constraint schedules_resource_fk foreign key (resource_id) references resources (id) on delete set null;
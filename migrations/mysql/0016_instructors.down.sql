@@ -0,0 +1,9 @@
+alter table schedules drop foreign key schedules_instructor_fk;
+alter table schedules drop key schedules_instructor_id;
+alter table schedules drop column instructor_id;
+alter table rides drop foreign key rides_instructor_fk;
+alter table rides drop key rides_instructor_id;
+alter table rides drop column instructor_id;
+
+drop table if exists instructor_event_types;
+drop table if exists instructors;
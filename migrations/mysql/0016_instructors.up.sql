@@ -0,0 +1,27 @@
+-- an event type has at most one instructor leading it in each barn
+create table instructors (
+    id bigint not null auto_increment primary key,
+    barn_id bigint not null,
+    user_id bigint not null,
+    unique key instructors_barn_user (barn_id, user_id),
+    constraint instructors_barn_fk foreign key (barn_id) references barns (id) on delete cascade,
+    constraint instructors_user_fk foreign key (user_id) references users (id) on delete cascade
+);
+
+create table instructor_event_types (
+    instructor_id bigint not null,
+    event_type_id bigint not null,
+    barn_id bigint not null,
+    primary key (instructor_id, event_type_id),
+    unique key instructor_event_types_barn_event_type (barn_id, event_type_id),
+    constraint instructor_event_types_instructor_fk foreign key (instructor_id) references instructors (id) on delete cascade,
+    constraint instructor_event_types_event_type_fk foreign key (event_type_id) references event_types (id) on delete cascade,
+    constraint instructor_event_types_barn_fk foreign key (barn_id) references barns (id) on delete cascade
+);
+
+alter table rides add column instructor_id bigint null;
+alter table rides add key rides_instructor_id (instructor_id, date);
+alter table rides add constraint rides_instructor_fk foreign key (instructor_id) references instructors (id) on delete set null;
+alter table schedules add column instructor_id bigint null;
+alter table schedules add key schedules_instructor_id (instructor_id);
+alter table schedules add constraint schedules_instructor_fk foreign key (instructor_id) references instructors (id) on delete set null;
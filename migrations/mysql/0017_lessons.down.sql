@@ -0,0 +1,5 @@
+alter table rides drop foreign key rides_lesson_fk;
+alter table rides drop key rides_lesson_id;
+alter table rides drop column lesson_id;
+
+drop table if exists lessons;
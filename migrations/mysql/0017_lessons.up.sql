@@ -0,0 +1,23 @@
+-- a group lesson's roster is the rides that point at it
+create table lessons (
+    id bigint not null auto_increment primary key,
+    barn_id bigint not null,
+    event_type_id bigint not null,
+    date date not null,
+    time time not null,
+    duration_minutes int null,
+    instructor_id bigint null,
+    resource_id bigint null,
+    max_riders int not null,
+    notes text null,
+    status enum('scheduled', 'cancelled') not null default 'scheduled',
+    key lessons_barn_date (barn_id, date),
+    constraint lessons_barn_fk foreign key (barn_id) references barns (id) on delete cascade,
+    constraint lessons_event_type_fk foreign key (event_type_id) references event_types (id),
+    constraint lessons_instructor_fk foreign key (instructor_id) references instructors (id) on delete set null,
+    constraint lessons_resource_fk foreign key (resource_id) references resources (id) on delete set null
+);
+
+alter table rides add column lesson_id bigint null;
+alter table rides add key rides_lesson_id (lesson_id);
+alter table rides add constraint rides_lesson_fk foreign key (lesson_id) references lessons (id) on delete set null;
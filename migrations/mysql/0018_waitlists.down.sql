@@ -0,0 +1,3 @@
+drop table if exists lesson_waitlist;
+
+alter table lessons drop column hold_minutes;
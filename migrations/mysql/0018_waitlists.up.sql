@@ -0,0 +1,21 @@
+-- an offered spot is held for the rider until expires_at; hold_minutes of
+-- null means the default hold
+alter table lessons add column hold_minutes int null;
+
+create table lesson_waitlist (
+    id bigint not null auto_increment primary key,
+    lesson_id bigint not null,
+    horse_id bigint not null,
+    rider_id bigint not null,
+    notes text null,
+    status enum('waiting', 'offered', 'confirmed', 'declined', 'expired', 'removed') not null default 'waiting',
+    created_at datetime not null,
+    offered_at datetime null,
+    expires_at datetime null,
+    ride_id bigint null,
+    key lesson_waitlist_lesson_id (lesson_id),
+    constraint lesson_waitlist_lesson_fk foreign key (lesson_id) references lessons (id) on delete cascade,
+    constraint lesson_waitlist_horse_fk foreign key (horse_id) references horses (id) on delete cascade,
+    constraint lesson_waitlist_rider_fk foreign key (rider_id) references riders (id) on delete cascade,
+    constraint lesson_waitlist_ride_fk foreign key (ride_id) references rides (id) on delete set null
+);
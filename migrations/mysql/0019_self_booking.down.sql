@@ -0,0 +1,3 @@
+drop table if exists booking_policies;
+drop table if exists availability_template_event_types;
+drop table if exists availability_templates;
@@ -0,0 +1,37 @@
+-- the windows a barn opens for riders to book themselves, cut into slots
+create table availability_templates (
+    id bigint not null auto_increment primary key,
+    barn_id bigint not null,
+    name varchar(255) not null,
+    instructor_id bigint null,
+    resource_id bigint null,
+    rrule varchar(255) not null,
+    start_date date not null,
+    end_date date null,
+    start_time time not null,
+    end_time time not null,
+    slot_minutes int not null,
+    key availability_templates_barn_id (barn_id),
+    constraint availability_templates_barn_fk foreign key (barn_id) references barns (id) on delete cascade,
+    constraint availability_templates_instructor_fk foreign key (instructor_id) references instructors (id) on delete set null,
+    constraint availability_templates_resource_fk foreign key (resource_id) references resources (id) on delete set null
+);
+
+create table availability_template_event_types (
+    template_id bigint not null,
+    event_type_id bigint not null,
+    primary key (template_id, event_type_id),
+    constraint availability_template_event_types_template_fk foreign key (template_id) references availability_templates (id) on delete cascade,
+    constraint availability_template_event_types_event_type_fk foreign key (event_type_id) references event_types (id) on delete cascade
+);
+
+-- one policy per barn; a null limit is no limit
+create table booking_policies (
+    barn_id bigint not null primary key,
+    min_notice_hours int null,
+    max_advance_days int null,
+    max_rides_per_day int null,
+    max_rides_per_week int null,
+    cancel_notice_hours int null,
+    constraint booking_policies_barn_fk foreign key (barn_id) references barns (id) on delete cascade
+);
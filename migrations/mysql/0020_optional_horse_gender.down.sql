@@ -0,0 +1,2 @@
+-- fails while a horse has no gender
+alter table horses modify gender enum('mare', 'gelding', 'stallion') not null;
@@ -0,0 +1,2 @@
+-- a horse's gender can be left unset
+alter table horses modify gender enum('mare', 'gelding', 'stallion') null;
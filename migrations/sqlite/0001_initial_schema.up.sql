@@ -0,0 +1,86 @@
+create table if not exists users (
+    id integer primary key autoincrement,
+    name text not null default '',
+    email text not null default '',
+    phone text not null unique,
+    stytch_user_id text not null unique,
+    stytch_method_id text null
+);
+
+create table if not exists owners (
+    id integer primary key autoincrement,
+    name text null,
+    user_id integer not null unique references users (id)
+);
+
+create table if not exists barns (
+    id integer primary key autoincrement,
+    name text not null
+);
+
+create table if not exists barn_owners (
+    id integer primary key autoincrement,
+    barn_id integer not null references barns (id) on delete cascade,
+    owner_id integer not null references owners (id) on delete cascade,
+    is_primary_barn boolean not null default false,
+    unique (barn_id, owner_id)
+);
+
+create table if not exists horses (
+    id integer primary key autoincrement,
+    name text not null,
+    dob date not null,
+    gender text not null check (gender in ('mare', 'gelding', 'stallion')),
+    barn_id integer not null references barns (id)
+);
+
+create index if not exists horses_barn_id on horses (barn_id);
+
+create table if not exists riders (
+    id integer primary key autoincrement,
+    name text not null,
+    barn_id integer not null references barns (id)
+);
+
+create index if not exists riders_barn_id on riders (barn_id);
+
+create table if not exists event_types (
+    id integer primary key autoincrement,
+    name text not null
+);
+
+-- rides.Ride.Save falls back to event type 10 when none is given
+insert or ignore into event_types (id, name) values (10, 'Generic');
+
+create table if not exists rides (
+    id integer primary key autoincrement,
+    horse_id integer not null references horses (id),
+    rider_id integer not null references riders (id),
+    event_type_id integer not null references event_types (id),
+    date date not null,
+    time time null,
+    notes text null,
+    status text not null default 'scheduled' check (status in ('scheduled', 'cancelled', 'completed'))
+);
+
+create index if not exists rides_date on rides (date);
+create index if not exists rides_horse_date on rides (horse_id, date);
+
+create table if not exists schedules (
+    id integer primary key autoincrement,
+    horse_id integer not null references horses (id),
+    rider_id integer not null references riders (id),
+    event_type_id integer not null references event_types (id),
+    start_date date not null,
+    end_date date null,
+    time time null,
+    sunday boolean not null default false,
+    monday boolean not null default false,
+    tuesday boolean not null default false,
+    wednesday boolean not null default false,
+    thursday boolean not null default false,
+    friday boolean not null default false,
+    saturday boolean not null default false
+);
+
+create index if not exists schedules_horse_id on schedules (horse_id);
@@ -0,0 +1,6 @@
+drop table if exists local_auth_sessions;
+drop table if exists local_auth_otps;
+drop table if exists local_auth_identities;
+
+alter table users rename column auth_method_id to stytch_method_id;
+alter table users rename column auth_user_id to stytch_user_id;
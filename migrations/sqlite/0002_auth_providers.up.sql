@@ -0,0 +1,25 @@
+alter table users rename column stytch_user_id to auth_user_id;
+alter table users rename column stytch_method_id to auth_method_id;
+
+create table local_auth_identities (
+    id integer primary key autoincrement,
+    phone text not null unique,
+    created_at datetime not null
+);
+
+create table local_auth_otps (
+    method_id text primary key,
+    identity_id integer not null references local_auth_identities (id) on delete cascade,
+    code_hash text not null,
+    expires_at datetime not null,
+    attempts integer not null default 0,
+    used_at datetime null
+);
+
+create table local_auth_sessions (
+    id text primary key,
+    identity_id integer not null references local_auth_identities (id) on delete cascade,
+    created_at datetime not null,
+    expires_at datetime not null,
+    revoked_at datetime null
+);
@@ -0,0 +1,2 @@
+drop table if exists barn_invitations;
+alter table barn_owners drop column role;
@@ -0,0 +1,19 @@
+-- everyone linked to a barn before roles existed owns it
+alter table barn_owners add column role text not null default 'owner';
+
+create table barn_invitations (
+    id integer primary key autoincrement,
+    barn_id integer not null references barns (id) on delete cascade,
+    role text not null,
+    phone text null,
+    email text null,
+    code_hash text not null unique,
+    invited_by integer not null references users (id),
+    created_at datetime not null,
+    expires_at datetime not null,
+    accepted_at datetime null,
+    accepted_by integer null references users (id),
+    revoked_at datetime null
+);
+
+create index barn_invitations_barn_id on barn_invitations (barn_id);
@@ -0,0 +1,20 @@
+-- only the weekdays of weekly rules survive the trip back
+alter table schedules add column sunday boolean not null default false;
+alter table schedules add column monday boolean not null default false;
+alter table schedules add column tuesday boolean not null default false;
+alter table schedules add column wednesday boolean not null default false;
+alter table schedules add column thursday boolean not null default false;
+alter table schedules add column friday boolean not null default false;
+alter table schedules add column saturday boolean not null default false;
+
+update schedules set
+    sunday = rrule like '%BYDAY=%SU%',
+    monday = rrule like '%BYDAY=%MO%',
+    tuesday = rrule like '%BYDAY=%TU%',
+    wednesday = rrule like '%BYDAY=%WE%',
+    thursday = rrule like '%BYDAY=%TH%',
+    friday = rrule like '%BYDAY=%FR%',
+    saturday = rrule like '%BYDAY=%SA%'
+where rrule like 'FREQ=WEEKLY%';
+
+alter table schedules drop column rrule;
@@ -0,0 +1,20 @@
+-- weekday flags become an RFC 5545 rule; a schedule with no day set never
+-- occurred, so it gets a rule that ends before it starts
+alter table schedules add column rrule text not null default '';
+
+update schedules set rrule = case
+    when sunday or monday or tuesday or wednesday or thursday or friday or saturday then 'FREQ=WEEKLY;BYDAY=' || rtrim(
+        (case when sunday then 'SU,' else '' end) || (case when monday then 'MO,' else '' end) ||
+        (case when tuesday then 'TU,' else '' end) || (case when wednesday then 'WE,' else '' end) ||
+        (case when thursday then 'TH,' else '' end) || (case when friday then 'FR,' else '' end) ||
+        (case when saturday then 'SA,' else '' end), ',')
+    else 'FREQ=WEEKLY;UNTIL=' || strftime('%Y%m%d', start_date, '-1 day')
+end;
+
+alter table schedules drop column sunday;
+alter table schedules drop column monday;
+alter table schedules drop column tuesday;
+alter table schedules drop column wednesday;
+alter table schedules drop column thursday;
+alter table schedules drop column friday;
+alter table schedules drop column saturday;
@@ -0,0 +1,3 @@
+drop index if exists rides_occurrence;
+alter table rides drop column occurrence_date;
+alter table rides drop column schedule_id;
@@ -0,0 +1,6 @@
+-- a ride linked to a schedule occurrence replaces that occurrence: it is how
+-- one date of a schedule gets cancelled, moved or otherwise edited
+alter table rides add column schedule_id integer null references schedules (id) on delete set null;
+alter table rides add column occurrence_date date null;
+
+create unique index if not exists rides_occurrence on rides (schedule_id, occurrence_date);
@@ -0,0 +1,5 @@
+-- rides and schedules without a duration of their own take their event
+-- type's
+alter table event_types add column duration_minutes integer not null default 60;
+alter table rides add column duration_minutes integer null;
+alter table schedules add column duration_minutes integer null;
@@ -0,0 +1,2 @@
+alter table rides drop column starts_at;
+alter table barns drop column time_zone;
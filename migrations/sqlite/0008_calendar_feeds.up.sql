@@ -0,0 +1,14 @@
+create table calendar_feeds (
+    id integer primary key autoincrement,
+    barn_id integer not null references barns (id) on delete cascade,
+    scope text not null,
+    horse_id integer null references horses (id) on delete cascade,
+    rider_id integer null references riders (id) on delete cascade,
+    name text not null,
+    token_hash text not null unique,
+    created_by integer not null references users (id),
+    created_at datetime not null,
+    revoked_at datetime null
+);
+
+create index calendar_feeds_barn_id on calendar_feeds (barn_id);
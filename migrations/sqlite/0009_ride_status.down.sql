@@ -0,0 +1,27 @@
+drop table if exists ride_status_changes;
+
+-- the statuses that go away fall back to the nearest one that stays
+create table rides_old (
+    id integer primary key autoincrement,
+    horse_id integer not null references horses (id),
+    rider_id integer not null references riders (id),
+    event_type_id integer not null references event_types (id),
+    date date not null,
+    time time null,
+    notes text null,
+    status text not null default 'scheduled' check (status in ('scheduled', 'cancelled', 'completed')),
+    schedule_id integer null references schedules (id) on delete set null,
+    occurrence_date date null,
+    duration_minutes integer null,
+    starts_at datetime null
+);
+
+insert into rides_old (id, horse_id, rider_id, event_type_id, date, time, notes, status, schedule_id, occurrence_date, duration_minutes, starts_at)
+select id, horse_id, rider_id, event_type_id, date, time, notes, case status when 'checked_in' then 'scheduled' when 'no_show' then 'cancelled' else status end, schedule_id, occurrence_date, duration_minutes, starts_at from rides;
+
+drop table rides;
+alter table rides_old rename to rides;
+
+create index rides_date on rides (date);
+create index rides_horse_date on rides (horse_id, date);
+create unique index rides_occurrence on rides (schedule_id, occurrence_date);
@@ -0,0 +1,42 @@
+-- rides are checked in before they are completed or marked a no-show, and
+-- every status change is kept with who made it. SQLite cannot change a check
+-- constraint, so the rides table is rebuilt.
+create table rides_new (
+    id integer primary key autoincrement,
+    horse_id integer not null references horses (id),
+    rider_id integer not null references riders (id),
+    event_type_id integer not null references event_types (id),
+    date date not null,
+    time time null,
+    notes text null,
+    status text not null default 'scheduled' check (status in ('scheduled', 'checked_in', 'completed', 'no_show', 'cancelled')),
+    schedule_id integer null references schedules (id) on delete set null,
+    occurrence_date date null,
+    duration_minutes integer null,
+    starts_at datetime null,
+    actual_minutes integer null,
+    intensity text null,
+    trainer_notes text null,
+    behavior_rating integer null
+);
+
+insert into rides_new (id, horse_id, rider_id, event_type_id, date, time, notes, status, schedule_id, occurrence_date, duration_minutes, starts_at)
+select id, horse_id, rider_id, event_type_id, date, time, notes, status, schedule_id, occurrence_date, duration_minutes, starts_at from rides;
+
+drop table rides;
+alter table rides_new rename to rides;
+
+create index rides_date on rides (date);
+create index rides_horse_date on rides (horse_id, date);
+create unique index rides_occurrence on rides (schedule_id, occurrence_date);
+
+create table ride_status_changes (
+    id integer primary key autoincrement,
+    ride_id integer not null references rides (id) on delete cascade,
+    from_status text not null,
+    to_status text not null,
+    actor_id integer not null references users (id),
+    changed_at datetime not null
+);
+
+create index ride_status_changes_ride_id on ride_status_changes (ride_id);
@@ -0,0 +1,14 @@
+create table health_records (
+    id integer primary key autoincrement,
+    horse_id integer not null references horses (id) on delete cascade,
+    kind text not null check (kind in ('vaccination', 'coggins', 'vet_exam', 'farrier', 'dental', 'deworming')),
+    name text null,
+    date date not null,
+    provider text null,
+    notes text null,
+    next_due date null,
+    created_by integer not null references users (id),
+    created_at datetime not null
+);
+
+create index health_records_horse_id on health_records (horse_id, date);
@@ -0,0 +1,10 @@
+-- one rule per horse; a null limit is no limit
+create table workload_rules (
+    horse_id integer primary key references horses (id) on delete cascade,
+    max_rides_per_day integer null,
+    max_minutes_per_day integer null,
+    max_minutes_per_week integer null,
+    rest_days text null,
+    min_gap_minutes integer null,
+    enforcement text not null default 'warn' check (enforcement in ('warn', 'block'))
+);
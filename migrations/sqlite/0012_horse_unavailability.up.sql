@@ -0,0 +1,12 @@
+create table horse_unavailability (
+    id integer primary key autoincrement,
+    horse_id integer not null references horses (id) on delete cascade,
+    reason text not null check (reason in ('lameness', 'layup', 'off_site', 'other')),
+    notes text null,
+    start_date date not null,
+    end_date date null,
+    created_by integer not null references users (id),
+    created_at datetime not null
+);
+
+create index horse_unavailability_horse_id on horse_unavailability (horse_id, start_date);
@@ -0,0 +1,10 @@
+drop table if exists rider_guardians;
+
+drop index if exists riders_user_id;
+alter table riders drop column medical_notes;
+alter table riders drop column emergency_contacts;
+alter table riders drop column dob;
+alter table riders drop column weight_pounds;
+alter table riders drop column height_inches;
+alter table riders drop column skill_level;
+alter table riders drop column user_id;
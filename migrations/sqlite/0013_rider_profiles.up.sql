@@ -0,0 +1,21 @@
+-- user_id is the account of the person who rides; emergency_contacts is a
+-- JSON list
+alter table riders add column user_id integer null references users (id) on delete set null;
+alter table riders add column skill_level text null check (skill_level in ('beginner', 'novice', 'intermediate', 'advanced', 'professional'));
+alter table riders add column height_inches integer null;
+alter table riders add column weight_pounds integer null;
+alter table riders add column dob date null;
+alter table riders add column emergency_contacts text null;
+alter table riders add column medical_notes text null;
+
+create index riders_user_id on riders (user_id);
+
+create table rider_guardians (
+    rider_id integer not null references riders (id) on delete cascade,
+    user_id integer not null references users (id) on delete cascade,
+    relationship text null,
+    created_at datetime not null,
+    primary key (rider_id, user_id)
+);
+
+create index rider_guardians_user_id on rider_guardians (user_id);
@@ -0,0 +1,4 @@
+alter table horses drop column disciplines;
+alter table horses drop column max_rider_weight_pounds;
+alter table horses drop column min_skill_level;
+alter table horses drop column temperament;
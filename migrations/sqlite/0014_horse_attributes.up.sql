@@ -0,0 +1,5 @@
+-- disciplines is a comma separated list
+alter table horses add column temperament text null check (temperament in ('calm', 'steady', 'spirited'));
+alter table horses add column min_skill_level text null check (min_skill_level in ('beginner', 'novice', 'intermediate', 'advanced', 'professional'));
+alter table horses add column max_rider_weight_pounds integer null;
+alter table horses add column disciplines text null;
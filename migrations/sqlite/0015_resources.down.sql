@@ -0,0 +1,6 @@
+drop index if exists schedules_resource_id;
+drop index if exists rides_resource_id;
+alter table schedules drop column resource_id;
+alter table rides drop column resource_id;
+
+drop table if exists resources;
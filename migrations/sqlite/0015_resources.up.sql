@@ -0,0 +1,15 @@
+create table resources (
+    id integer primary key autoincrement,
+    barn_id integer not null references barns (id) on delete cascade,
+    name text not null,
+    kind text not null check (kind in ('arena', 'round_pen', 'trail', 'other')),
+    capacity integer not null
+);
+
+create index resources_barn_id on resources (barn_id);
+
+alter table rides add column resource_id integer null references resources (id) on delete set null;
+alter table schedules add column resource_id integer null references resources (id) on delete set null;
+
+create index rides_resource_id on rides (resource_id, date);
+create index schedules_resource_id on schedules (resource_id);
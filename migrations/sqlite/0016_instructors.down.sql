@@ -0,0 +1,7 @@
+drop index if exists schedules_instructor_id;
+drop index if exists rides_instructor_id;
+alter table schedules drop column instructor_id;
+alter table rides drop column instructor_id;
+
+drop table if exists instructor_event_types;
+drop table if exists instructors;
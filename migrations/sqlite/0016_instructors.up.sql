@@ -0,0 +1,21 @@
+-- an event type has at most one instructor leading it in each barn
+create table instructors (
+    id integer primary key autoincrement,
+    barn_id integer not null references barns (id) on delete cascade,
+    user_id integer not null references users (id) on delete cascade,
+    unique (barn_id, user_id)
+);
+
+create table instructor_event_types (
+    instructor_id integer not null references instructors (id) on delete cascade,
+    event_type_id integer not null references event_types (id) on delete cascade,
+    barn_id integer not null references barns (id) on delete cascade,
+    primary key (instructor_id, event_type_id),
+    unique (barn_id, event_type_id)
+);
+
+alter table rides add column instructor_id integer null references instructors (id) on delete set null;
+alter table schedules add column instructor_id integer null references instructors (id) on delete set null;
+
+create index rides_instructor_id on rides (instructor_id, date);
+create index schedules_instructor_id on schedules (instructor_id);
@@ -0,0 +1,5 @@
+drop index if exists rides_lesson_id;
+alter table rides drop column lesson_id;
+
+drop index if exists lessons_barn_date;
+drop table if exists lessons;
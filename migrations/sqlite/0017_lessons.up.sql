@@ -0,0 +1,20 @@
+-- a group lesson's roster is the rides that point at it
+create table lessons (
+    id integer primary key autoincrement,
+    barn_id integer not null references barns (id) on delete cascade,
+    event_type_id integer not null references event_types (id),
+    date date not null,
+    time time not null,
+    duration_minutes integer null,
+    instructor_id integer null references instructors (id) on delete set null,
+    resource_id integer null references resources (id) on delete set null,
+    max_riders integer not null,
+    notes text null,
+    status text not null default 'scheduled' check (status in ('scheduled', 'cancelled'))
+);
+
+create index lessons_barn_date on lessons (barn_id, date);
+
+alter table rides add column lesson_id integer null references lessons (id) on delete set null;
+
+create index rides_lesson_id on rides (lesson_id);
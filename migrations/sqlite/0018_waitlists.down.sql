@@ -0,0 +1,4 @@
+drop index if exists lesson_waitlist_lesson_id;
+drop table if exists lesson_waitlist;
+
+alter table lessons drop column hold_minutes;
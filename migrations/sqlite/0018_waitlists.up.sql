@@ -0,0 +1,18 @@
+-- an offered spot is held for the rider until expires_at; hold_minutes of
+-- null means the default hold
+alter table lessons add column hold_minutes integer null;
+
+create table lesson_waitlist (
+    id integer primary key autoincrement,
+    lesson_id integer not null references lessons (id) on delete cascade,
+    horse_id integer not null references horses (id) on delete cascade,
+    rider_id integer not null references riders (id) on delete cascade,
+    notes text null,
+    status text not null default 'waiting' check (status in ('waiting', 'offered', 'confirmed', 'declined', 'expired', 'removed')),
+    created_at datetime not null,
+    offered_at datetime null,
+    expires_at datetime null,
+    ride_id integer null references rides (id) on delete set null
+);
+
+create index lesson_waitlist_lesson_id on lesson_waitlist (lesson_id);
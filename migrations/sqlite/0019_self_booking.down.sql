@@ -0,0 +1,4 @@
+drop index if exists availability_templates_barn_id;
+drop table if exists booking_policies;
+drop table if exists availability_template_event_types;
+drop table if exists availability_templates;
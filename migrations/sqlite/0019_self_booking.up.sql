@@ -0,0 +1,32 @@
+-- the windows a barn opens for riders to book themselves, cut into slots
+create table availability_templates (
+    id integer primary key autoincrement,
+    barn_id integer not null references barns (id) on delete cascade,
+    name text not null,
+    instructor_id integer null references instructors (id) on delete set null,
+    resource_id integer null references resources (id) on delete set null,
+    rrule text not null,
+    start_date date not null,
+    end_date date null,
+    start_time time not null,
+    end_time time not null,
+    slot_minutes integer not null
+);
+
+create table availability_template_event_types (
+    template_id integer not null references availability_templates (id) on delete cascade,
+    event_type_id integer not null references event_types (id) on delete cascade,
+    primary key (template_id, event_type_id)
+);
+
+-- one policy per barn; a null limit is no limit
+create table booking_policies (
+    barn_id integer not null primary key references barns (id) on delete cascade,
+    min_notice_hours integer null,
+    max_advance_days integer null,
+    max_rides_per_day integer null,
+    max_rides_per_week integer null,
+    cancel_notice_hours integer null
+);
+
+create index availability_templates_barn_id on availability_templates (barn_id);
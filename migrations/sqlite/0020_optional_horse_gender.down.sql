@@ -0,0 +1,5 @@
+-- fails while a horse has no gender
+alter table horses add column gender_set text not null default 'mare' check (gender_set in ('mare', 'gelding', 'stallion'));
+update horses set gender_set = gender;
+alter table horses drop column gender;
+alter table horses rename column gender_set to gender;
@@ -0,0 +1,5 @@
+-- a horse's gender can be left unset
+alter table horses add column gender_unset text null check (gender_unset in ('mare', 'gelding', 'stallion'));
+update horses set gender_unset = gender;
+alter table horses drop column gender;
+alter table horses rename column gender_unset to gender;
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"hack/barns"
+	"hack/rides"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerOccurrenceRoutes(app *fiber.App, s stores) {
+	app.Post("/occurrence/:id/cancel", func(c *fiber.Ctx) error {
+		schedule, date, err := occurrence(c, s)
+		if err != nil {
+			return err
+		}
+		ride, err := rides.CancelOccurrence(schedule, date, currentUser(c).ID, s.rides)
+		if err != nil {
+			return occurrenceFailed("Failed to cancel occurrence", err)
+		}
+		return c.JSON(fiber.Map{
+			"ride": ride,
+		})
+	})
+
+	// check-in, no-show and complete work on occurrences nobody has saved as
+	// a ride yet, saving them on the way
+	for path, status := range map[string]rides.Status{"check-in": rides.CheckedIn, "no-show": rides.NoShow} {
+		status := status
+		app.Post("/occurrence/:id/"+path, func(c *fiber.Ctx) error {
+			schedule, date, err := occurrence(c, s)
+			if err != nil {
+				return err
+			}
+			ride, err := rides.TransitionOccurrence(schedule, date, status, currentUser(c).ID, s.rides)
+			if err != nil {
+				return occurrenceFailed("Failed to change occurrence status", err)
+			}
+			return c.JSON(fiber.Map{
+				"ride": ride,
+			})
+		})
+	}
+
+	app.Post("/occurrence/:id/complete", func(c *fiber.Ctx) error {
+		var completion rides.Completion
+		err := parseBody(c, &completion, "completion")
+		if err != nil {
+			return err
+		}
+		schedule, date, err := occurrence(c, s)
+		if err != nil {
+			return err
+		}
+		ride, err := rides.CompleteOccurrence(schedule, date, completion, currentUser(c).ID, s.rides)
+		if err != nil {
+			return occurrenceFailed("Failed to complete occurrence", err)
+		}
+		return c.JSON(fiber.Map{
+			"ride": ride,
+		})
+	})
+
+	app.Put("/occurrence/:id", func(c *fiber.Ctx) error {
+		var change rides.OccurrenceChange
+		err := parseBody(c, &change, "occurrence")
+		if err != nil {
+			return err
+		}
+		schedule, date, err := occurrence(c, s)
+		if err != nil {
+			return err
+		}
+		horseID := schedule.HorseID
+		if change.HorseID != 0 {
+			err = authorizeHorseAndRider(c, s, change.HorseID, schedule.RiderID, barns.ManageSchedules)
+			if err != nil {
+				return err
+			}
+			horseID = change.HorseID
+		}
+		err = authorizeResource(s, change.ResourceID, horseID)
+		if err != nil {
+			return err
+		}
+		err = authorizeInstructor(s, change.InstructorID, horseID)
+		if err != nil {
+			return err
+		}
+		ride, err := rides.MoveOccurrence(schedule, date, change, override(c), currentUser(c).ID, s.rides)
+		if err != nil {
+			return occurrenceFailed("Failed to change occurrence", err)
+		}
+		return c.JSON(fiber.Map{
+			"ride": ride,
+		})
+	})
+
+	// split applies the schedule in the body to the occurrence on its start
+	// date and every one after it
+	app.Post("/schedule/:id/split", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		var next rides.Schedule
+		err = parseBody(c, &next, "schedule")
+		if err != nil {
+			return err
+		}
+		original, err := authorizeScheduleID(c, s, id, barns.ManageSchedules)
+		if err != nil {
+			return err
+		}
+		next.Inherit(original)
+		err = authorizeHorseAndRider(c, s, next.HorseID, next.RiderID, barns.ManageSchedules)
+		if err != nil {
+			return err
+		}
+		err = authorizeResource(s, next.ResourceID, next.HorseID)
+		if err != nil {
+			return err
+		}
+		err = authorizeInstructor(s, next.InstructorID, next.HorseID)
+		if err != nil {
+			return err
+		}
+		err = rides.SplitSchedule(original, &next, override(c), s.rides)
+		if err != nil {
+			return occurrenceFailed("Failed to split schedule", err)
+		}
+		return c.JSON(fiber.Map{
+			"schedule": next,
+		})
+	})
+}
+
+// occurrence resolves the :id param, an occurrence ID, to its schedule.
+func occurrence(c *fiber.Ctx, s stores) (*rides.Schedule, utils.Date, error) {
+	scheduleID, date, err := rides.ParseOccurrenceID(c.Params("id"))
+	if err != nil {
+		return nil, date, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	schedule, err := authorizeScheduleID(c, s, scheduleID, barns.ManageSchedules)
+	return schedule, date, err
+}
+
+func occurrenceFailed(msg string, err error) error {
+	if err == rides.ErrNoOccurrence {
+		return fiber.NewError(fiber.StatusNotFound, msg+": "+err.Error())
+	}
+	return failed(fiber.StatusBadRequest, msg, err)
+}
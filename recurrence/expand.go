@@ -0,0 +1,216 @@
+package recurrence
+
+import (
+	"time"
+)
+
+// maxPeriods stops rules that can never match, like BYMONTHDAY=30;BYMONTH=2,
+// from looping forever.
+const maxPeriods = 10000
+
+// Day truncates t to a date at midnight UTC, which is how occurrences are
+// represented.
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Between returns the occurrences of the rule, counted from start, that fall
+// on or after from and on or before to.
+func (r *Rule) Between(start, from, to time.Time) []time.Time {
+	start, from, to = Day(start), Day(from), Day(to)
+	var dates []time.Time
+	r.each(start, func(date time.Time) bool {
+		if date.After(to) {
+			return false
+		}
+		if !date.Before(from) {
+			dates = append(dates, date)
+		}
+		return true
+	})
+	return dates
+}
+
+// OccursOn reports whether date is an occurrence of the rule counted from start.
+func (r *Rule) OccursOn(start, date time.Time) bool {
+	return len(r.Between(start, date, date)) > 0
+}
+
+// First returns the first occurrence of the rule counted from start, or false
+// when it never occurs.
+func (r *Rule) First(start time.Time) (time.Time, bool) {
+	var first time.Time
+	found := false
+	r.each(Day(start), func(date time.Time) bool {
+		first = date
+		found = true
+		return false
+	})
+	return first, found
+}
+
+// Last returns the final occurrence of a rule with COUNT or UNTIL, or false
+// when the rule is unbounded or never occurs.
+func (r *Rule) Last(start time.Time) (time.Time, bool) {
+	if r.Count == 0 && r.Until == nil {
+		return time.Time{}, false
+	}
+	var last time.Time
+	found := false
+	r.each(Day(start), func(date time.Time) bool {
+		last = date
+		found = true
+		return true
+	})
+	return last, found
+}
+
+// each calls fn with every occurrence in order until fn returns false or the
+// rule runs out.
+func (r *Rule) each(start time.Time, fn func(time.Time) bool) {
+	count := 0
+	periodStart := r.periodStart(start)
+	for i := 0; i < maxPeriods; i++ {
+		for _, date := range r.candidates(start, periodStart) {
+			if date.Before(start) {
+				continue
+			}
+			if r.Until != nil && date.After(*r.Until) {
+				return
+			}
+			if !fn(date) {
+				return
+			}
+			count++
+			if r.Count > 0 && count >= r.Count {
+				return
+			}
+		}
+		periodStart = r.nextPeriod(periodStart)
+	}
+}
+
+func (r *Rule) periodStart(start time.Time) time.Time {
+	switch r.Freq {
+	case Weekly:
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		return start.AddDate(0, 0, -offset)
+	case Monthly:
+		return time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case Yearly:
+		return time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return start
+}
+
+func (r *Rule) nextPeriod(periodStart time.Time) time.Time {
+	switch r.Freq {
+	case Weekly:
+		return periodStart.AddDate(0, 0, 7*r.Interval)
+	case Monthly:
+		return periodStart.AddDate(0, r.Interval, 0)
+	case Yearly:
+		return periodStart.AddDate(r.Interval, 0, 0)
+	}
+	return periodStart.AddDate(0, 0, r.Interval)
+}
+
+// candidates lists the days of the period starting at periodStart that match
+// every BY* part of the rule, filling in whatever the rule leaves out from
+// start as RFC 5545 does.
+func (r *Rule) candidates(start, periodStart time.Time) []time.Time {
+	var periodEnd time.Time
+	switch r.Freq {
+	case Daily:
+		periodEnd = periodStart
+	case Weekly:
+		periodEnd = periodStart.AddDate(0, 0, 6)
+	case Monthly:
+		periodEnd = periodStart.AddDate(0, 1, -1)
+	case Yearly:
+		periodEnd = periodStart.AddDate(1, 0, -1)
+	}
+
+	byDay := r.ByDay
+	byMonthDay := r.ByMonthDay
+	byMonth := r.ByMonth
+	switch r.Freq {
+	case Weekly:
+		if len(byDay) == 0 {
+			byDay = []WeekdayNum{{Day: start.Weekday()}}
+		}
+	case Monthly:
+		if len(byDay) == 0 && len(byMonthDay) == 0 {
+			byMonthDay = []int{start.Day()}
+		}
+	case Yearly:
+		if len(byDay) == 0 && len(byMonthDay) == 0 {
+			byMonthDay = []int{start.Day()}
+			if len(byMonth) == 0 {
+				byMonth = []time.Month{start.Month()}
+			}
+		}
+	}
+
+	var dates []time.Time
+	for date := periodStart; !date.After(periodEnd); date = date.AddDate(0, 0, 1) {
+		if len(byMonth) > 0 && !containsMonth(byMonth, date.Month()) {
+			continue
+		}
+		if len(byMonthDay) > 0 && !matchesMonthDay(byMonthDay, date) {
+			continue
+		}
+		if len(byDay) > 0 && !r.matchesDay(byDay, len(byMonth) > 0, date) {
+			continue
+		}
+		dates = append(dates, date)
+	}
+	return dates
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, m := range months {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesMonthDay(days []int, date time.Time) bool {
+	daysInMonth := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, day := range days {
+		if day == date.Day() || (day < 0 && daysInMonth+day+1 == date.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesDay checks date against BYDAY. Ordinals count within the month for
+// monthly rules and yearly rules with BYMONTH, and within the year otherwise.
+func (r *Rule) matchesDay(days []WeekdayNum, byMonth bool, date time.Time) bool {
+	for _, day := range days {
+		if day.Day != date.Weekday() {
+			continue
+		}
+		if day.N == 0 {
+			return true
+		}
+		var first, last time.Time
+		if r.Freq == Monthly || byMonth {
+			first = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+			last = first.AddDate(0, 1, -1)
+		} else {
+			first = time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+			last = first.AddDate(1, 0, -1)
+		}
+		if day.N > 0 && int(date.Sub(first).Hours()/24)/7+1 == day.N {
+			return true
+		}
+		if day.N < 0 && int(last.Sub(date).Hours()/24)/7+1 == -day.N {
+			return true
+		}
+	}
+	return false
+}
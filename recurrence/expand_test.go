@@ -0,0 +1,199 @@
+package recurrence
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func formatDates(dates []time.Time) string {
+	var s []string
+	for _, d := range dates {
+		s = append(s, d.Format("2006-01-02"))
+	}
+	return strings.Join(s, " ")
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		from  string
+		to    string
+		want  string
+	}{
+		{
+			name: "every other tuesday",
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", start: "2026-01-06", from: "2026-01-01", to: "2026-02-28",
+			want: "2026-01-06 2026-01-20 2026-02-03 2026-02-17",
+		},
+		{
+			name: "interval counts from the start week, not from",
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", start: "2026-01-06", from: "2026-01-13", to: "2026-02-05",
+			want: "2026-01-20 2026-02-03",
+		},
+		{
+			name: "every third day",
+			rule: "FREQ=DAILY;INTERVAL=3", start: "2026-01-30", from: "2026-01-01", to: "2026-02-10",
+			want: "2026-01-30 2026-02-02 2026-02-05 2026-02-08",
+		},
+		{
+			name: "weekly days before the start are skipped",
+			rule: "FREQ=WEEKLY;BYDAY=MO,TH", start: "2026-01-07", from: "2026-01-01", to: "2026-01-15",
+			want: "2026-01-08 2026-01-12 2026-01-15",
+		},
+		{
+			name: "weekly without BYDAY takes the start's day",
+			rule: "FREQ=WEEKLY", start: "2026-01-07", from: "2026-01-01", to: "2026-01-21",
+			want: "2026-01-07 2026-01-14 2026-01-21",
+		},
+		{
+			name: "first saturday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=1SA", start: "2026-01-01", from: "2026-01-01", to: "2026-04-30",
+			want: "2026-01-03 2026-02-07 2026-03-07 2026-04-04",
+		},
+		{
+			name: "last friday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=-1FR", start: "2026-01-01", from: "2026-01-01", to: "2026-04-30",
+			want: "2026-01-30 2026-02-27 2026-03-27 2026-04-24",
+		},
+		{
+			name: "every other month on the first saturday",
+			rule: "FREQ=MONTHLY;INTERVAL=2;BYDAY=1SA", start: "2026-01-01", from: "2026-01-01", to: "2026-06-30",
+			want: "2026-01-03 2026-03-07 2026-05-02",
+		},
+		{
+			name: "the 31st skips short months",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=31", start: "2026-01-01", from: "2026-01-01", to: "2026-12-31",
+			want: "2026-01-31 2026-03-31 2026-05-31 2026-07-31 2026-08-31 2026-10-31 2026-12-31",
+		},
+		{
+			name: "monthly from the 31st skips short months",
+			rule: "FREQ=MONTHLY", start: "2026-01-31", from: "2026-01-01", to: "2026-05-31",
+			want: "2026-01-31 2026-03-31 2026-05-31",
+		},
+		{
+			name: "last day of the month",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=-1", start: "2028-01-01", from: "2028-01-01", to: "2028-04-30",
+			want: "2028-01-31 2028-02-29 2028-03-31 2028-04-30",
+		},
+		{
+			name: "yearly on feb 29 only in leap years",
+			rule: "FREQ=YEARLY", start: "2024-02-29", from: "2024-01-01", to: "2033-12-31",
+			want: "2024-02-29 2028-02-29 2032-02-29",
+		},
+		{
+			name: "last sunday of march",
+			rule: "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU", start: "2026-01-01", from: "2026-01-01", to: "2028-12-31",
+			want: "2026-03-29 2027-03-28 2028-03-26",
+		},
+		{
+			name: "count",
+			rule: "FREQ=DAILY;COUNT=3", start: "2026-01-01", from: "2026-01-01", to: "2026-12-31",
+			want: "2026-01-01 2026-01-02 2026-01-03",
+		},
+		{
+			name: "count runs from the start, not from",
+			rule: "FREQ=DAILY;COUNT=3", start: "2026-01-01", from: "2026-01-02", to: "2026-12-31",
+			want: "2026-01-02 2026-01-03",
+		},
+		{
+			name: "count only counts occurrences",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3", start: "2026-01-01", from: "2026-01-01", to: "2026-12-31",
+			want: "2026-01-31 2026-03-31 2026-05-31",
+		},
+		{
+			name: "until is inclusive",
+			rule: "FREQ=WEEKLY;BYDAY=MO;UNTIL=20260119", start: "2026-01-01", from: "2026-01-01", to: "2026-12-31",
+			want: "2026-01-05 2026-01-12 2026-01-19",
+		},
+		{
+			name: "until as a date-time",
+			rule: "FREQ=WEEKLY;BYDAY=MO;UNTIL=20260119T235959Z", start: "2026-01-01", from: "2026-01-01", to: "2026-12-31",
+			want: "2026-01-05 2026-01-12 2026-01-19",
+		},
+		{
+			name: "until before the start",
+			rule: "FREQ=DAILY;UNTIL=20251231", start: "2026-01-01", from: "2026-01-01", to: "2026-12-31",
+			want: "",
+		},
+		{
+			name: "never matches",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=30;BYMONTH=2", start: "2026-01-01", from: "2026-01-01", to: "2030-12-31",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := formatDates(rule.Between(date(tt.start), date(tt.from), date(tt.to)))
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstAndLast(t *testing.T) {
+	tests := []struct {
+		rule  string
+		start string
+		first string
+		last  string
+	}{
+		{"FREQ=MONTHLY;BYDAY=1SA", "2026-01-04", "2026-02-07", ""},
+		{"FREQ=WEEKLY;BYDAY=TU;COUNT=3", "2026-01-01", "2026-01-06", "2026-01-20"},
+		{"FREQ=WEEKLY;BYDAY=TU;UNTIL=20260125", "2026-01-01", "2026-01-06", "2026-01-20"},
+		{"FREQ=YEARLY;COUNT=2", "2024-02-29", "2024-02-29", "2028-02-29"},
+		{"FREQ=MONTHLY;BYMONTHDAY=30;BYMONTH=2", "2026-01-01", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, ok := rule.First(date(tt.start))
+			if got := formatDates(dates(first, ok)); got != tt.first {
+				t.Fatalf("first %q, want %q", got, tt.first)
+			}
+			last, ok := rule.Last(date(tt.start))
+			if got := formatDates(dates(last, ok)); got != tt.last {
+				t.Fatalf("last %q, want %q", got, tt.last)
+			}
+		})
+	}
+}
+
+func dates(d time.Time, ok bool) []time.Time {
+	if !ok {
+		return nil
+	}
+	return []time.Time{d}
+}
+
+func TestOccursOnIgnoresTimeOfDay(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=TU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 6, 18, 30, 0, 0, time.UTC)
+	if !rule.OccursOn(start, time.Date(2026, 1, 13, 7, 0, 0, 0, time.UTC)) {
+		t.Fatal("does not occur on the next tuesday")
+	}
+	if rule.OccursOn(start, date("2026-01-14")) {
+		t.Fatal("occurs on a wednesday")
+	}
+}
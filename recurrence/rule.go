@@ -0,0 +1,240 @@
+// Package recurrence implements the date part of RFC 5545 recurrence rules:
+// FREQ, INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, COUNT, UNTIL and WKST.
+// Occurrences are whole days; the time of day lives with the schedule.
+package recurrence
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// WeekdayNum is a BYDAY entry such as TU, 1SA or -1FR. N is zero when the
+// entry has no ordinal.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	Count      int
+	Until      *time.Time
+	WeekStart  time.Weekday
+}
+
+var dayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func dayCode(day time.Weekday) string {
+	for code, d := range dayCodes {
+		if d == day {
+			return code
+		}
+	}
+	return ""
+}
+
+// WeeklyOn returns a rule that repeats every week on days.
+func WeeklyOn(days ...time.Weekday) *Rule {
+	r := &Rule{Freq: Weekly, Interval: 1, WeekStart: time.Monday}
+	for _, day := range days {
+		r.ByDay = append(r.ByDay, WeekdayNum{Day: day})
+	}
+	return r
+}
+
+// Parse reads a rule in RRULE syntax, with or without the "RRULE:" prefix.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	r := Rule{Interval: 1, WeekStart: time.Monday}
+	if s == "" {
+		return nil, errors.New("recurrence rule is empty")
+	}
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("invalid recurrence rule part: " + part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = Frequency(value)
+			if r.Freq != Daily && r.Freq != Weekly && r.Freq != Monthly && r.Freq != Yearly {
+				return nil, errors.New("unsupported FREQ: " + value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 {
+				return nil, errors.New("INTERVAL must be a positive number")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err != nil || r.Count < 1 {
+				return nil, errors.New("COUNT must be a positive number")
+			}
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = &until
+		case "BYDAY":
+			for _, item := range strings.Split(value, ",") {
+				day, err := parseWeekdayNum(item)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(item)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, errors.New("invalid BYMONTHDAY: " + item)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, item := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(item)
+				if err != nil || n < 1 || n > 12 {
+					return nil, errors.New("invalid BYMONTH: " + item)
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			day, ok := dayCodes[value]
+			if !ok {
+				return nil, errors.New("invalid WKST: " + value)
+			}
+			r.WeekStart = day
+		default:
+			return nil, errors.New("unsupported recurrence rule part: " + key)
+		}
+	}
+	return &r, r.Validate()
+}
+
+func (r *Rule) Validate() error {
+	if r.Freq == "" {
+		return errors.New("recurrence rule needs a FREQ")
+	}
+	if r.Interval < 1 {
+		return errors.New("INTERVAL must be a positive number")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return errors.New("COUNT and UNTIL cannot both be set")
+	}
+	for _, day := range r.ByDay {
+		if day.N == 0 {
+			continue
+		}
+		if r.Freq != Monthly && r.Freq != Yearly {
+			return errors.New("BYDAY ordinals are only allowed with FREQ=MONTHLY or FREQ=YEARLY")
+		}
+		if day.N < -53 || day.N > 53 || (r.Freq == Monthly && (day.N < -5 || day.N > 5)) {
+			return errors.New("BYDAY ordinal out of range")
+		}
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return errors.New("BYMONTHDAY is not allowed with FREQ=WEEKLY")
+	}
+	return nil
+}
+
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, day := range r.ByDay {
+			code := dayCode(day.Day)
+			if day.N != 0 {
+				code = strconv.Itoa(day.N) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		var days []string
+		for _, day := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(day))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		var months []string
+		for _, month := range r.ByMonth {
+			months = append(months, strconv.Itoa(int(month)))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+dayCode(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return WeekdayNum{}, errors.New("invalid BYDAY: " + s)
+	}
+	day, ok := dayCodes[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, errors.New("invalid BYDAY: " + s)
+	}
+	w := WeekdayNum{Day: day}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 {
+			return WeekdayNum{}, errors.New("invalid BYDAY: " + s)
+		}
+		w.N = n
+	}
+	return w, nil
+}
+
+// parseUntil accepts the DATE and DATE-TIME forms and keeps only the date.
+func parseUntil(s string) (time.Time, error) {
+	if len(s) < 8 {
+		return time.Time{}, errors.New("invalid UNTIL: " + s)
+	}
+	until, err := time.Parse("20060102", s[:8])
+	if err != nil {
+		return time.Time{}, errors.New("invalid UNTIL: " + s)
+	}
+	return until, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"time"
+
+	"hack/barns"
+	"hack/rides"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerResourceRoutes(app *fiber.App, s stores) {
+	app.Post("/barn/:barnID/resources", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		var resource rides.Resource
+		err = parseBody(c, &resource, "resource")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ManageBarn)
+		if err != nil {
+			return err
+		}
+		resource.ID = 0
+		resource.BarnID = barnID
+		err = resource.Save(s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save resource", err)
+		}
+		return c.JSON(fiber.Map{
+			"resource": resource,
+		})
+	})
+
+	app.Get("/barn/:barnID/resources", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		resources, err := rides.ListResources(barnID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get resources", err)
+		}
+		return c.JSON(fiber.Map{
+			"resources": resources,
+		})
+	})
+
+	app.Put("/barn/:barnID/resources/:resourceID", func(c *fiber.Ctx) error {
+		var resource rides.Resource
+		err := parseBody(c, &resource, "resource")
+		if err != nil {
+			return err
+		}
+		existing, err := barnResource(c, s, barns.ManageBarn)
+		if err != nil {
+			return err
+		}
+		resource.ID = existing.ID
+		resource.BarnID = existing.BarnID
+		err = resource.Save(s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save resource", err)
+		}
+		return c.JSON(fiber.Map{
+			"resource": resource,
+		})
+	})
+
+	app.Delete("/barn/:barnID/resources/:resourceID", func(c *fiber.Ctx) error {
+		resource, err := barnResource(c, s, barns.ManageBarn)
+		if err != nil {
+			return err
+		}
+		err = rides.DeleteResource(resource.ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to delete resource", err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+		})
+	})
+
+	// timeline is the day of /barn/:barnID/rides/:date laid out by resource
+	app.Get("/barn/:barnID/timeline/:date", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		date, err := time.Parse("2006-01-02", c.Params("date"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Failed to parse date: "+err.Error())
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		timeline, err := rides.GetTimeline(barnID, utils.Date{Time: date}, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get timeline", err)
+		}
+		return c.JSON(timeline)
+	})
+}
+
+// barnResource resolves the :barnID and :resourceID params to a resource of
+// that barn.
+func barnResource(c *fiber.Ctx, s stores, permission barns.Permission) (*rides.Resource, error) {
+	barnID, err := paramID(c, "barnID")
+	if err != nil {
+		return nil, err
+	}
+	id, err := paramID(c, "resourceID")
+	if err != nil {
+		return nil, err
+	}
+	err = authorizeBarn(c, s, barnID, permission)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := rides.GetResource(barnID, id, s.rides)
+	if err != nil {
+		return nil, failed(fiber.StatusInternalServerError, "Failed to get resource", err)
+	}
+	return resource, nil
+}
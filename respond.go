@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"hack/rides"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errorHandler renders errors returned from handlers, such as the
+// *fiber.Error values built by the helpers below, in the same shape the
+// handlers use.
+func errorHandler(c *fiber.Ctx, err error) error {
+	if conflict, ok := err.(*rides.ConflictError); ok {
+		fmt.Println(conflict.Error())
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":     conflict.Error(),
+			"conflicts": conflict.Conflicts,
+		})
+	}
+	if workload, ok := err.(*rides.WorkloadError); ok {
+		fmt.Println(workload.Error())
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":      workload.Error(),
+			"violations": workload.Violations,
+		})
+	}
+	code := fiber.StatusInternalServerError
+	if e, ok := err.(*fiber.Error); ok {
+		code = e.Code
+	}
+	fmt.Println(err.Error())
+	return c.Status(code).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+func paramID(c *fiber.Ctx, name string) (int64, error) {
+	id, err := strconv.ParseInt(c.Params(name), 10, 64)
+	if err != nil {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Failed to parse "+name+": "+err.Error())
+	}
+	return id, nil
+}
+
+func parseBody(c *fiber.Ctx, out interface{}, what string) error {
+	err := c.BodyParser(out)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Failed to parse "+what+": "+err.Error())
+	}
+	return nil
+}
+
+// failed turns an error from a domain package into a response, mapping
+// utils.ErrNotFound to 404, disallowed status changes and full lessons to
+// 409 and half an occurrence link to 400, passing conflicts and workload
+// violations through for errorHandler and everything else to status.
+func failed(status int, msg string, err error) error {
+	if _, ok := err.(*rides.ConflictError); ok {
+		return err
+	}
+	if _, ok := err.(*rides.WorkloadError); ok {
+		return err
+	}
+	if _, ok := err.(*rides.TransitionError); ok {
+		return fiber.NewError(fiber.StatusConflict, msg+": "+err.Error())
+	}
+	if err == utils.ErrNotFound {
+		return fiber.NewError(fiber.StatusNotFound, msg+": not found")
+	}
+	if err == rides.ErrLessonFull || err == rides.ErrSlotTaken {
+		return fiber.NewError(fiber.StatusConflict, msg+": "+err.Error())
+	}
+	if err == rides.ErrOccurrenceLink {
+		return fiber.NewError(fiber.StatusBadRequest, msg+": "+err.Error())
+	}
+	return fiber.NewError(status, msg+": "+err.Error())
+}
+
+// override reports whether the request asks to save despite conflicts, with
+// ?override=true.
+func override(c *fiber.Ctx) bool {
+	return c.Query("override") == "true"
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"hack/barns"
+	"hack/riders"
+	"hack/rides"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerRiderRoutes(app *fiber.App, s stores) {
+	app.Get("/rider/:id", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		rider, medical, err := authorizeRiderView(c, s, id)
+		if err != nil {
+			return err
+		}
+		if !medical {
+			rider.MedicalNotes = ""
+		}
+		guardians, err := riders.ListGuardians(id, s.riders)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get guardians", err)
+		}
+		return c.JSON(fiber.Map{
+			"rider":     rider,
+			"guardians": guardians,
+		})
+	})
+
+	app.Put("/rider/:id", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		var rider riders.Rider
+		err = parseBody(c, &rider, "rider")
+		if err != nil {
+			return err
+		}
+		existing, err := authorizeRider(c, s, id, barns.ManageRiders)
+		if err != nil {
+			return err
+		}
+		rider.ID = id
+		rider.BarnID = existing.BarnID
+		rider.UserID = existing.UserID
+		err = rider.Save(s.riders)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save rider", err)
+		}
+		return c.JSON(fiber.Map{
+			"rider": rider,
+		})
+	})
+
+	// user links the rider to the account of a barn member, or unlinks it
+	// with a null user_id
+	app.Put("/rider/:id/user", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		var req struct {
+			UserID *int64 `json:"user_id"`
+		}
+		err = parseBody(c, &req, "user")
+		if err != nil {
+			return err
+		}
+		rider, err := authorizeRider(c, s, id, barns.ManageRiders)
+		if err != nil {
+			return err
+		}
+		if req.UserID != nil {
+			err = requireMember(s, rider.BarnID, *req.UserID)
+			if err != nil {
+				return err
+			}
+		}
+		err = rider.LinkUser(req.UserID, s.riders)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to link rider", err)
+		}
+		return c.JSON(fiber.Map{
+			"rider": rider,
+		})
+	})
+
+	app.Get("/rider/:id/guardians", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		_, _, err = authorizeRiderView(c, s, id)
+		if err != nil {
+			return err
+		}
+		guardians, err := riders.ListGuardians(id, s.riders)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get guardians", err)
+		}
+		return c.JSON(fiber.Map{
+			"guardians": guardians,
+		})
+	})
+
+	app.Post("/rider/:id/guardians", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		var guardian riders.Guardian
+		err = parseBody(c, &guardian, "guardian")
+		if err != nil {
+			return err
+		}
+		rider, err := authorizeRider(c, s, id, barns.ManageRiders)
+		if err != nil {
+			return err
+		}
+		err = requireMember(s, rider.BarnID, guardian.UserID)
+		if err != nil {
+			return err
+		}
+		guardian.RiderID = id
+		err = riders.AddGuardian(&guardian, s.riders)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to add guardian", err)
+		}
+		return c.JSON(fiber.Map{
+			"guardian": guardian,
+		})
+	})
+
+	app.Delete("/rider/:id/guardians/:userID", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		userID, err := paramID(c, "userID")
+		if err != nil {
+			return err
+		}
+		_, err = authorizeRider(c, s, id, barns.ManageRiders)
+		if err != nil {
+			return err
+		}
+		err = riders.RemoveGuardian(id, userID, s.riders)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to remove guardian", err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+		})
+	})
+
+	// the riders the signed in user is, or is guardian of
+	app.Get("/me/riders", func(c *fiber.Ctx) error {
+		linked, err := riders.GetLinkedRiders(currentUser(c).ID, s.riders)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get riders", err)
+		}
+		return c.JSON(fiber.Map{
+			"riders": linked,
+		})
+	})
+
+	// schedule is the combined schedule of the riders the signed in user is,
+	// or is guardian of
+	app.Get("/me/schedule", func(c *fiber.Ctx) error {
+		from, to, err := dateRange(c)
+		if err != nil {
+			return err
+		}
+		linked, err := riders.GetLinkedRiders(currentUser(c).ID, s.riders)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get riders", err)
+		}
+		var ids []int64
+		for _, rider := range linked {
+			ids = append(ids, rider.ID)
+		}
+		days, err := rides.GetRidersScheduleByRange(ids, from, to, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get schedule", err)
+		}
+		return c.JSON(fiber.Map{
+			"riders": linked,
+			"days":   days,
+		})
+	})
+}
+
+// requireMember fails with 400 unless userID belongs to the barn, which is
+// how accounts are linked to its riders.
+func requireMember(s stores, barnID int64, userID int64) error {
+	_, err := barns.GetMembership(barnID, userID, s.barns)
+	if err == utils.ErrNotFound {
+		return fiber.NewError(fiber.StatusBadRequest, "user is not a member of this barn")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check barn membership: "+err.Error())
+	}
+	return nil
+}
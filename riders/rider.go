@@ -1,64 +1,177 @@
 package riders
 
 import (
-	"database/sql"
 	"errors"
+	"time"
+
+	"hack/utils"
 )
 
+type SkillLevel string
+
+const (
+	Beginner     SkillLevel = "beginner"
+	Novice       SkillLevel = "novice"
+	Intermediate SkillLevel = "intermediate"
+	Advanced     SkillLevel = "advanced"
+	Professional SkillLevel = "professional"
+)
+
+var skillLevels = []SkillLevel{Beginner, Novice, Intermediate, Advanced, Professional}
+
+func (l SkillLevel) Valid() bool {
+	return l.Rank() > 0
+}
+
+// Rank orders skill levels from 1 for beginners; an unknown level is 0.
+func (l SkillLevel) Rank() int {
+	for i, level := range skillLevels {
+		if level == l {
+			return i + 1
+		}
+	}
+	return 0
+}
+
 type Rider struct {
 	ID     int64  `json:"id"`
 	Name   string `json:"name"`
 	BarnID int64  `json:"barn_id"`
+	// UserID links the rider to the account of the person who rides, so
+	// they can sign in and see their own schedule.
+	UserID            *int64             `json:"user_id,omitempty"`
+	SkillLevel        SkillLevel         `json:"skill_level,omitempty"`
+	HeightInches      int                `json:"height_inches,omitempty"`
+	WeightPounds      int                `json:"weight_pounds,omitempty"`
+	DOB               *utils.Date        `json:"dob,omitempty"`
+	EmergencyContacts []EmergencyContact `json:"emergency_contacts,omitempty"`
+	// MedicalNotes are only read back by GetRider, and only shown to those
+	// who manage riders, the rider and their guardians.
+	MedicalNotes string `json:"medical_notes,omitempty"`
+}
+
+type EmergencyContact struct {
+	Name         string `json:"name"`
+	Relationship string `json:"relationship,omitempty"`
+	Phone        string `json:"phone"`
+}
+
+// Guardian links a user to a rider in their care, usually a parent to a
+// child, so they can see the rider's schedule and profile.
+type Guardian struct {
+	RiderID      int64     `json:"rider_id"`
+	UserID       int64     `json:"user_id"`
+	Name         string    `json:"name,omitempty"`
+	Relationship string    `json:"relationship,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type RiderStore interface {
+	InsertRider(r *Rider) error
+	UpdateRider(r *Rider) error
+	GetRider(id int64) (*Rider, error)
+	GetRidersByBarnID(barnID int64) ([]*Rider, error)
+	// GetRidersByUserID returns the riders in every barn the user belongs to.
+	GetRidersByUserID(userID int64) ([]*Rider, error)
+	// GetLinkedRiders returns the riders who are the user or in their care,
+	// in the barns the user still belongs to.
+	GetLinkedRiders(userID int64) ([]*Rider, error)
+	UpdateRiderUser(riderID int64, userID *int64) error
+	InsertGuardian(g *Guardian) error
+	DeleteGuardian(riderID int64, userID int64) error
+	ListGuardians(riderID int64) ([]*Guardian, error)
+}
+
+func (r *Rider) validate() error {
+	if r.SkillLevel != "" && !r.SkillLevel.Valid() {
+		return errors.New("invalid skill level: " + string(r.SkillLevel))
+	}
+	if r.HeightInches < 0 || r.WeightPounds < 0 {
+		return errors.New("height and weight cannot be negative")
+	}
+	for _, contact := range r.EmergencyContacts {
+		if contact.Name == "" || contact.Phone == "" {
+			return errors.New("an emergency contact needs a name and a phone number")
+		}
+	}
+	return nil
 }
 
-func (r *Rider) Save(db *sql.DB) error {
-	query := "insert into riders (name, barn_id) values (?, ?)"
-	result, err := db.Exec(query, r.Name, r.BarnID)
+// Save stores the rider. The user link is kept as it is; it changes through
+// LinkUser.
+func (r *Rider) Save(store RiderStore) error {
+	err := r.validate()
 	if err != nil {
-		return errors.New("failed to insert rider into database: " + err.Error())
+		return err
+	}
+	if r.ID > 0 {
+		return store.UpdateRider(r)
 	}
-	r.ID, err = result.LastInsertId()
+	return store.InsertRider(r)
+}
+
+func GetRidersByBarnID(barnID int64, store RiderStore) ([]*Rider, error) {
+	return store.GetRidersByBarnID(barnID)
+}
+
+func GetRider(id int64, store RiderStore) (*Rider, error) {
+	return store.GetRider(id)
+}
+
+func GetRidersByUserID(userID int64, store RiderStore) ([]*Rider, error) {
+	return store.GetRidersByUserID(userID)
+}
+
+func GetLinkedRiders(userID int64, store RiderStore) ([]*Rider, error) {
+	return store.GetLinkedRiders(userID)
+}
+
+// LinkUser makes userID the account of the rider, or unlinks it when userID
+// is nil.
+func (r *Rider) LinkUser(userID *int64, store RiderStore) error {
+	err := store.UpdateRiderUser(r.ID, userID)
 	if err != nil {
-		return errors.New("failed to get last insert ID: " + err.Error())
+		return err
 	}
+	r.UserID = userID
 	return nil
 }
 
-func GetRidersByBarnID(barnID int64, db *sql.DB) ([]*Rider, error) {
-	query := "select id, name from riders where barn_id = ?"
-	rows, err := db.Query(query, barnID)
+// IsLinked reports whether userID is the rider or one of their guardians.
+func (r *Rider) IsLinked(userID int64, store RiderStore) (bool, error) {
+	if r.UserID != nil && *r.UserID == userID {
+		return true, nil
+	}
+	guardians, err := store.ListGuardians(r.ID)
 	if err != nil {
-		return nil, errors.New("failed to select riders from database: " + err.Error())
+		return false, err
 	}
-	defer rows.Close()
-	var riders []*Rider
-	for rows.Next() {
-		var rider Rider
-		err = rows.Scan(&rider.ID, &rider.Name)
-		if err != nil {
-			return nil, errors.New("failed to scan row: " + err.Error())
+	for _, g := range guardians {
+		if g.UserID == userID {
+			return true, nil
 		}
-		rider.BarnID = barnID
-		riders = append(riders, &rider)
 	}
-	return riders, nil
+	return false, nil
 }
 
-func GetRiders(db *sql.DB) ([]*Rider, error) {
-	query := "select id, name, barn_id from riders"
-	rows, err := db.Query(query)
+func AddGuardian(g *Guardian, store RiderStore) error {
+	guardians, err := store.ListGuardians(g.RiderID)
 	if err != nil {
-		return nil, errors.New("failed to select riders from database: " + err.Error())
+		return err
 	}
-	defer rows.Close()
-	var riders []*Rider
-	for rows.Next() {
-		var r Rider
-		err := rows.Scan(&r.ID, &r.Name, &r.BarnID)
-		if err != nil {
-			return nil, errors.New("failed to scan row: " + err.Error())
+	for _, existing := range guardians {
+		if existing.UserID == g.UserID {
+			return errors.New("user is already a guardian of this rider")
 		}
-		riders = append(riders, &r)
 	}
-	return riders, nil
+	g.CreatedAt = time.Now().UTC()
+	return store.InsertGuardian(g)
+}
+
+func RemoveGuardian(riderID int64, userID int64, store RiderStore) error {
+	return store.DeleteGuardian(riderID, userID)
+}
+
+func ListGuardians(riderID int64, store RiderStore) ([]*Guardian, error) {
+	return store.ListGuardians(riderID)
 }
@@ -0,0 +1,294 @@
+package rides
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"hack/recurrence"
+	"hack/utils"
+)
+
+// ErrSlotTaken is returned for a slot that is not open, because the
+// template does not have it or its instructor or resource is taken.
+var ErrSlotTaken = errors.New("the slot is not open")
+
+// AvailabilityTemplate is a window of time a barn opens for riders to book
+// themselves into, on the days its rule repeats on. The window is cut into
+// slots of SlotMinutes, each booked as a ride of one of EventTypeIDs with
+// the template's instructor and resource. A slot stays open while they are
+// free. Horses are not counted: a slot with neither stays open to any number
+// of riders, each of whom needs a horse free for it to book.
+type AvailabilityTemplate struct {
+	ID             int64   `json:"id,omitempty"`
+	BarnID         int64   `json:"barn_id"`
+	Name           string  `json:"name"`
+	InstructorID   *int64  `json:"instructor_id,omitempty"`
+	InstructorName string  `json:"instructor_name,omitempty"`
+	ResourceID     *int64  `json:"resource_id,omitempty"`
+	ResourceName   string  `json:"resource_name,omitempty"`
+	EventTypeIDs   []int64 `json:"event_type_ids"`
+	// RRule, StartDate and EndDate work as they do on Schedule.
+	RRule       string      `json:"rrule"`
+	StartDate   utils.Date  `json:"start_date"`
+	EndDate     *utils.Date `json:"end_date,omitempty"`
+	StartTime   *utils.Time `json:"start_time"`
+	EndTime     *utils.Time `json:"end_time"`
+	SlotMinutes int         `json:"slot_minutes"`
+	TimeZone    string      `json:"time_zone,omitempty"`
+}
+
+type AvailabilityStore interface {
+	InsertAvailabilityTemplate(t *AvailabilityTemplate) error
+	UpdateAvailabilityTemplate(t *AvailabilityTemplate) error
+	DeleteAvailabilityTemplate(id int64) error
+	GetAvailabilityTemplate(id int64) (*AvailabilityTemplate, error)
+	ListAvailabilityTemplatesByBarn(barnID int64) ([]*AvailabilityTemplate, error)
+}
+
+// Slot is one open slot of a template, free for a rider to book.
+type Slot struct {
+	TemplateID      int64       `json:"template_id"`
+	Date            utils.Date  `json:"date"`
+	Time            *utils.Time `json:"time"`
+	EndTime         *utils.Time `json:"end_time"`
+	DurationMinutes int         `json:"duration_minutes"`
+	StartsAt        *time.Time  `json:"starts_at"`
+	EndsAt          *time.Time  `json:"ends_at"`
+	EventTypes      []EventType `json:"event_types"`
+	InstructorID    *int64      `json:"instructor_id,omitempty"`
+	InstructorName  string      `json:"instructor_name,omitempty"`
+	ResourceID      *int64      `json:"resource_id,omitempty"`
+	ResourceName    string      `json:"resource_name,omitempty"`
+}
+
+func (t *AvailabilityTemplate) validate(store Store) error {
+	if t.Name == "" {
+		return errors.New("an availability template needs a name")
+	}
+	if t.RRule == "" {
+		return errors.New("an availability template needs a recurrence rule")
+	}
+	rule, err := recurrence.Parse(t.RRule)
+	if err != nil {
+		return errors.New("invalid recurrence rule: " + err.Error())
+	}
+	t.RRule = rule.String()
+	if t.StartDate.IsZero() {
+		return errors.New("an availability template needs a start date")
+	}
+	// an end date before the start date is ignored
+	if t.EndDate != nil && t.EndDate.Before(t.StartDate.Time) {
+		t.EndDate = nil
+	}
+	if t.StartTime == nil || !t.StartTime.Valid || t.EndTime == nil || !t.EndTime.Valid {
+		return errors.New("an availability template needs a start and end time")
+	}
+	if t.SlotMinutes <= 0 {
+		return errors.New("slots have to be at least a minute long")
+	}
+	if minutesOf(t.EndTime)-minutesOf(t.StartTime) < t.SlotMinutes {
+		return errors.New("the window has to fit at least one slot")
+	}
+	if len(t.EventTypeIDs) == 0 {
+		return errors.New("an availability template needs at least one event type")
+	}
+	for _, id := range t.EventTypeIDs {
+		_, err := store.GetEventType(id)
+		if err == utils.ErrNotFound {
+			return errors.New("event type " + strconv.FormatInt(id, 10) + " not found")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *AvailabilityTemplate) Save(store Store) error {
+	err := t.validate(store)
+	if err != nil {
+		return err
+	}
+	if t.ID > 0 {
+		return store.UpdateAvailabilityTemplate(t)
+	}
+	return store.InsertAvailabilityTemplate(t)
+}
+
+// GetAvailabilityTemplate returns the template only if it belongs to barnID.
+func GetAvailabilityTemplate(barnID int64, id int64, store AvailabilityStore) (*AvailabilityTemplate, error) {
+	t, err := store.GetAvailabilityTemplate(id)
+	if err != nil {
+		return nil, err
+	}
+	if t.BarnID != barnID {
+		return nil, utils.ErrNotFound
+	}
+	return t, nil
+}
+
+func ListAvailabilityTemplates(barnID int64, store AvailabilityStore) ([]*AvailabilityTemplate, error) {
+	return store.ListAvailabilityTemplatesByBarn(barnID)
+}
+
+func DeleteAvailabilityTemplate(id int64, store AvailabilityStore) error {
+	return store.DeleteAvailabilityTemplate(id)
+}
+
+// OpenSlots returns the slots of the barn's templates from from to to whose
+// instructor and resource are free, earliest first. Slots already over are
+// left in; how far ahead a slot can be booked is up to the caller.
+func OpenSlots(barnID int64, from utils.Date, to utils.Date, store Store) ([]*Slot, error) {
+	templates, err := store.ListAvailabilityTemplatesByBarn(barnID)
+	if err != nil {
+		return nil, err
+	}
+	slots := []*Slot{}
+	for _, t := range templates {
+		open, err := t.openSlots(from, to, store)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, open...)
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].StartsAt.Before(*slots[j].StartsAt)
+	})
+	return slots, nil
+}
+
+// FindSlot returns the open slot of the barn's template starting at time on
+// date, or ErrSlotTaken.
+func FindSlot(barnID int64, templateID int64, date utils.Date, at *utils.Time, store Store) (*Slot, error) {
+	t, err := GetAvailabilityTemplate(barnID, templateID, store)
+	if err != nil {
+		return nil, err
+	}
+	if at == nil || !at.Valid {
+		return nil, errors.New("a slot needs a time")
+	}
+	open, err := t.openSlots(date, date, store)
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range open {
+		if minutesOf(slot.Time) == minutesOf(at) {
+			return slot, nil
+		}
+	}
+	return nil, ErrSlotTaken
+}
+
+// Ride returns the ride booking the slot for a horse and rider.
+func (s *Slot) Ride(horseID int64, riderID int64, eventTypeID int64) *Ride {
+	return &Ride{
+		HorseID:         horseID,
+		RiderID:         riderID,
+		EventTypeID:     eventTypeID,
+		Date:            s.Date,
+		Time:            s.Time,
+		DurationMinutes: s.DurationMinutes,
+		InstructorID:    s.InstructorID,
+		ResourceID:      s.ResourceID,
+	}
+}
+
+// Overlaps reports whether a booked ride is on during the slot.
+func (s *Slot) Overlaps(other *RideDetail) bool {
+	r := &Ride{Date: s.Date, Time: s.Time, DurationMinutes: s.DurationMinutes, StartsAt: s.StartsAt, EndsAt: s.EndsAt, Status: Scheduled}
+	return overlaps(r, &other.Ride)
+}
+
+// openSlots cuts the template's window into slots on each day it repeats
+// on from from to to, leaving out those its instructor is booked for or its
+// resource has no room for.
+func (t *AvailabilityTemplate) openSlots(from utils.Date, to utils.Date, store Store) ([]*Slot, error) {
+	schedule := &Schedule{RRule: t.RRule, StartDate: t.StartDate, EndDate: t.EndDate}
+	dates := schedule.Occurrences(from, to)
+	if len(dates) == 0 {
+		return nil, nil
+	}
+	var eventTypes []EventType
+	for _, id := range t.EventTypeIDs {
+		eventType, err := store.GetEventType(id)
+		if err != nil {
+			return nil, err
+		}
+		eventTypes = append(eventTypes, *eventType)
+	}
+	var instructorDays, resourceDays []*Day
+	var resource *Resource
+	var err error
+	if t.InstructorID != nil {
+		instructorDays, err = GetInstructorScheduleByRange(*t.InstructorID, from, to, store)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.ResourceID != nil {
+		resource, err = store.GetResource(*t.ResourceID)
+		if err != nil {
+			return nil, err
+		}
+		resourceDays, err = GetResourceScheduleByRange(resource.ID, from, to, store)
+		if err != nil {
+			return nil, err
+		}
+	}
+	loc := utils.Location(t.TimeZone)
+	var slots []*Slot
+	for _, date := range dates {
+		for start := minutesOf(t.StartTime); start+t.SlotMinutes <= minutesOf(t.EndTime); start += t.SlotMinutes {
+			at := &utils.Time{}
+			at.Valid = true
+			at.Time = time.Date(0, 1, 1, start/60, start%60, 0, 0, time.UTC)
+			r := &Ride{Date: date, Time: at, DurationMinutes: t.SlotMinutes, Status: Scheduled}
+			r.locate(loc)
+			if day := dayOf(instructorDays, date); day != nil && busy(r, day.Rides) {
+				continue
+			}
+			if day := dayOf(resourceDays, date); day != nil && len(peakUse(r, day.Rides))+1 > resource.Capacity {
+				continue
+			}
+			slots = append(slots, &Slot{
+				TemplateID:      t.ID,
+				Date:            date,
+				Time:            at,
+				EndTime:         r.EndTime,
+				DurationMinutes: t.SlotMinutes,
+				StartsAt:        r.StartsAt,
+				EndsAt:          r.EndsAt,
+				EventTypes:      eventTypes,
+				InstructorID:    t.InstructorID,
+				InstructorName:  t.InstructorName,
+				ResourceID:      t.ResourceID,
+				ResourceName:    t.ResourceName,
+			})
+		}
+	}
+	return slots, nil
+}
+
+func busy(r *Ride, booked []*RideDetail) bool {
+	for _, other := range booked {
+		if overlaps(r, &other.Ride) {
+			return true
+		}
+	}
+	return false
+}
+
+func dayOf(days []*Day, date utils.Date) *Day {
+	for _, day := range days {
+		if day.Date.Equal(date.Time) {
+			return day
+		}
+	}
+	return nil
+}
+
+func minutesOf(t *utils.Time) int {
+	return t.Time.Hour()*60 + t.Time.Minute()
+}
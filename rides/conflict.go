@@ -0,0 +1,307 @@
+package rides
+
+import (
+	"strconv"
+	"time"
+
+	"hack/recurrence"
+	"hack/utils"
+)
+
+// conflictHorizon is how many days of an open-ended schedule are checked for
+// conflicts.
+const conflictHorizon = 366
+
+type ConflictKind string
+
+const (
+	HorseConflict      ConflictKind = "horse"
+	RiderConflict      ConflictKind = "rider"
+	InstructorConflict ConflictKind = "instructor"
+	// HorseUnavailable is a booking on a day the horse is blocked.
+	HorseUnavailable ConflictKind = "horse_unavailable"
+	// ResourceFull is a booking that would put more rides in a resource than
+	// it takes at once.
+	ResourceFull ConflictKind = "resource_full"
+)
+
+// Conflict is an existing ride or schedule occurrence that a proposed ride or
+// schedule would double-book, the block that keeps its horse out, or the
+// resource it would overfill along with the rides sharing it.
+type Conflict struct {
+	Kind     ConflictKind    `json:"kind"`
+	Date     utils.Date      `json:"date"`
+	With     *RideDetail     `json:"with,omitempty"`
+	Block    *Unavailability `json:"block,omitempty"`
+	Resource *Resource       `json:"resource,omitempty"`
+	Sharing  []*RideDetail   `json:"sharing,omitempty"`
+}
+
+// ConflictError is returned by the save functions when they refuse to
+// double-book.
+type ConflictError struct {
+	Conflicts []*Conflict `json:"conflicts"`
+}
+
+func (e *ConflictError) Error() string {
+	if len(e.Conflicts) == 1 {
+		return "1 scheduling conflict"
+	}
+	return strconv.Itoa(len(e.Conflicts)) + " scheduling conflicts"
+}
+
+// overlaps reports whether two located bookings are on at the same time.
+// Rides without a time are not known to clash with anything.
+func overlaps(a *Ride, b *Ride) bool {
+	if a.Status == Cancelled || b.Status == Cancelled {
+		return false
+	}
+	if a.StartsAt == nil || b.StartsAt == nil {
+		return false
+	}
+	aStart, aEnd := a.span()
+	bStart, bEnd := b.span()
+	return aStart.Before(bEnd) && bStart.Before(aEnd)
+}
+
+// FindConflicts returns what the ride would double-book.
+func (r *Ride) FindConflicts(store Store) ([]*Conflict, error) {
+	p, err := r.propose(store)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	return findConflicts(p, r.RiderID, store)
+}
+
+// FindConflicts returns what the schedule would double-book, looking at most
+// conflictHorizon days ahead from today or its start, whichever is later.
+func (s *Schedule) FindConflicts(store Store) ([]*Conflict, error) {
+	p, err := s.propose(store)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	return findConflicts(p, s.RiderID, store)
+}
+
+// proposal is what a ride or schedule would add to a horse's days, with a
+// way to tell which booked rides it replaces and the group lesson it is in,
+// whose other rides share its instructor and resource.
+type proposal struct {
+	rides        []*Ride
+	horseID      int64
+	resourceID   *int64
+	instructorID *int64
+	lessonID     *int64
+	from         utils.Date
+	to           utils.Date
+	replaces     func(*RideDetail) bool
+}
+
+// propose returns the ride as it would be booked, or nil for a cancelled one.
+func (r *Ride) propose(store Store) (*proposal, error) {
+	if r.Status == Cancelled {
+		return nil, nil
+	}
+	proposed := *r
+	var err error
+	proposed.DurationMinutes, err = durationOrDefault(r.DurationMinutes, r.EventTypeID, store)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := horseLocation(r.HorseID, store)
+	if err != nil {
+		return nil, err
+	}
+	proposed.StartsAt = nil
+	proposed.locate(loc)
+	isSelf := func(other *RideDetail) bool {
+		if r.ID > 0 && other.ID == r.ID {
+			return true
+		}
+		// the occurrence this ride stands in for
+		return r.ScheduleID != nil && r.OccurrenceDate != nil && other.OccurrenceID != "" && other.OccurrenceID == OccurrenceID(*r.ScheduleID, *r.OccurrenceDate)
+	}
+	return &proposal{
+		rides:        []*Ride{&proposed},
+		horseID:      r.HorseID,
+		resourceID:   r.ResourceID,
+		instructorID: r.InstructorID,
+		lessonID:     r.LessonID,
+		from:         r.Date,
+		to:           r.Date,
+		replaces:     isSelf,
+	}, nil
+}
+
+// propose returns the occurrences of the schedule from today or its start,
+// whichever is later, up to conflictHorizon days ahead, or nil if there are
+// none.
+func (s *Schedule) propose(store Store) (*proposal, error) {
+	loc, err := horseLocation(s.HorseID, store)
+	if err != nil {
+		return nil, err
+	}
+	from := s.StartDate
+	today := recurrence.Day(time.Now().In(loc))
+	if today.After(from.Time) {
+		from = utils.Date{Time: today}
+	}
+	to := utils.Date{Time: from.AddDate(0, 0, conflictHorizon-1)}
+	if s.EndDate != nil && s.EndDate.Before(to.Time) {
+		to = *s.EndDate
+	}
+	if to.Before(from.Time) {
+		return nil, nil
+	}
+	duration, err := durationOrDefault(s.DurationMinutes, s.EventType.ID, store)
+	if err != nil {
+		return nil, err
+	}
+	var proposed []*Ride
+	for _, date := range s.Occurrences(from, to) {
+		r := &Ride{
+			HorseID:         s.HorseID,
+			RiderID:         s.RiderID,
+			EventTypeID:     s.EventType.ID,
+			Date:            date,
+			Time:            s.Time,
+			DurationMinutes: duration,
+			Status:          Scheduled,
+		}
+		r.locate(loc)
+		proposed = append(proposed, r)
+	}
+	if len(proposed) == 0 {
+		return nil, nil
+	}
+	isSelf := func(other *RideDetail) bool {
+		return s.ID > 0 && other.ScheduleID != nil && *other.ScheduleID == s.ID
+	}
+	return &proposal{
+		rides:        proposed,
+		horseID:      s.HorseID,
+		resourceID:   s.ResourceID,
+		instructorID: s.InstructorID,
+		from:         from,
+		to:           to,
+		replaces:     isSelf,
+	}, nil
+}
+
+// findConflicts checks the proposed bookings against everything their horse,
+// riderID and instructor already have in the proposal's range, against the
+// horse's blocks and against the capacity of their resource.
+func findConflicts(p *proposal, riderID int64, store Store) ([]*Conflict, error) {
+	horseDays, err := GetHorseScheduleByRange(p.horseID, p.from, p.to, store)
+	if err != nil {
+		return nil, err
+	}
+	riderDays, err := GetRiderScheduleByRange(riderID, p.from, p.to, store)
+	if err != nil {
+		return nil, err
+	}
+	var instructorDays []*Day
+	if p.instructorID != nil {
+		instructorDays, err = GetInstructorScheduleByRange(*p.instructorID, p.from, p.to, store)
+		if err != nil {
+			return nil, err
+		}
+	}
+	blocks, err := store.ListUnavailabilities([]int64{p.horseID}, p.from, p.to)
+	if err != nil {
+		return nil, err
+	}
+	var conflicts []*Conflict
+	for i := range horseDays {
+		for _, r := range p.rides {
+			if !r.Date.Equal(horseDays[i].Date.Time) {
+				continue
+			}
+			if block := blockFor(blocks, p.horseID, r.Date); block != nil {
+				conflicts = append(conflicts, &Conflict{
+					Kind:  HorseUnavailable,
+					Date:  r.Date,
+					Block: block,
+				})
+			}
+			conflicts = appendConflicts(conflicts, HorseConflict, r, horseDays[i].Rides, p.replaces)
+			conflicts = appendConflicts(conflicts, RiderConflict, r, riderDays[i].Rides, p.replaces)
+			if instructorDays != nil {
+				conflicts = appendConflicts(conflicts, InstructorConflict, r, instructorDays[i].Rides, p.sharesWith)
+			}
+		}
+	}
+	if p.resourceID == nil {
+		return conflicts, nil
+	}
+	return appendResourceConflicts(conflicts, p, store)
+}
+
+// appendResourceConflicts adds a conflict for each proposed booking that
+// would have its resource take more rides at once than its capacity.
+func appendResourceConflicts(conflicts []*Conflict, p *proposal, store Store) ([]*Conflict, error) {
+	resource, err := store.GetResource(*p.resourceID)
+	if err != nil {
+		return nil, err
+	}
+	days, err := GetResourceScheduleByRange(resource.ID, p.from, p.to, store)
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range days {
+		var booked []*RideDetail
+		for _, other := range day.Rides {
+			if !p.sharesWith(other) {
+				booked = append(booked, other)
+			}
+		}
+		for _, r := range p.rides {
+			if !r.Date.Equal(day.Date.Time) {
+				continue
+			}
+			sharing := peakUse(r, booked)
+			if len(sharing)+1 > resource.Capacity {
+				conflicts = append(conflicts, &Conflict{
+					Kind:     ResourceFull,
+					Date:     r.Date,
+					Resource: resource,
+					Sharing:  sharing,
+				})
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+func appendConflicts(conflicts []*Conflict, kind ConflictKind, proposed *Ride, booked []*RideDetail, isSelf func(*RideDetail) bool) []*Conflict {
+	for _, other := range booked {
+		if isSelf(other) || !overlaps(proposed, &other.Ride) {
+			continue
+		}
+		conflicts = append(conflicts, &Conflict{
+			Kind: kind,
+			Date: proposed.Date,
+			With: other,
+		})
+	}
+	return conflicts
+}
+
+func checkConflicts(conflicts []*Conflict, err error) error {
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &ConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// sharesWith reports whether a booked ride is one the proposal replaces or
+// another ride of its group lesson.
+func (p *proposal) sharesWith(other *RideDetail) bool {
+	if p.replaces(other) {
+		return true
+	}
+	return p.lessonID != nil && other.LessonID != nil && *other.LessonID == *p.lessonID
+}
@@ -0,0 +1,271 @@
+package rides
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHalfOccurrenceLink(t *testing.T) {
+	scheduleID := int64(1)
+	occurrence := date("2031-01-06")
+	tests := []struct {
+		name string
+		ride Ride
+	}{
+		{"schedule without occurrence", Ride{HorseID: 1, RiderID: 1, Date: occurrence, Time: clock(9, 0), ScheduleID: &scheduleID}},
+		{"occurrence without schedule", Ride{HorseID: 1, RiderID: 1, Date: occurrence, Time: clock(9, 0), OccurrenceDate: &occurrence}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			booked := &Ride{HorseID: 1, RiderID: 2, Date: occurrence, Time: clock(9, 0)}
+			err := booked.Save(store, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ride := tt.ride
+			_, err = ride.Check(store)
+			if err != ErrOccurrenceLink {
+				t.Fatalf("check: got %v, want ErrOccurrenceLink", err)
+			}
+			ride = tt.ride
+			err = ride.Save(store, false)
+			if err != ErrOccurrenceLink {
+				t.Fatalf("save: got %v, want ErrOccurrenceLink", err)
+			}
+			if len(store.rides) != 1 {
+				t.Fatalf("%d rides saved, want only the one booked", len(store.rides))
+			}
+			// conflicts are still found without the link being checked
+			ride = tt.ride
+			conflicts, err := ride.FindConflicts(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conflicts) != 1 || conflicts[0].Kind != HorseConflict {
+				t.Fatalf("got %d conflicts, want the horse booked at 9:00", len(conflicts))
+			}
+		})
+	}
+}
+
+// bookedBarn saves what the conflict tests book against: horse 1 and rider 1
+// with instructor 7 on Tuesday 2031-01-07 from 10:00 to 11:00, a cancelled
+// ride of horse 2 and an untimed one of horse 3 that day, and schedule 1 of
+// horse 4 and rider 4 every Monday at 9:00 from 2031-01-06, whose occurrence
+// on 2031-01-20 was moved to the Tuesday after.
+func bookedBarn(t *testing.T) *memoryStore {
+	t.Helper()
+	store := newMemoryStore()
+	instructorID := int64(7)
+	scheduleID := int64(1)
+	moved := date("2031-01-20")
+	booked := []*Ride{
+		{HorseID: 1, RiderID: 1, InstructorID: &instructorID, Date: date("2031-01-07"), Time: clock(10, 0)},
+		{HorseID: 2, RiderID: 2, Date: date("2031-01-07"), Time: clock(14, 0), Status: Cancelled},
+		{HorseID: 3, RiderID: 3, Date: date("2031-01-07")},
+		{HorseID: 4, RiderID: 4, Date: date("2031-01-21"), Time: clock(9, 0), ScheduleID: &scheduleID, OccurrenceDate: &moved},
+	}
+	for _, r := range booked {
+		err := r.Save(store, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &Schedule{HorseID: 4, RiderID: 4, StartDate: date("2031-01-06"), Time: clock(9, 0), RRule: "FREQ=WEEKLY;BYDAY=MO"}
+	err := s.Save(store, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func formatConflicts(conflicts []*Conflict) string {
+	var s []string
+	for _, c := range conflicts {
+		s = append(s, string(c.Kind)+" "+c.Date.Format("2006-01-02"))
+	}
+	return strings.Join(s, ", ")
+}
+
+func TestRideConflicts(t *testing.T) {
+	instructorID := int64(7)
+	scheduleID := int64(1)
+	occurrence := date("2031-01-27")
+	tests := []struct {
+		name string
+		ride Ride
+		want string
+	}{
+		{
+			name: "horse overlaps",
+			ride: Ride{HorseID: 1, RiderID: 9, Date: date("2031-01-07"), Time: clock(10, 30)},
+			want: "horse 2031-01-07",
+		},
+		{
+			name: "rider overlaps",
+			ride: Ride{HorseID: 9, RiderID: 1, Date: date("2031-01-07"), Time: clock(9, 30)},
+			want: "rider 2031-01-07",
+		},
+		{
+			name: "horse and rider overlap",
+			ride: Ride{HorseID: 1, RiderID: 1, Date: date("2031-01-07"), Time: clock(10, 0)},
+			want: "horse 2031-01-07, rider 2031-01-07",
+		},
+		{
+			name: "instructor overlaps",
+			ride: Ride{HorseID: 9, RiderID: 9, InstructorID: &instructorID, Date: date("2031-01-07"), Time: clock(10, 45)},
+			want: "instructor 2031-01-07",
+		},
+		{
+			name: "back to back",
+			ride: Ride{HorseID: 1, RiderID: 1, Date: date("2031-01-07"), Time: clock(11, 0)},
+			want: "",
+		},
+		{
+			name: "another day",
+			ride: Ride{HorseID: 1, RiderID: 1, Date: date("2031-01-08"), Time: clock(10, 0)},
+			want: "",
+		},
+		{
+			name: "the ride itself saved again",
+			ride: Ride{ID: 1, HorseID: 1, RiderID: 1, Date: date("2031-01-07"), Time: clock(10, 30)},
+			want: "",
+		},
+		{
+			name: "cancelled ride frees the horse",
+			ride: Ride{HorseID: 2, RiderID: 9, Date: date("2031-01-07"), Time: clock(14, 0)},
+			want: "",
+		},
+		{
+			name: "booked ride without a time",
+			ride: Ride{HorseID: 3, RiderID: 9, Date: date("2031-01-07"), Time: clock(10, 0)},
+			want: "",
+		},
+		{
+			name: "proposed ride without a time",
+			ride: Ride{HorseID: 1, RiderID: 1, Date: date("2031-01-07")},
+			want: "",
+		},
+		{
+			name: "cancelled ride is not checked",
+			ride: Ride{HorseID: 1, RiderID: 1, Date: date("2031-01-07"), Time: clock(10, 0), Status: Cancelled},
+			want: "",
+		},
+		{
+			name: "schedule occurrence",
+			ride: Ride{HorseID: 4, RiderID: 9, Date: date("2031-01-13"), Time: clock(9, 30)},
+			want: "horse 2031-01-13",
+		},
+		{
+			name: "day the schedule skips",
+			ride: Ride{HorseID: 4, RiderID: 4, Date: date("2031-01-14"), Time: clock(9, 0)},
+			want: "",
+		},
+		{
+			name: "occurrence moved away",
+			ride: Ride{HorseID: 4, RiderID: 4, Date: date("2031-01-20"), Time: clock(9, 0)},
+			want: "",
+		},
+		{
+			name: "occurrence moved in",
+			ride: Ride{HorseID: 4, RiderID: 9, Date: date("2031-01-21"), Time: clock(9, 0)},
+			want: "horse 2031-01-21",
+		},
+		{
+			name: "standing in for its own occurrence",
+			ride: Ride{HorseID: 4, RiderID: 4, Date: date("2031-01-27"), Time: clock(9, 30), ScheduleID: &scheduleID, OccurrenceDate: &occurrence},
+			want: "",
+		},
+		{
+			name: "moved onto the next occurrence",
+			ride: Ride{HorseID: 4, RiderID: 4, Date: date("2031-02-03"), Time: clock(9, 0), ScheduleID: &scheduleID, OccurrenceDate: &occurrence},
+			want: "horse 2031-02-03, rider 2031-02-03",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := bookedBarn(t)
+			ride := tt.ride
+			conflicts, err := ride.Check(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := formatConflicts(conflicts); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleConflicts(t *testing.T) {
+	until := date("2031-01-31")
+	tests := []struct {
+		name     string
+		schedule Schedule
+		want     string
+	}{
+		{
+			name:     "over a ride",
+			schedule: Schedule{HorseID: 1, RiderID: 9, StartDate: date("2031-01-06"), EndDate: &until, Time: clock(10, 30), RRule: "FREQ=WEEKLY;BYDAY=TU"},
+			want:     "horse 2031-01-07",
+		},
+		{
+			name:     "around a ride",
+			schedule: Schedule{HorseID: 1, RiderID: 9, StartDate: date("2031-01-06"), EndDate: &until, Time: clock(12, 0), RRule: "FREQ=WEEKLY;BYDAY=TU"},
+			want:     "",
+		},
+		{
+			name:     "starting after a ride",
+			schedule: Schedule{HorseID: 1, RiderID: 9, StartDate: date("2031-01-08"), EndDate: &until, Time: clock(10, 30), RRule: "FREQ=WEEKLY;BYDAY=TU"},
+			want:     "",
+		},
+		{
+			name:     "over another schedule and the occurrence it moved",
+			schedule: Schedule{HorseID: 4, RiderID: 9, StartDate: date("2031-01-06"), EndDate: &until, Time: clock(9, 30), RRule: "FREQ=DAILY;INTERVAL=7"},
+			want:     "horse 2031-01-06, horse 2031-01-13, horse 2031-01-27",
+		},
+		{
+			name:     "on the day an occurrence moved to",
+			schedule: Schedule{HorseID: 4, RiderID: 9, StartDate: date("2031-01-06"), EndDate: &until, Time: clock(9, 0), RRule: "FREQ=WEEKLY;BYDAY=TU"},
+			want:     "horse 2031-01-21",
+		},
+		{
+			name:     "the schedule itself saved again",
+			schedule: Schedule{ID: 1, HorseID: 4, RiderID: 4, StartDate: date("2031-01-06"), Time: clock(9, 30), RRule: "FREQ=WEEKLY;BYDAY=MO,TU"},
+			want:     "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := bookedBarn(t)
+			schedule := tt.schedule
+			conflicts, err := schedule.Check(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := formatConflicts(conflicts); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandReplacedOccurrences(t *testing.T) {
+	store := bookedBarn(t)
+	days, err := GetHorseScheduleByRange(4, date("2031-01-19"), date("2031-01-28"), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, day := range days {
+		for _, r := range day.Rides {
+			got = append(got, day.Date.Format("2006-01-02")+" "+r.OccurrenceID)
+		}
+	}
+	want := "2031-01-21 1-20310120, 2031-01-27 1-20310127"
+	if strings.Join(got, ", ") != want {
+		t.Fatalf("got %q, want %q", strings.Join(got, ", "), want)
+	}
+}
@@ -0,0 +1,63 @@
+package rides
+
+import (
+	"errors"
+	"time"
+
+	"hack/utils"
+)
+
+// durationFromEndTime works out the duration in minutes of a booking that
+// gave an end time instead. A duration that was given wins.
+func durationFromEndTime(start *utils.Time, end *utils.Time, minutes int) (int, error) {
+	if minutes < 0 {
+		return 0, errors.New("duration cannot be negative")
+	}
+	if minutes > 0 || end == nil || !end.Valid {
+		return minutes, nil
+	}
+	if start == nil || !start.Valid {
+		return 0, errors.New("an end time needs a start time")
+	}
+	if !end.Time.After(start.Time) {
+		return 0, errors.New("end time must be after the start time")
+	}
+	return int(end.Time.Sub(start.Time).Minutes()), nil
+}
+
+func endTime(start *utils.Time, minutes int) *utils.Time {
+	if start == nil || !start.Valid || minutes <= 0 {
+		return nil
+	}
+	var end utils.Time
+	end.Valid = true
+	end.Time = start.Time.Add(time.Duration(minutes) * time.Minute)
+	return &end
+}
+
+// durationOrDefault returns minutes, or the event type's duration when the
+// booking has none of its own.
+func durationOrDefault(minutes int, eventTypeID int64, store EventTypeStore) (int, error) {
+	if minutes > 0 {
+		return minutes, nil
+	}
+	eventType, err := store.GetEventType(eventTypeID)
+	if err == utils.ErrNotFound {
+		return DefaultDurationMinutes, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return eventType.DurationMinutes, nil
+}
+
+// span returns when a located ride starts and ends. A ride with no duration
+// is treated as lasting a minute so it still clashes with one at the same
+// time.
+func (r *Ride) span() (time.Time, time.Time) {
+	minutes := r.DurationMinutes
+	if minutes <= 0 {
+		minutes = 1
+	}
+	return *r.StartsAt, r.StartsAt.Add(time.Duration(minutes) * time.Minute)
+}
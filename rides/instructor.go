@@ -0,0 +1,124 @@
+package rides
+
+import (
+	"errors"
+	"strconv"
+
+	"hack/utils"
+)
+
+// Instructor is a barn member who teaches rides. EventTypeIDs are the kinds
+// of ride they lead in the barn by default: a ride or schedule of one of
+// them saved without an instructor gets this one.
+type Instructor struct {
+	ID           int64   `json:"id"`
+	BarnID       int64   `json:"barn_id"`
+	UserID       int64   `json:"user_id"`
+	Name         string  `json:"name"`
+	EventTypeIDs []int64 `json:"event_type_ids"`
+}
+
+type InstructorStore interface {
+	// InsertInstructor and UpdateInstructor take over the instructor's
+	// event types from any other instructor of the barn who led them.
+	InsertInstructor(i *Instructor) error
+	UpdateInstructor(i *Instructor) error
+	// DeleteInstructor leaves the rides and schedules they taught without an
+	// instructor.
+	DeleteInstructor(id int64) error
+	GetInstructor(id int64) (*Instructor, error)
+	ListInstructorsByBarn(barnID int64) ([]*Instructor, error)
+	// DefaultInstructor returns the instructor who leads eventTypeID in the
+	// barn of horseID, or nil if no one does.
+	DefaultInstructor(horseID int64, eventTypeID int64) (*int64, error)
+	// ListRidesByInstructor and ListActiveSchedulesByInstructor work as
+	// their horse and rider counterparts do.
+	ListRidesByInstructor(instructorID int64, from utils.Date, to utils.Date) ([]*RideDetail, error)
+	ListActiveSchedulesByInstructor(instructorID int64, from utils.Date, to utils.Date) ([]*Schedule, error)
+}
+
+func (i *Instructor) Save(store Store) error {
+	if i.EventTypeIDs == nil {
+		i.EventTypeIDs = []int64{}
+	}
+	for _, id := range i.EventTypeIDs {
+		_, err := store.GetEventType(id)
+		if err == utils.ErrNotFound {
+			return errors.New("event type " + strconv.FormatInt(id, 10) + " not found")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if i.ID > 0 {
+		return store.UpdateInstructor(i)
+	}
+	return store.InsertInstructor(i)
+}
+
+// GetInstructor returns the instructor only if they belong to barnID.
+func GetInstructor(barnID int64, id int64, store InstructorStore) (*Instructor, error) {
+	i, err := store.GetInstructor(id)
+	if err != nil {
+		return nil, err
+	}
+	if i.BarnID != barnID {
+		return nil, utils.ErrNotFound
+	}
+	return i, nil
+}
+
+func ListInstructors(barnID int64, store InstructorStore) ([]*Instructor, error) {
+	return store.ListInstructorsByBarn(barnID)
+}
+
+func DeleteInstructor(id int64, store InstructorStore) error {
+	return store.DeleteInstructor(id)
+}
+
+// DeleteMemberInstructor deletes the instructor userID is in the barn, if
+// any, once they are no longer a member.
+func DeleteMemberInstructor(barnID int64, userID int64, store InstructorStore) error {
+	instructors, err := store.ListInstructorsByBarn(barnID)
+	if err != nil {
+		return err
+	}
+	for _, i := range instructors {
+		if i.UserID == userID {
+			return store.DeleteInstructor(i.ID)
+		}
+	}
+	return nil
+}
+
+// defaultInstructor fills in the instructor who leads the event type in the
+// horse's barn when none is given.
+func defaultInstructor(instructorID *int64, horseID int64, eventTypeID int64, store Store) (*int64, error) {
+	if instructorID != nil {
+		return instructorID, nil
+	}
+	return store.DefaultInstructor(horseID, eventTypeID)
+}
+
+func GetInstructorScheduleByRange(instructorID int64, from utils.Date, to utils.Date, store Store) ([]*Day, error) {
+	rides, err := store.ListRidesByInstructor(instructorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := store.ListActiveSchedulesByInstructor(instructorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return expand(rides, schedules, from, to, store)
+}
+
+// GetInstructorAgenda returns the instructor's day on date or, for a week,
+// the Monday to Sunday date falls in.
+func GetInstructorAgenda(instructorID int64, date utils.Date, week bool, store Store) ([]*Day, error) {
+	if !week {
+		return GetInstructorScheduleByRange(instructorID, date, date, store)
+	}
+	from := WeekStart(date)
+	to := utils.Date{Time: from.AddDate(0, 0, 6)}
+	return GetInstructorScheduleByRange(instructorID, from, to, store)
+}
@@ -0,0 +1,350 @@
+package rides
+
+import (
+	"errors"
+	"time"
+
+	"hack/utils"
+)
+
+// ErrLessonFull is returned for a ride that would take a group lesson past
+// its MaxRiders.
+var ErrLessonFull = errors.New("the lesson is full")
+
+// Lesson is a group lesson: a time, event type, instructor and resource
+// shared by a roster of rides, one for each horse and rider taking part.
+// The rides keep their own status, so a rider can drop out of a lesson
+// without cancelling it for everyone.
+type Lesson struct {
+	ID            int64       `json:"id,omitempty"`
+	BarnID        int64       `json:"barn_id"`
+	EventTypeID   int64       `json:"event_type_id"`
+	EventTypeName string      `json:"event_type_name,omitempty"`
+	Date          utils.Date  `json:"date"`
+	Time          *utils.Time `json:"time"`
+	// DurationMinutes, EndTime, StartsAt and EndsAt work as they do on Ride;
+	// a lesson read back has the event type's duration filled in.
+	DurationMinutes int         `json:"duration_minutes,omitempty"`
+	EndTime         *utils.Time `json:"end_time,omitempty"`
+	StartsAt        *time.Time  `json:"starts_at,omitempty"`
+	EndsAt          *time.Time  `json:"ends_at,omitempty"`
+	TimeZone        string      `json:"time_zone,omitempty"`
+	// InstructorID left out is the instructor who leads the event type in
+	// the barn, if any.
+	InstructorID   *int64 `json:"instructor_id,omitempty"`
+	InstructorName string `json:"instructor_name,omitempty"`
+	ResourceID     *int64 `json:"resource_id,omitempty"`
+	ResourceName   string `json:"resource_name,omitempty"`
+	// MaxRiders is how many rides the roster takes, not counting cancelled
+	// ones.
+	MaxRiders int `json:"max_riders"`
+	// HoldMinutes is how long a spot that opens up is held for the next
+	// rider on the waitlist to confirm; zero means DefaultHoldMinutes.
+	HoldMinutes int    `json:"hold_minutes,omitempty"`
+	Notes       string `json:"notes"`
+	// Status is scheduled or cancelled.
+	Status Status        `json:"status"`
+	Roster []*RideDetail `json:"roster"`
+}
+
+type LessonStore interface {
+	GetLesson(id int64) (*Lesson, error)
+	InsertLesson(l *Lesson) error
+	UpdateLesson(l *Lesson) error
+	UpdateLessonStatus(id int64, status Status) error
+	// ListLessonsByBarn returns the lessons dated from from to to, without
+	// their rosters.
+	ListLessonsByBarn(barnID int64, from utils.Date, to utils.Date) ([]*Lesson, error)
+	// ListLessonRides returns the roster of a lesson in the order the rides
+	// joined it, cancelled ones included.
+	ListLessonRides(lessonID int64) ([]*RideDetail, error)
+	// DefaultBarnInstructor works as DefaultInstructor does for a barn
+	// rather than a horse.
+	DefaultBarnInstructor(barnID int64, eventTypeID int64) (*int64, error)
+	// HorseAndRiderInBarn reports whether both the horse and the rider are
+	// in barnID.
+	HorseAndRiderInBarn(barnID int64, horseID int64, riderID int64) (bool, error)
+}
+
+func (l *Lesson) validate() error {
+	if l.Date.IsZero() {
+		return errors.New("a lesson needs a date")
+	}
+	if l.Time == nil || !l.Time.Valid {
+		return errors.New("a lesson needs a time")
+	}
+	if l.MaxRiders < 1 {
+		return errors.New("a lesson has to take at least one rider")
+	}
+	if l.HoldMinutes < 0 {
+		return errors.New("the hold cannot be negative")
+	}
+	return nil
+}
+
+// Save stores the lesson and moves its roster along with it, refusing with a
+// *ConflictError or *WorkloadError as Ride.Save does when any ride of the
+// roster would be refused at the lesson's new time, unless override is set.
+// A cancelled lesson cannot be changed, and the roster and the spots on offer
+// to the waitlist cannot be more than MaxRiders. Spots that open up are
+// offered to the waitlist.
+func (l *Lesson) Save(store Store, override bool) error {
+	err := l.validate()
+	if err != nil {
+		return err
+	}
+	if l.EventTypeID == 0 {
+		l.EventTypeID = 10 // Generic event type
+	}
+	l.DurationMinutes, err = durationFromEndTime(l.Time, l.EndTime, l.DurationMinutes)
+	if err != nil {
+		return err
+	}
+	if l.InstructorID == nil {
+		l.InstructorID, err = store.DefaultBarnInstructor(l.BarnID, l.EventTypeID)
+		if err != nil {
+			return err
+		}
+	}
+	if l.ID == 0 {
+		l.Status = Scheduled
+		return store.InsertLesson(l)
+	}
+
+	existing, err := store.GetLesson(l.ID)
+	if err != nil {
+		return err
+	}
+	if existing.Status == Cancelled {
+		return errors.New("a cancelled lesson cannot be changed")
+	}
+	l.Status = existing.Status
+	roster, err := store.ListLessonRides(l.ID)
+	if err != nil {
+		return err
+	}
+	held, err := heldSpots(l.ID, 0, store)
+	if err != nil {
+		return err
+	}
+	if len(active(roster))+held > l.MaxRiders {
+		return errors.New("the lesson already has more riders, or spots on offer, than that")
+	}
+	if !override {
+		var conflicts []*Conflict
+		for _, r := range active(roster) {
+			moved := r.Ride
+			l.apply(&moved)
+			p, err := moved.propose(store)
+			if err != nil {
+				return err
+			}
+			found, err := findConflicts(p, moved.RiderID, store)
+			if err != nil {
+				return err
+			}
+			conflicts = append(conflicts, found...)
+			_, err = checkWorkload(p, false, store)
+			if err != nil {
+				return err
+			}
+		}
+		err = checkConflicts(conflicts, nil)
+		if err != nil {
+			return err
+		}
+	}
+	err = store.UpdateLesson(l)
+	if err != nil {
+		return err
+	}
+	// the roster has been checked, or the save overrides it
+	for _, r := range roster {
+		ride := r.Ride
+		err = ride.Save(store, true)
+		if err != nil {
+			return err
+		}
+	}
+	return fillLesson(l.ID, store)
+}
+
+func (l *Lesson) holdMinutes() int {
+	if l.HoldMinutes > 0 {
+		return l.HoldMinutes
+	}
+	return DefaultHoldMinutes
+}
+
+// started reports whether the lesson has begun by now.
+func (l *Lesson) started(now time.Time) bool {
+	l.locate()
+	return l.StartsAt != nil && !now.Before(*l.StartsAt)
+}
+
+// apply gives a ride of the lesson's roster the lesson's time, event type,
+// instructor and resource.
+func (l *Lesson) apply(r *Ride) {
+	id := l.ID
+	r.LessonID = &id
+	r.EventTypeID = l.EventTypeID
+	r.Date = l.Date
+	r.Time = l.Time
+	r.DurationMinutes = l.DurationMinutes
+	r.EndTime = nil
+	r.InstructorID = l.InstructorID
+	r.ResourceID = l.ResourceID
+	r.StartsAt = nil
+}
+
+// locate fills in when the lesson starts and ends on the barn's clock.
+func (l *Lesson) locate() {
+	var r Ride
+	l.apply(&r)
+	r.locate(utils.Location(l.TimeZone))
+	l.StartsAt, l.EndsAt, l.EndTime = r.StartsAt, r.EndsAt, r.EndTime
+}
+
+// GetLesson returns the lesson with its roster, only if it belongs to barnID.
+func GetLesson(barnID int64, id int64, store Store) (*Lesson, error) {
+	l, err := store.GetLesson(id)
+	if err != nil {
+		return nil, err
+	}
+	if l.BarnID != barnID {
+		return nil, utils.ErrNotFound
+	}
+	l.Roster, err = store.ListLessonRides(id)
+	if err != nil {
+		return nil, err
+	}
+	if l.Roster == nil {
+		l.Roster = []*RideDetail{}
+	}
+	for _, r := range l.Roster {
+		r.locate(utils.Location(r.TimeZone))
+	}
+	l.locate()
+	return l, nil
+}
+
+// ListLessons returns the barn's lessons dated from from to to with their
+// rosters.
+func ListLessons(barnID int64, from utils.Date, to utils.Date, store Store) ([]*Lesson, error) {
+	lessons, err := store.ListLessonsByBarn(barnID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range lessons {
+		lessons[i], err = GetLesson(barnID, l.ID, store)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lessons, nil
+}
+
+// Cancel cancels the lesson and every ride of its roster that can still be
+// cancelled on behalf of actorID, and closes its waitlist. Cancelling a
+// cancelled lesson does nothing.
+func (l *Lesson) Cancel(actorID int64, store Store) error {
+	if l.Status == Cancelled {
+		return nil
+	}
+	// the lesson is cancelled first so the rides leaving it do not offer
+	// their spots to the waitlist
+	err := store.UpdateLessonStatus(l.ID, Cancelled)
+	if err != nil {
+		return err
+	}
+	l.Status = Cancelled
+	roster, err := store.ListLessonRides(l.ID)
+	if err != nil {
+		return err
+	}
+	for _, r := range roster {
+		if !r.Status.CanBecome(Cancelled) {
+			continue
+		}
+		ride := r.Ride
+		err = ride.Transition(Cancelled, actorID, store)
+		if err != nil {
+			return err
+		}
+	}
+	return closeWaitlist(l.ID, store)
+}
+
+// checkRoom returns ErrLessonFull when the lesson cannot take another ride
+// for riderID. Spots held for other riders on the waitlist are taken.
+func checkRoom(lessonID int64, riderID int64, store Store) error {
+	err := fillLesson(lessonID, store)
+	if err != nil {
+		return err
+	}
+	l, err := store.GetLesson(lessonID)
+	if err != nil {
+		return err
+	}
+	if l.Status == Cancelled {
+		return errors.New("the lesson is cancelled")
+	}
+	roster, err := store.ListLessonRides(lessonID)
+	if err != nil {
+		return err
+	}
+	held, err := heldSpots(lessonID, riderID, store)
+	if err != nil {
+		return err
+	}
+	if len(active(roster))+held >= l.MaxRiders {
+		return ErrLessonFull
+	}
+	return nil
+}
+
+// checkLessonBarn refuses a horse or rider from another barn than the
+// lesson's.
+func checkLessonBarn(l *Lesson, horseID int64, riderID int64, store Store) error {
+	ok, err := store.HorseAndRiderInBarn(l.BarnID, horseID, riderID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("the horse and rider have to be in the lesson's barn")
+	}
+	return nil
+}
+
+// active leaves out the cancelled rides of a roster.
+func active(roster []*RideDetail) []*RideDetail {
+	var kept []*RideDetail
+	for _, r := range roster {
+		if r.Status != Cancelled {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// groupLessons moves the rides of the lessons into their rosters, leaving
+// the day's other rides where they are.
+func groupLessons(day *Day, lessons []*Lesson) *Day {
+	grouped := &Day{Date: day.Date, Rides: []*RideDetail{}, Lessons: []*Lesson{}}
+	byID := make(map[int64]*Lesson)
+	for _, l := range lessons {
+		l.Roster = []*RideDetail{}
+		l.locate()
+		byID[l.ID] = l
+		grouped.Lessons = append(grouped.Lessons, l)
+	}
+	for _, r := range day.Rides {
+		if r.LessonID != nil && byID[*r.LessonID] != nil {
+			l := byID[*r.LessonID]
+			l.Roster = append(l.Roster, r)
+			continue
+		}
+		grouped.Rides = append(grouped.Rides, r)
+	}
+	return grouped
+}
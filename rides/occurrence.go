@@ -0,0 +1,247 @@
+package rides
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"hack/utils"
+)
+
+var ErrNoOccurrence = errors.New("schedule has no occurrence on that date")
+
+// OccurrenceID identifies one occurrence of a schedule by the schedule and
+// the date its rule puts it on, so it stays the same when the occurrence is
+// moved.
+func OccurrenceID(scheduleID int64, date utils.Date) string {
+	return strconv.FormatInt(scheduleID, 10) + "-" + date.Format("20060102")
+}
+
+func ParseOccurrenceID(id string) (int64, utils.Date, error) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return 0, utils.Date{}, errors.New("invalid occurrence ID: " + id)
+	}
+	scheduleID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, utils.Date{}, errors.New("invalid occurrence ID: " + id)
+	}
+	date, err := time.Parse("20060102", parts[1])
+	if err != nil {
+		return 0, utils.Date{}, errors.New("invalid occurrence ID: " + id)
+	}
+	return scheduleID, utils.Date{Time: date}, nil
+}
+
+func setOccurrenceIDs(rides []*RideDetail) {
+	for _, r := range rides {
+		if r.ScheduleID != nil && r.OccurrenceDate != nil {
+			r.OccurrenceID = OccurrenceID(*r.ScheduleID, *r.OccurrenceDate)
+		}
+	}
+}
+
+// OccurrenceChange edits a single occurrence. Anything left out stays as
+// the schedule has it.
+type OccurrenceChange struct {
+	Date            *utils.Date `json:"date,omitempty"`
+	Time            *utils.Time `json:"time,omitempty"`
+	DurationMinutes int         `json:"duration_minutes,omitempty"`
+	HorseID         int64       `json:"horse_id,omitempty"`
+	ResourceID      *int64      `json:"resource_id,omitempty"`
+	InstructorID    *int64      `json:"instructor_id,omitempty"`
+	Notes           *string     `json:"notes,omitempty"`
+}
+
+// occurrenceRide returns the ride standing in for the occurrence of s on
+// date, or an unsaved one built from the schedule if there is none yet.
+func occurrenceRide(s *Schedule, date utils.Date, store RideStore) (*Ride, error) {
+	r, err := store.GetOccurrenceRide(s.ID, date)
+	if err == nil {
+		return r, nil
+	}
+	if err != utils.ErrNotFound {
+		return nil, err
+	}
+	if !s.OccursOn(date) {
+		return nil, ErrNoOccurrence
+	}
+	scheduleID := s.ID
+	occurrenceDate := date
+	return &Ride{
+		HorseID:         s.HorseID,
+		RiderID:         s.RiderID,
+		EventTypeID:     s.EventType.ID,
+		Date:            date,
+		Time:            s.Time,
+		DurationMinutes: s.DurationMinutes,
+		Status:          Scheduled,
+		ScheduleID:      &scheduleID,
+		OccurrenceDate:  &occurrenceDate,
+		ResourceID:      s.ResourceID,
+		InstructorID:    s.InstructorID,
+	}, nil
+}
+
+func CancelOccurrence(s *Schedule, date utils.Date, actorID int64, store Store) (*Ride, error) {
+	r, err := occurrenceRide(s, date, store)
+	if err != nil {
+		return nil, err
+	}
+	return r, r.Cancel(actorID, store)
+}
+
+// MoveOccurrence applies change to the occurrence of s on date. Moving a
+// cancelled occurrence puts it back on the schedule.
+func MoveOccurrence(s *Schedule, date utils.Date, change OccurrenceChange, override bool, actorID int64, store Store) (*Ride, error) {
+	r, err := occurrenceRide(s, date, store)
+	if err != nil {
+		return nil, err
+	}
+	if change.Date != nil {
+		r.Date = *change.Date
+	}
+	if change.Time != nil {
+		r.Time = change.Time
+	}
+	if change.DurationMinutes != 0 {
+		r.DurationMinutes = change.DurationMinutes
+	}
+	if change.HorseID != 0 {
+		r.HorseID = change.HorseID
+	}
+	if change.ResourceID != nil {
+		r.ResourceID = change.ResourceID
+	}
+	if change.InstructorID != nil {
+		r.InstructorID = change.InstructorID
+	}
+	if change.Notes != nil {
+		r.Notes = *change.Notes
+	}
+	if r.Status != Cancelled {
+		return r, r.Save(store, override)
+	}
+
+	// Save lets a cancelled ride through unchecked, so it is checked here as
+	// it will be once it is back on the schedule, then saved and put back
+	err = r.prepare(store)
+	if err != nil {
+		return nil, err
+	}
+	r.Status = Scheduled
+	p, err := r.propose(store)
+	r.Status = Cancelled
+	if err != nil {
+		return nil, err
+	}
+	if !override {
+		err = checkConflicts(findConflicts(p, r.RiderID, store))
+		if err != nil {
+			return nil, err
+		}
+	}
+	warnings, err := checkWorkload(p, override, store)
+	if err != nil {
+		return nil, err
+	}
+	err = r.Save(store, override)
+	if err != nil {
+		return nil, err
+	}
+	r.Warnings = warnings
+	return r, r.Transition(Scheduled, actorID, store)
+}
+
+// Inherit fills in whatever s leaves out from original, so a split only has
+// to carry what changes.
+func (s *Schedule) Inherit(original *Schedule) {
+	if s.HorseID == 0 {
+		s.HorseID = original.HorseID
+	}
+	if s.RiderID == 0 {
+		s.RiderID = original.RiderID
+	}
+	if s.EventType.ID == 0 {
+		s.EventType.ID = original.EventType.ID
+	}
+	if s.Time == nil {
+		s.Time = original.Time
+	}
+	if s.DurationMinutes == 0 && s.EndTime == nil {
+		s.DurationMinutes = original.DurationMinutes
+	}
+	if s.EndDate == nil {
+		s.EndDate = original.EndDate
+	}
+	if s.ResourceID == nil {
+		s.ResourceID = original.ResourceID
+	}
+	if s.InstructorID == nil {
+		s.InstructorID = original.InstructorID
+	}
+	if s.RRule == "" && s.weekdayRule() == "" {
+		s.RRule = original.RRule
+	}
+}
+
+// SplitSchedule makes a "this and following" edit from the occurrence on
+// next.StartDate: original stops the day before and next takes over from
+// there, along with any occurrences already edited on or after that date.
+// Splitting at the first occurrence edits the whole schedule.
+func SplitSchedule(original *Schedule, next *Schedule, override bool, store Store) error {
+	if !original.OccursOn(next.StartDate) {
+		return ErrNoOccurrence
+	}
+	next.Inherit(original)
+	rule, err := original.Rule()
+	if err != nil {
+		return err
+	}
+	before := rule.Between(original.StartDate.Time, original.StartDate.Time, next.StartDate.AddDate(0, 0, -1))
+	if len(before) == 0 {
+		next.ID = original.ID
+		next.StartDate = original.StartDate
+		return next.Save(store, override)
+	}
+
+	// a counted rule keeps counting where the original left off
+	if next.RRule == original.RRule && rule.Count > 0 {
+		rule.Count -= len(before)
+		next.RRule = rule.String()
+	}
+	// the original is cut short first so next is not checked against the
+	// occurrences it is taking over
+	endDate := original.EndDate
+	original.EndDate = &utils.Date{Time: next.StartDate.AddDate(0, 0, -1)}
+	err = store.UpdateSchedule(original)
+	if err != nil {
+		return err
+	}
+	// a step that fails undoes the ones before it, leaving the original as
+	// it was
+	restore := func(err error) error {
+		original.EndDate = endDate
+		restoreErr := store.UpdateSchedule(original)
+		if restoreErr != nil {
+			return errors.New("failed to restore schedule after failed split: " + restoreErr.Error())
+		}
+		return err
+	}
+	next.ID = 0
+	err = next.Save(store, override)
+	if err != nil {
+		return restore(err)
+	}
+	err = store.MoveOccurrenceRides(original.ID, next.ID, next.StartDate)
+	if err != nil {
+		deleteErr := store.DeleteSchedule(next.ID)
+		if deleteErr != nil {
+			return errors.New("failed to delete new schedule after failed split: " + deleteErr.Error())
+		}
+		next.ID = 0
+		return restore(err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package rides
+
+import (
+	"errors"
+	"testing"
+)
+
+// mondays saves a schedule of horse 1 and rider 1 every Monday at 9:00 from
+// 2031-01-06.
+func mondays(t *testing.T, store *memoryStore) *Schedule {
+	t.Helper()
+	s := &Schedule{HorseID: 1, RiderID: 1, StartDate: date("2031-01-06"), Time: clock(9, 0), RRule: "FREQ=WEEKLY;BYDAY=MO"}
+	err := s.Save(store, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestMoveOccurrenceReinstates(t *testing.T) {
+	tests := []struct {
+		name        string
+		enforcement Enforcement
+		override    bool
+		refused     bool
+		warnings    int
+	}{
+		{"blocking rule", Block, false, true, 0},
+		{"blocking rule overridden", Block, true, false, 1},
+		{"warning rule", Warn, false, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			s := mondays(t, store)
+			other := &Ride{HorseID: 1, RiderID: 2, Date: date("2031-01-14"), Time: clock(13, 0)}
+			err := other.Save(store, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.rules[1] = &WorkloadRule{HorseID: 1, MaxRidesPerDay: 1, Enforcement: tt.enforcement}
+			cancelled, err := CancelOccurrence(s, date("2031-01-13"), 1, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// back on the schedule a day later, when the horse already works
+			tuesday := date("2031-01-14")
+			r, err := MoveOccurrence(s, date("2031-01-13"), OccurrenceChange{Date: &tuesday}, tt.override, 1, store)
+			saved, getErr := store.GetRide(cancelled.ID)
+			if getErr != nil {
+				t.Fatal(getErr)
+			}
+			if tt.refused {
+				if _, ok := err.(*WorkloadError); !ok {
+					t.Fatalf("got %v, want a *WorkloadError", err)
+				}
+				if saved.Status != Cancelled || !saved.Date.Equal(date("2031-01-13").Time) {
+					t.Fatalf("ride is %s on %s, want it left cancelled on 2031-01-13", saved.Status, saved.Date.Format("2006-01-02"))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(r.Warnings) != tt.warnings {
+				t.Fatalf("got %d warnings, want %d", len(r.Warnings), tt.warnings)
+			}
+			if saved.Status != Scheduled || !saved.Date.Equal(tuesday.Time) {
+				t.Fatalf("ride is %s on %s, want it scheduled on 2031-01-14", saved.Status, saved.Date.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestMoveOccurrenceReinstateConflicts(t *testing.T) {
+	store := newMemoryStore()
+	s := mondays(t, store)
+	_, err := CancelOccurrence(s, date("2031-01-13"), 1, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &Ride{HorseID: 1, RiderID: 2, Date: date("2031-01-13"), Time: clock(9, 30)}
+	err = other.Save(store, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = MoveOccurrence(s, date("2031-01-13"), OccurrenceChange{}, false, 1, store)
+	if conflict, ok := err.(*ConflictError); !ok || len(conflict.Conflicts) != 1 {
+		t.Fatalf("got %v, want the horse's 9:30 ride as the one conflict", err)
+	}
+}
+
+func TestSplitSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		failMove error
+	}{
+		{"split", nil},
+		{"rides fail to move", errors.New("failed to move occurrence rides")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			s := mondays(t, store)
+			edited := date("2031-01-20")
+			moved, err := MoveOccurrence(s, edited, OccurrenceChange{Time: clock(10, 0)}, false, 1, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.failMove = tt.failMove
+
+			original, err := store.GetSchedule(s.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := &Schedule{StartDate: date("2031-01-13"), Time: clock(11, 0)}
+			err = SplitSchedule(original, next, false, store)
+			ride, getErr := store.GetRide(moved.ID)
+			if getErr != nil {
+				t.Fatal(getErr)
+			}
+			if tt.failMove != nil {
+				if err != tt.failMove {
+					t.Fatalf("got %v, want %v", err, tt.failMove)
+				}
+				if len(store.schedules) != 1 || store.schedules[0].EndDate != nil {
+					t.Fatalf("schedules are %+v, want the original alone and open-ended", store.schedules)
+				}
+				if *ride.ScheduleID != s.ID {
+					t.Fatalf("edited occurrence moved to schedule %d", *ride.ScheduleID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved, err := store.GetSchedule(s.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.EndDate == nil || !saved.EndDate.Equal(date("2031-01-12").Time) {
+				t.Fatalf("original ends %v, want 2031-01-12", saved.EndDate)
+			}
+			if next.ID == s.ID || next.HorseID != s.HorseID || next.RRule != s.RRule {
+				t.Fatalf("next is %+v, want a new schedule inheriting the original", next)
+			}
+			if *ride.ScheduleID != next.ID {
+				t.Fatalf("edited occurrence is on schedule %d, want %d", *ride.ScheduleID, next.ID)
+			}
+		})
+	}
+}
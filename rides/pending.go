@@ -0,0 +1,103 @@
+package rides
+
+import "hack/utils"
+
+// Pending is bookings that are not saved yet, like the events of an import
+// being previewed, that rides and schedules are checked against as if they
+// were. Each booking is kept under the source it came from.
+type Pending struct {
+	bookings []*pendingBooking
+}
+
+type pendingBooking struct {
+	source string
+	ride   *RideDetail
+}
+
+// RideConflicts returns what the ride, as Check leaves it, would double-book
+// among the pending bookings.
+func (p *Pending) RideConflicts(r *Ride, store Store) ([]*Conflict, error) {
+	prop, err := r.propose(store)
+	if err != nil || prop == nil {
+		return nil, err
+	}
+	return p.conflicts(prop, r.RiderID), nil
+}
+
+// ScheduleConflicts works as RideConflicts does for a schedule's
+// occurrences.
+func (p *Pending) ScheduleConflicts(s *Schedule, store Store) ([]*Conflict, error) {
+	prop, err := s.propose(store)
+	if err != nil || prop == nil {
+		return nil, err
+	}
+	return p.conflicts(prop, s.RiderID), nil
+}
+
+func (p *Pending) AddRide(source string, r *Ride, store Store) error {
+	prop, err := r.propose(store)
+	if err != nil || prop == nil {
+		return err
+	}
+	p.add(source, prop, r.RiderID)
+	return nil
+}
+
+// AddSchedule adds the schedule's occurrences as far ahead as conflicts are
+// checked.
+func (p *Pending) AddSchedule(source string, s *Schedule, store Store) error {
+	prop, err := s.propose(store)
+	if err != nil || prop == nil {
+		return err
+	}
+	p.add(source, prop, s.RiderID)
+	return nil
+}
+
+// Remove drops the bookings of source on date, as for an occurrence that is
+// cancelled or moved.
+func (p *Pending) Remove(source string, date utils.Date) {
+	var kept []*pendingBooking
+	for _, b := range p.bookings {
+		if b.source != source || !b.ride.Date.Equal(date.Time) {
+			kept = append(kept, b)
+		}
+	}
+	p.bookings = kept
+}
+
+func (p *Pending) add(source string, prop *proposal, riderID int64) {
+	for _, r := range prop.rides {
+		booked := *r
+		booked.HorseID = prop.horseID
+		booked.RiderID = riderID
+		booked.InstructorID = prop.instructorID
+		booked.ResourceID = prop.resourceID
+		p.bookings = append(p.bookings, &pendingBooking{source: source, ride: &RideDetail{Ride: booked}})
+	}
+}
+
+// conflicts checks the proposal against the pending bookings of its horse,
+// rider and instructor.
+func (p *Pending) conflicts(prop *proposal, riderID int64) []*Conflict {
+	var horse, rider, instructor []*RideDetail
+	for _, b := range p.bookings {
+		if b.ride.HorseID == prop.horseID {
+			horse = append(horse, b.ride)
+		}
+		if b.ride.RiderID == riderID {
+			rider = append(rider, b.ride)
+		}
+		if prop.instructorID != nil && b.ride.InstructorID != nil && *b.ride.InstructorID == *prop.instructorID {
+			instructor = append(instructor, b.ride)
+		}
+	}
+	none := func(*RideDetail) bool { return false }
+	var conflicts []*Conflict
+	for _, r := range prop.rides {
+		conflicts = appendConflicts(conflicts, HorseConflict, r, horse, none)
+		conflicts = appendConflicts(conflicts, RiderConflict, r, rider, none)
+		conflicts = appendConflicts(conflicts, InstructorConflict, r, instructor, none)
+	}
+	return conflicts
+}
@@ -0,0 +1,209 @@
+package rides
+
+import (
+	"errors"
+	"sort"
+
+	"hack/utils"
+)
+
+type ResourceKind string
+
+const (
+	Arena    ResourceKind = "arena"
+	RoundPen ResourceKind = "round_pen"
+	Trail    ResourceKind = "trail"
+	// OtherResource is anything else a ride can take up, like a wash rack.
+	OtherResource ResourceKind = "other"
+)
+
+// Resource is a place in the barn rides are booked into. Capacity is how
+// many rides it takes at once.
+type Resource struct {
+	ID       int64        `json:"id"`
+	BarnID   int64        `json:"barn_id"`
+	Name     string       `json:"name"`
+	Kind     ResourceKind `json:"kind"`
+	Capacity int          `json:"capacity"`
+}
+
+type ResourceStore interface {
+	InsertResource(r *Resource) error
+	UpdateResource(r *Resource) error
+	// DeleteResource leaves the rides and schedules booked into the
+	// resource without one.
+	DeleteResource(id int64) error
+	GetResource(id int64) (*Resource, error)
+	ListResourcesByBarn(barnID int64) ([]*Resource, error)
+	// ListRidesByResource and ListActiveSchedulesByResource work as their
+	// horse and rider counterparts do.
+	ListRidesByResource(resourceID int64, from utils.Date, to utils.Date) ([]*RideDetail, error)
+	ListActiveSchedulesByResource(resourceID int64, from utils.Date, to utils.Date) ([]*Schedule, error)
+}
+
+func (r *Resource) validate() error {
+	if r.Name == "" {
+		return errors.New("a resource needs a name")
+	}
+	switch r.Kind {
+	case Arena, RoundPen, Trail, OtherResource:
+	default:
+		return errors.New("invalid resource kind: " + string(r.Kind))
+	}
+	if r.Capacity < 1 {
+		return errors.New("a resource has to take at least one ride at a time")
+	}
+	return nil
+}
+
+func (r *Resource) Save(store ResourceStore) error {
+	err := r.validate()
+	if err != nil {
+		return err
+	}
+	if r.ID > 0 {
+		return store.UpdateResource(r)
+	}
+	return store.InsertResource(r)
+}
+
+// GetResource returns the resource only if it belongs to barnID.
+func GetResource(barnID int64, id int64, store ResourceStore) (*Resource, error) {
+	r, err := store.GetResource(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.BarnID != barnID {
+		return nil, utils.ErrNotFound
+	}
+	return r, nil
+}
+
+func ListResources(barnID int64, store ResourceStore) ([]*Resource, error) {
+	return store.ListResourcesByBarn(barnID)
+}
+
+func DeleteResource(id int64, store ResourceStore) error {
+	return store.DeleteResource(id)
+}
+
+func GetResourceScheduleByRange(resourceID int64, from utils.Date, to utils.Date, store Store) ([]*Day, error) {
+	rides, err := store.ListRidesByResource(resourceID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := store.ListActiveSchedulesByResource(resourceID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return expand(rides, schedules, from, to, store)
+}
+
+// peakUse returns the rides in booked that are on together at the busiest
+// moment of proposed. A group lesson takes up the resource once however many
+// ride in it, so only one ride of each lesson is returned.
+func peakUse(proposed *Ride, booked []*RideDetail) []*RideDetail {
+	var sharing []*RideDetail
+	for _, other := range booked {
+		if overlaps(proposed, &other.Ride) {
+			sharing = append(sharing, other)
+		}
+	}
+	// the most rides are on at once at the start of the proposed ride or
+	// of one of those sharing it
+	var peak []*RideDetail
+	start, _ := proposed.span()
+	instants := []*RideDetail{nil}
+	instants = append(instants, sharing...)
+	for _, at := range instants {
+		t := start
+		if at != nil && at.StartsAt.After(start) {
+			t = *at.StartsAt
+		}
+		var on []*RideDetail
+		lessons := make(map[int64]bool)
+		for _, other := range sharing {
+			otherStart, otherEnd := other.span()
+			if t.Before(otherStart) || !t.Before(otherEnd) {
+				continue
+			}
+			if other.LessonID != nil {
+				if lessons[*other.LessonID] {
+					continue
+				}
+				lessons[*other.LessonID] = true
+			}
+			on = append(on, other)
+		}
+		if len(on) > len(peak) {
+			peak = on
+		}
+	}
+	return peak
+}
+
+// ResourceUse is what a resource has booked on a day, and the most rides it
+// has on at once.
+type ResourceUse struct {
+	Resource *Resource     `json:"resource"`
+	Rides    []*RideDetail `json:"rides"`
+	Peak     int           `json:"peak"`
+	// Over is true when Peak is more than the resource's capacity, as it can
+	// be after a save that overrides it.
+	Over bool `json:"over"`
+}
+
+// Timeline is a barn's day laid out by resource, with the rides that are not
+// booked into any.
+type Timeline struct {
+	Date       utils.Date     `json:"date"`
+	Resources  []*ResourceUse `json:"resources"`
+	Unassigned []*RideDetail  `json:"unassigned"`
+}
+
+// GetTimeline lays out the barn's rides on date by the resource they are
+// booked into. Cancelled rides are left out.
+func GetTimeline(barnID int64, date utils.Date, store Store) (*Timeline, error) {
+	resources, err := store.ListResourcesByBarn(barnID)
+	if err != nil {
+		return nil, err
+	}
+	days, err := GetScheduleByRange(barnID, date, date, store)
+	if err != nil {
+		return nil, err
+	}
+	rides := days[0].Rides
+	t := &Timeline{Date: date, Resources: []*ResourceUse{}, Unassigned: []*RideDetail{}}
+	uses := make(map[int64]*ResourceUse)
+	for _, resource := range resources {
+		use := &ResourceUse{Resource: resource, Rides: []*RideDetail{}}
+		uses[resource.ID] = use
+		t.Resources = append(t.Resources, use)
+	}
+	for _, r := range rides {
+		if r.Status == Cancelled {
+			continue
+		}
+		if r.ResourceID == nil || uses[*r.ResourceID] == nil {
+			t.Unassigned = append(t.Unassigned, r)
+			continue
+		}
+		use := uses[*r.ResourceID]
+		use.Rides = append(use.Rides, r)
+	}
+	for _, use := range t.Resources {
+		for _, r := range use.Rides {
+			if r.StartsAt == nil {
+				continue
+			}
+			if peak := len(peakUse(&r.Ride, use.Rides)); peak > use.Peak {
+				use.Peak = peak
+			}
+		}
+		use.Over = use.Peak > use.Resource.Capacity
+	}
+	sort.SliceStable(t.Resources, func(i, j int) bool {
+		return t.Resources[i].Resource.Name < t.Resources[j].Resource.Name
+	})
+	return t, nil
+}
@@ -1,15 +1,18 @@
 package rides
 
 import (
-	"database/sql"
 	"errors"
-	"reflect"
 	"sort"
 	"time"
 
+	"hack/recurrence"
 	"hack/utils"
 )
 
+// ErrOccurrenceLink is returned for a ride that names a schedule without the
+// occurrence it stands in for, or an occurrence without its schedule.
+var ErrOccurrenceLink = errors.New("a ride needs both a schedule_id and an occurrence_date, or neither")
+
 type Ride struct {
 	ID          int64       `json:"id,omitempty"`
 	HorseID     int64       `json:"horse_id"`
@@ -19,68 +22,242 @@ type Ride struct {
 	Time        *utils.Time `json:"time,omitempty"`
 	Notes       string      `json:"notes"`
 	Status      Status      `json:"status"`
+	// DurationMinutes of zero means the event type's duration. EndTime can
+	// be given instead of a duration and is filled in when rides are listed.
+	DurationMinutes int         `json:"duration_minutes,omitempty"`
+	EndTime         *utils.Time `json:"end_time,omitempty"`
+	// StartsAt and EndsAt are Date and Time in the barn's time zone, set on
+	// save and when rides are listed.
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	// ScheduleID and OccurrenceDate link a ride to the schedule occurrence it
+	// stands in for; the schedule no longer expands on that date.
+	ScheduleID     *int64      `json:"schedule_id,omitempty"`
+	OccurrenceDate *utils.Date `json:"occurrence_date,omitempty"`
+	// ResourceID is the arena or other resource the ride is booked into.
+	ResourceID *int64 `json:"resource_id,omitempty"`
+	// InstructorID is who teaches the ride. Left out, it is the instructor
+	// who leads the event type in the barn, if any.
+	InstructorID *int64 `json:"instructor_id,omitempty"`
+	// LessonID is the group lesson the ride is on the roster of. Its date,
+	// time, event type, instructor and resource are the lesson's.
+	LessonID *int64 `json:"lesson_id,omitempty"`
+	// Completion is set once the ride is completed.
+	Completion *Completion `json:"completion,omitempty"`
+	// Warnings are the limits of the horse's workload rule the ride broke
+	// when it was saved.
+	Warnings []*Violation `json:"warnings,omitempty"`
 }
 
 type Status string
 
 const (
 	Scheduled Status = "scheduled"
-	Cancelled Status = "cancelled"
+	CheckedIn Status = "checked_in"
 	Completed Status = "completed"
+	NoShow    Status = "no_show"
+	Cancelled Status = "cancelled"
 )
 
-func (r *Ride) Save(db *sql.DB) error {
-	// set default status
+type RideStore interface {
+	GetRide(id int64) (*Ride, error)
+	InsertRide(r *Ride) error
+	UpdateRide(r *Ride) error
+	UpdateRideStatus(id int64, status Status) error
+	// ListRidesByBarn, ListRidesByHorse and ListRidesByRider return the rides
+	// dated from from to to, both included.
+	ListRidesByBarn(barnID int64, from utils.Date, to utils.Date) ([]*RideDetail, error)
+	ListRidesByHorse(horseID int64, from utils.Date, to utils.Date) ([]*RideDetail, error)
+	ListRidesByRider(riderID int64, from utils.Date, to utils.Date) ([]*RideDetail, error)
+	// GetOccurrenceRide returns the ride linked to the occurrence of
+	// scheduleID on date.
+	GetOccurrenceRide(scheduleID int64, date utils.Date) (*Ride, error)
+	// ListOccurrenceRides returns the rides linked to occurrences of the
+	// given schedules between from and to.
+	ListOccurrenceRides(scheduleIDs []int64, from utils.Date, to utils.Date) ([]*Ride, error)
+	// MoveOccurrenceRides relinks the rides of occurrences on or after from
+	// to another schedule.
+	MoveOccurrenceRides(fromScheduleID int64, toScheduleID int64, from utils.Date) error
+}
+
+type ScheduleStore interface {
+	GetSchedule(id int64) (*Schedule, error)
+	InsertSchedule(s *Schedule) error
+	UpdateSchedule(s *Schedule) error
+	DeleteSchedule(id int64) error
+	ListSchedulesByBarn(barnID int64) ([]*Schedule, error)
+	// ListActiveSchedulesByBarn returns the schedules of a barn that have
+	// started on or before to and have not ended before from.
+	ListActiveSchedulesByBarn(barnID int64, from utils.Date, to utils.Date) ([]*Schedule, error)
+	ListActiveSchedulesByHorse(horseID int64, from utils.Date, to utils.Date) ([]*Schedule, error)
+	ListActiveSchedulesByRider(riderID int64, from utils.Date, to utils.Date) ([]*Schedule, error)
+}
+
+// Store is everything the ride schedule needs from persistence.
+type Store interface {
+	AvailabilityStore
+	EventTypeStore
+	InstructorStore
+	LessonStore
+	ResourceStore
+	RideStore
+	ScheduleStore
+	StatusStore
+	TimeZoneStore
+	UnavailabilityStore
+	WaitlistStore
+	WorkloadStore
+}
+
+// Save stores the ride, refusing with a *ConflictError to double-book its
+// horse, rider or instructor or to overfill its resource, or with a
+// *WorkloadError to break a blocking workload rule, unless override is set.
+// Limits it breaks otherwise end up in Warnings. The status of a saved ride
+// only changes through Transition; a new one starts out scheduled, or
+// cancelled when it records a lesson that did not happen. A ride joining a
+// group lesson gets ErrLessonFull when the lesson has no room for it, is
+// refused for a horse or rider from another barn, and takes the rider off
+// the lesson's waitlist.
+func (r *Ride) Save(store Store, override bool) error {
+	if (r.ScheduleID == nil) != (r.OccurrenceDate == nil) {
+		return ErrOccurrenceLink
+	}
+	var existing *Ride
+	if r.ID > 0 {
+		var err error
+		existing, err = store.GetRide(r.ID)
+		if err != nil {
+			return err
+		}
+		r.Status = existing.Status
+	}
 	if r.Status == "" {
 		r.Status = Scheduled
 	}
-
-	if r.EventTypeID == 0 {
-		r.EventTypeID = 10 // Generic event type
+	if r.ID == 0 && r.Status != Scheduled && r.Status != Cancelled {
+		return errors.New("a new ride has to be scheduled or cancelled")
 	}
-
-	if r.ID == 0 {
-		query := "insert into rides (horse_id, rider_id, event_type_id, date, time, notes, status) values (?, ?, ?, ?, ?, ?, ?)"
-		result, err := db.Exec(query, r.HorseID, r.RiderID, r.EventTypeID, r.Date.Format("2006-01-02"), r.Time, r.Notes, r.Status)
+	joining := r.LessonID != nil && r.Status != Cancelled && (existing == nil || existing.LessonID == nil || *existing.LessonID != *r.LessonID)
+	if joining {
+		err := checkRoom(*r.LessonID, r.RiderID, store)
 		if err != nil {
-			return errors.New("failed to insert ride into database: " + err.Error())
+			return err
 		}
-		r.ID, err = result.LastInsertId()
+		lesson, err := store.GetLesson(*r.LessonID)
 		if err != nil {
-			return errors.New("failed to get last insert ID: " + err.Error())
+			return err
 		}
-	} else {
-		query := "update rides set horse_id = ?, rider_id = ?, event_type_id = ?, date = ?, time = ?, notes = ?, status = ? where id = ?"
-		_, err := db.Exec(query, r.HorseID, r.RiderID, r.EventTypeID, r.Date.Format("2006-01-02"), r.Time, r.Notes, r.Status, r.ID)
+		err = checkLessonBarn(lesson, r.HorseID, r.RiderID, store)
 		if err != nil {
-			return errors.New("failed to update ride in database: " + err.Error())
+			return err
 		}
 	}
-	return nil
+	err := r.prepare(store)
+	if err != nil {
+		return err
+	}
+	p, err := r.propose(store)
+	if err != nil {
+		return err
+	}
+	if !override && p != nil {
+		err := checkConflicts(findConflicts(p, r.RiderID, store))
+		if err != nil {
+			return err
+		}
+	}
+	r.Warnings, err = checkWorkload(p, override, store)
+	if err != nil {
+		return err
+	}
+
+	if r.ID == 0 {
+		err = store.InsertRide(r)
+	} else {
+		err = store.UpdateRide(r)
+	}
+	if err != nil || !joining {
+		return err
+	}
+	return claimWaitlist(r, store)
 }
 
-func (r *Ride) Cancel(db *sql.DB) error {
-	r.Status = Cancelled
-	if r.ID > 0 {
-		query := "update rides set status = ? where id = ?"
-		_, err := db.Exec(query, r.Status, r.ID)
+// Check fills in the ride as Save would and returns what it would
+// double-book, without saving it.
+func (r *Ride) Check(store Store) ([]*Conflict, error) {
+	if (r.ScheduleID == nil) != (r.OccurrenceDate == nil) {
+		return nil, ErrOccurrenceLink
+	}
+	err := r.prepare(store)
+	if err != nil {
+		return nil, err
+	}
+	return r.FindConflicts(store)
+}
+
+func (r *Ride) prepare(store Store) error {
+	if r.LessonID != nil {
+		lesson, err := store.GetLesson(*r.LessonID)
 		if err != nil {
-			return errors.New("failed to set ride to cancelled status: " + err.Error())
+			return err
 		}
-		return nil
+		lesson.apply(r)
 	}
-	query := "insert into rides (horse_id, rider_id, event_type_id, date, time, status) values (?, ?, ?, ?, ?, ?)"
-	result, err := db.Exec(query, r.HorseID, r.RiderID, r.EventTypeID, r.Date.Format("2006-01-02"), r.Time, r.Status)
+	r.setDefaultEventType()
+	var err error
+	r.DurationMinutes, err = durationFromEndTime(r.Time, r.EndTime, r.DurationMinutes)
 	if err != nil {
-		return errors.New("failed to insert cancelled ride into database: " + err.Error())
+		return err
 	}
-	r.ID, err = result.LastInsertId()
+	// a lesson without an instructor has none for any of its rides
+	if r.LessonID == nil {
+		r.InstructorID, err = defaultInstructor(r.InstructorID, r.HorseID, r.EventTypeID, store)
+		if err != nil {
+			return err
+		}
+	}
+	loc, err := horseLocation(r.HorseID, store)
 	if err != nil {
-		return errors.New("failed to get last insert ID: " + err.Error())
+		return err
 	}
+	r.StartsAt = nil
+	r.locate(loc)
 	return nil
 }
 
+func (r *Ride) setDefaultEventType() {
+	if r.EventTypeID == 0 {
+		r.EventTypeID = 10 // Generic event type
+	}
+}
+
+// Cancel cancels the ride on behalf of actorID. A ride given by the
+// occurrence it stands in for is looked up first, and one that was never
+// saved is saved cancelled. Cancelling a cancelled ride does nothing.
+func (r *Ride) Cancel(actorID int64, store Store) error {
+	r.setDefaultEventType()
+	var existing *Ride
+	var err error
+	if r.ID > 0 {
+		existing, err = store.GetRide(r.ID)
+	} else if r.ScheduleID != nil && r.OccurrenceDate != nil {
+		existing, err = store.GetOccurrenceRide(*r.ScheduleID, *r.OccurrenceDate)
+	}
+	if err != nil && err != utils.ErrNotFound {
+		return err
+	}
+	if existing != nil {
+		*r = *existing
+	} else {
+		r.ID = 0
+		r.Status = Scheduled
+	}
+	if r.Status == Cancelled {
+		return nil
+	}
+	return r.Transition(Cancelled, actorID, store)
+}
+
 type Schedule struct {
 	ID        int64       `json:"id,omitempty"`
 	HorseID   int64       `json:"horse_id"`
@@ -91,180 +268,376 @@ type Schedule struct {
 	StartDate utils.Date  `json:"start_date"`
 	EndDate   *utils.Date `json:"end_date,omitempty"`
 	Time      *utils.Time `json:"time,omitempty"`
-	Sunday    bool        `json:"sunday"`
-	Monday    bool        `json:"monday"`
-	Tuesday   bool        `json:"tuesday"`
-	Wednesday bool        `json:"wednesday"`
-	Thursday  bool        `json:"thursday"`
-	Friday    bool        `json:"friday"`
-	Saturday  bool        `json:"saturday"`
+	// TimeZone is the barn's, which the start and end dates and the time
+	// are read in.
+	TimeZone string `json:"time_zone,omitempty"`
+	// DurationMinutes and EndTime work as they do on Ride.
+	DurationMinutes int         `json:"duration_minutes,omitempty"`
+	EndTime         *utils.Time `json:"end_time,omitempty"`
+	// RRule is an RFC 5545 recurrence rule counted from StartDate, such as
+	// FREQ=WEEKLY;BYDAY=MO,WE. The weekday flags are the older way of
+	// saying the same thing for weekly schedules; Save turns them into a rule.
+	RRule     string `json:"rrule"`
+	Sunday    bool   `json:"sunday"`
+	Monday    bool   `json:"monday"`
+	Tuesday   bool   `json:"tuesday"`
+	Wednesday bool   `json:"wednesday"`
+	Thursday  bool   `json:"thursday"`
+	Friday    bool   `json:"friday"`
+	Saturday  bool   `json:"saturday"`
+	// ResourceID and InstructorID work as they do on Ride.
+	ResourceID     *int64 `json:"resource_id,omitempty"`
+	ResourceName   string `json:"resource_name,omitempty"`
+	InstructorID   *int64 `json:"instructor_id,omitempty"`
+	InstructorName string `json:"instructor_name,omitempty"`
+	// Warnings work as they do on Ride.
+	Warnings []*Violation `json:"warnings,omitempty"`
 }
 
-func (s *Schedule) Save(db *sql.DB) error {
-	if s.ID == 0 {
-		query := "insert into schedules (horse_id, rider_id, event_type_id, start_date, time, sunday, monday, tuesday, wednesday, thursday, friday, saturday) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
-		result, err := db.Exec(query, s.HorseID, s.RiderID, s.EventType.ID, s.StartDate.Format("2006-01-02"), s.Time, s.Sunday, s.Monday, s.Tuesday, s.Wednesday, s.Thursday, s.Friday, s.Saturday)
-		if err != nil {
-			return errors.New("failed to insert schedule into database: " + err.Error())
-		}
-		s.ID, err = result.LastInsertId()
-		if err != nil {
-			return errors.New("failed to get last insert ID: " + err.Error())
-		}
-	} else {
-		query := "update schedules set horse_id = ?, rider_id = ?, event_type_id = ?, start_date = ?, time = ?, sunday = ?, monday = ?, tuesday = ?, wednesday = ?, thursday = ?, friday = ?, saturday = ? where id = ?"
-		_, err := db.Exec(query, s.HorseID, s.RiderID, s.EventType.ID, s.StartDate.Format("2006-01-02"), s.Time, s.Sunday, s.Monday, s.Tuesday, s.Wednesday, s.Thursday, s.Friday, s.Saturday, s.ID)
-		if err != nil {
-			return errors.New("failed to update schedule in database: " + err.Error())
-		}
+// Save stores the schedule, refusing with a *ConflictError to double-book its
+// horse, rider or instructor or to overfill its resource, or with a
+// *WorkloadError to break a blocking workload rule, unless override is set.
+func (s *Schedule) Save(store Store, override bool) error {
+	err := s.prepare()
+	if err != nil {
+		return err
 	}
-	// could improve this with custom value method for an end date type
-	if s.EndDate != nil && s.EndDate.After(s.StartDate.Time) {
-		query := "update schedules set end_date = ? where id = ?"
-		_, err := db.Exec(query, s.EndDate.Format("2006-01-02"), s.ID)
+	s.InstructorID, err = defaultInstructor(s.InstructorID, s.HorseID, s.EventType.ID, store)
+	if err != nil {
+		return err
+	}
+	p, err := s.propose(store)
+	if err != nil {
+		return err
+	}
+	if !override && p != nil {
+		err := checkConflicts(findConflicts(p, s.RiderID, store))
 		if err != nil {
-			return errors.New("failed to update schedule end date in database: " + err.Error())
+			return err
 		}
 	}
-	return nil
+	s.Warnings, err = checkWorkload(p, override, store)
+	if err != nil {
+		return err
+	}
+	if s.ID == 0 {
+		return store.InsertSchedule(s)
+	}
+	return store.UpdateSchedule(s)
 }
 
-func ListSchedules(barnID int64, db *sql.DB) ([]*Schedule, error) {
-	query := "select id, horse_id, (select name from horses where id = horse_id) horse_name, rider_id, (select name from riders where id = rider_id) rider_name, event_type_id, (select name from event_types where id = event_type_id) event_type_name, start_date, end_date, time, sunday, monday, tuesday, wednesday, thursday, friday, saturday from schedules where horse_id in (select id from horses where barn_id = ?) and rider_id in (select id from riders where barn_id = ?) order by start_date, time"
-	rows, err := db.Query(query, barnID, barnID)
+// Check fills in the schedule as Save would and returns what it would
+// double-book, without saving it.
+func (s *Schedule) Check(store Store) ([]*Conflict, error) {
+	err := s.prepare()
+	if err != nil {
+		return nil, err
+	}
+	s.InstructorID, err = defaultInstructor(s.InstructorID, s.HorseID, s.EventType.ID, store)
 	if err != nil {
-		return nil, errors.New("failed to query schedules from database: " + err.Error())
+		return nil, err
 	}
-	defer rows.Close()
+	return s.FindConflicts(store)
+}
 
-	var schedules []*Schedule
-	for rows.Next() {
-		var s Schedule
-		err := rows.Scan(&s.ID, &s.HorseID, &s.HorseName, &s.RiderID, &s.RiderName, &s.EventType.ID, &s.EventType.Name, &s.StartDate, &s.EndDate, &s.Time, &s.Sunday, &s.Monday, &s.Tuesday, &s.Wednesday, &s.Thursday, &s.Friday, &s.Saturday)
-		if err != nil {
-			return nil, errors.New("failed to scan schedule from database: " + err.Error())
+func (s *Schedule) prepare() error {
+	if s.RRule == "" {
+		s.RRule = s.weekdayRule()
+	}
+	if s.RRule == "" {
+		return errors.New("a schedule needs a recurrence rule or at least one weekday")
+	}
+	rule, err := recurrence.Parse(s.RRule)
+	if err != nil {
+		return errors.New("invalid recurrence rule: " + err.Error())
+	}
+	s.RRule = rule.String()
+	s.setWeekdays(rule)
+
+	if s.EventType.ID == 0 {
+		s.EventType.ID = 10 // Generic event type
+	}
+	s.DurationMinutes, err = durationFromEndTime(s.Time, s.EndTime, s.DurationMinutes)
+	if err != nil {
+		return err
+	}
+
+	// an end date on or before the start date is ignored
+	if s.EndDate != nil && !s.EndDate.After(s.StartDate.Time) {
+		s.EndDate = nil
+	}
+	// a rule that runs out ends the schedule, which keeps it out of the
+	// active schedule queries afterwards
+	last, ok := rule.Last(s.StartDate.Time)
+	if ok && (s.EndDate == nil || last.Before(s.EndDate.Time)) {
+		s.EndDate = &utils.Date{Time: last}
+	}
+	return nil
+}
+
+func (s *Schedule) Rule() (*recurrence.Rule, error) {
+	return recurrence.Parse(s.RRule)
+}
+
+func (s *Schedule) weekdayRule() string {
+	var days []time.Weekday
+	flags := []bool{s.Sunday, s.Monday, s.Tuesday, s.Wednesday, s.Thursday, s.Friday, s.Saturday}
+	for day, set := range flags {
+		if set {
+			days = append(days, time.Weekday(day))
 		}
-		schedules = append(schedules, &s)
 	}
-	return schedules, nil
+	if len(days) == 0 {
+		return ""
+	}
+	return recurrence.WeeklyOn(days...).String()
 }
 
-func DeleteSchedule(id int64, db *sql.DB) error {
-	query := "delete from schedules where id = ?"
-	_, err := db.Exec(query, id)
+// setWeekdays fills in the weekday flags for rules that repeat every week on
+// plain weekdays, so clients that only know the flags keep working.
+func (s *Schedule) setWeekdays(rule *recurrence.Rule) {
+	if rule.Freq != recurrence.Weekly || rule.Interval != 1 {
+		return
+	}
+	days := make([]bool, 7)
+	for _, day := range rule.ByDay {
+		days[day.Day] = true
+	}
+	if len(rule.ByDay) == 0 {
+		days[s.StartDate.Weekday()] = true
+	}
+	s.Sunday, s.Monday, s.Tuesday, s.Wednesday, s.Thursday, s.Friday, s.Saturday = days[0], days[1], days[2], days[3], days[4], days[5], days[6]
+}
+
+func ListSchedules(barnID int64, store ScheduleStore) ([]*Schedule, error) {
+	schedules, err := store.ListSchedulesByBarn(barnID)
 	if err != nil {
-		return errors.New("failed to delete schedule from database: " + err.Error())
+		return nil, err
 	}
-	return nil
+	for _, s := range schedules {
+		rule, err := s.Rule()
+		if err == nil {
+			s.setWeekdays(rule)
+		}
+		duration := s.DurationMinutes
+		if duration == 0 {
+			duration = s.EventType.DurationMinutes
+		}
+		s.EndTime = endTime(s.Time, duration)
+	}
+	return schedules, nil
+}
+
+func DeleteSchedule(id int64, store ScheduleStore) error {
+	return store.DeleteSchedule(id)
 }
 
 type RideDetail struct {
 	Ride
-	HorseName     string `json:"horse_name"`
-	RiderName     string `json:"rider_name"`
-	EventTypeName string `json:"event_type_name"`
+	HorseName      string `json:"horse_name"`
+	RiderName      string `json:"rider_name"`
+	EventTypeName  string `json:"event_type_name"`
+	ResourceName   string `json:"resource_name,omitempty"`
+	InstructorName string `json:"instructor_name,omitempty"`
+	OccurrenceID   string `json:"occurrence_id,omitempty"`
+	TimeZone       string `json:"time_zone,omitempty"`
+	// Blocked is the block that keeps the horse from this ride.
+	Blocked *Unavailability `json:"blocked,omitempty"`
 }
 
-func GetHorseScheduleByDay(horseID int64, date utils.Date, db *sql.DB) ([]*RideDetail, error) {
-	query := "select id, horse_id, (select name from horses where id = horse_id) horse_name, rider_id, (select name from riders where id = rider_id) rider_name, event_type_id, (select name from event_types where id = event_type_id) event_type_name, date, time, status from rides where horse_id = ? and date = ? order by time"
-	rows, err := db.Query(query, horseID, date.Format("2006-01-02"))
+// Day is one day of a schedule view. Lessons is only filled in by
+// GetScheduleByDay; everywhere else the rides of a lesson are listed with
+// the others.
+type Day struct {
+	Date    utils.Date    `json:"date"`
+	Rides   []*RideDetail `json:"rides"`
+	Lessons []*Lesson     `json:"lessons,omitempty"`
+}
+
+func GetHorseScheduleByDay(horseID int64, date utils.Date, store Store) ([]*RideDetail, error) {
+	days, err := GetHorseScheduleByRange(horseID, date, date, store)
 	if err != nil {
-		return nil, errors.New("failed to query schedules from database: " + err.Error())
+		return nil, err
 	}
-	defer rows.Close()
+	return days[0].Rides, nil
+}
 
-	var rides []*RideDetail
-	for rows.Next() {
-		var r RideDetail
-		err := rows.Scan(&r.ID, &r.HorseID, &r.HorseName, &r.RiderID, &r.RiderName, &r.EventTypeID, &r.EventTypeName, &r.Date, &r.Time, &r.Status)
-		if err != nil {
-			return nil, errors.New("failed to scan schedule from database: " + err.Error())
-		}
-		rides = append(rides, &r)
+// GetScheduleByDay returns the barn's day with the rides of its group lessons
+// gathered into Lessons rather than listed with the other rides.
+func GetScheduleByDay(barnID int64, date utils.Date, store Store) (*Day, error) {
+	days, err := GetScheduleByRange(barnID, date, date, store)
+	if err != nil {
+		return nil, err
 	}
-	schedulesQuery := "select horse_id, (select name from horses where id = horse_id) horse_name, rider_id, (select name from riders where id = rider_id) rider_name, event_type_id, (select name from event_types where id = event_type_id) event_type_name, time, sunday, monday, tuesday, wednesday, thursday, friday, saturday from schedules where horse_id = ? and start_date <= ? and (end_date is null or end_date >= ?) order by start_date, time"
-	scheduleRows, err := db.Query(schedulesQuery, horseID, date.Format("2006-01-02"), date.Format("2006-01-02"))
+	lessons, err := store.ListLessonsByBarn(barnID, date, date)
 	if err != nil {
-		return nil, errors.New("failed to query schedules from database: " + err.Error())
+		return nil, err
 	}
-	defer scheduleRows.Close()
-	for scheduleRows.Next() {
-		var s Schedule
-		err := scheduleRows.Scan(&s.HorseID, &s.HorseName, &s.RiderID, &s.RiderName, &s.EventType.ID, &s.EventType.Name, &s.Time, &s.Sunday, &s.Monday, &s.Tuesday, &s.Wednesday, &s.Thursday, &s.Friday, &s.Saturday)
-		if err != nil {
-			return nil, errors.New("failed to scan schedule row: " + err.Error())
-		}
+	return groupLessons(days[0], lessons), nil
+}
 
-		day := date.Weekday().String()
-		scheduleValue := reflect.ValueOf(&s)
-		field := scheduleValue.Elem().FieldByName(day)
+func GetScheduleByRange(barnID int64, from utils.Date, to utils.Date, store Store) ([]*Day, error) {
+	rides, err := store.ListRidesByBarn(barnID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := store.ListActiveSchedulesByBarn(barnID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return expand(rides, schedules, from, to, store)
+}
 
-		if field.Bool() {
-			rides = appendScheduledRide(&s, date, rides)
-		}
+func GetHorseScheduleByRange(horseID int64, from utils.Date, to utils.Date, store Store) ([]*Day, error) {
+	rides, err := store.ListRidesByHorse(horseID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := store.ListActiveSchedulesByHorse(horseID, from, to)
+	if err != nil {
+		return nil, err
 	}
+	return expand(rides, schedules, from, to, store)
+}
 
-	return rides, nil
+func GetRiderScheduleByRange(riderID int64, from utils.Date, to utils.Date, store Store) ([]*Day, error) {
+	rides, err := store.ListRidesByRider(riderID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := store.ListActiveSchedulesByRider(riderID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return expand(rides, schedules, from, to, store)
 }
 
-func GetScheduleByDay(barnID int64, date utils.Date, db *sql.DB) ([]*RideDetail, error) {
-	var rides []*RideDetail
-	ridesQuery := "select id, horse_id, (select name from horses where id = horse_id) horse_name, rider_id, (select name from riders where id = rider_id) rider_name, event_type_id, (select name from event_types where id = event_type_id) event_type_name, time, notes, status from rides where date = ? and horse_id in (select id from horses where barn_id = ?) and rider_id in (select id from riders where barn_id = ?) order by time"
-	mysqlDate := date.Format("2006-01-02")
-	rideRows, err := db.Query(ridesQuery, mysqlDate, barnID, barnID)
+// GetRidersScheduleByRange merges the schedules of several riders, as for a
+// parent with more than one child riding.
+func GetRidersScheduleByRange(riderIDs []int64, from utils.Date, to utils.Date, store Store) ([]*Day, error) {
+	days, err := expand(nil, nil, from, to, store)
 	if err != nil {
-		return nil, errors.New("failed to select rides from database: " + err.Error())
+		return nil, err
 	}
-	defer rideRows.Close()
-	for rideRows.Next() {
-		var r RideDetail
-		var notes sql.NullString
-		err := rideRows.Scan(&r.ID, &r.HorseID, &r.HorseName, &r.RiderID, &r.RiderName, &r.EventTypeID, &r.EventTypeName, &r.Time, &notes, &r.Status)
+	for _, id := range riderIDs {
+		riderDays, err := GetRiderScheduleByRange(id, from, to, store)
 		if err != nil {
-			return nil, errors.New("failed to scan ride row: " + err.Error())
+			return nil, err
 		}
-		if notes.Valid {
-			r.Notes = notes.String
+		for i := range days {
+			days[i].Rides = append(days[i].Rides, riderDays[i].Rides...)
 		}
-		r.Date = date
-		rides = append(rides, &r)
 	}
+	for _, day := range days {
+		sortRides(day.Rides)
+	}
+	return days, nil
+}
 
-	schedulesQuery := "select horse_id, (select name from horses where id = horse_id) horse_name, rider_id, (select name from riders where id = rider_id) rider_name, event_type_id, (select name from event_types where id = event_type_id) event_type_name, end_date, time, sunday, monday, tuesday, wednesday, thursday, friday, saturday from schedules where start_date <= ? and horse_id in (select id from horses where barn_id = ?) and rider_id in (select id from riders where barn_id = ?) order by time"
-	rows, err := db.Query(schedulesQuery, mysqlDate, barnID, barnID)
+// Occurrences returns the dates from from to to that the schedule occurs on.
+func (s *Schedule) Occurrences(from utils.Date, to utils.Date) []utils.Date {
+	if s.EndDate != nil && to.After(s.EndDate.Time) {
+		to = *s.EndDate
+	}
+	rule, err := s.Rule()
 	if err != nil {
-		return nil, errors.New("failed to select schedules from database: " + err.Error())
+		return nil
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var s Schedule
-		var endDate *time.Time
-		err := rows.Scan(&s.HorseID, &s.HorseName, &s.RiderID, &s.RiderName, &s.EventType.ID, &s.EventType.Name, &endDate, &s.Time, &s.Sunday, &s.Monday, &s.Tuesday, &s.Wednesday, &s.Thursday, &s.Friday, &s.Saturday)
-		if err != nil {
-			return nil, errors.New("failed to scan schedule row: " + err.Error())
+	var dates []utils.Date
+	for _, date := range rule.Between(s.StartDate.Time, from.Time, to.Time) {
+		dates = append(dates, utils.Date{Time: date})
+	}
+	return dates
+}
+
+// OccursOn reports whether the schedule's rule has an occurrence on date.
+func (s *Schedule) OccursOn(date utils.Date) bool {
+	return len(s.Occurrences(date, date)) > 0
+}
+
+// expand lays out rides and the occurrences of schedules that have not been
+// replaced by a ride of their own over every day from from to to, marking
+// those whose horse is blocked. Each schedule is expanded once for the whole
+// range.
+func expand(rides []*RideDetail, schedules []*Schedule, from utils.Date, to utils.Date, store Store) ([]*Day, error) {
+	var days []*Day
+	byDate := make(map[string]*Day)
+	for date := from.Time; !date.After(to.Time); date = date.AddDate(0, 0, 1) {
+		day := &Day{Date: utils.Date{Time: date}, Rides: []*RideDetail{}}
+		days = append(days, day)
+		byDate[date.Format("2006-01-02")] = day
+	}
+	for _, r := range rides {
+		day, ok := byDate[r.Date.Format("2006-01-02")]
+		if ok {
+			day.Rides = append(day.Rides, r)
 		}
-		if endDate != nil {
-			s.EndDate = &utils.Date{Time: *endDate}
+	}
+
+	var ids []int64
+	for _, s := range schedules {
+		ids = append(ids, s.ID)
+	}
+	replaced, err := store.ListOccurrenceRides(ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range schedules {
+		for _, date := range s.Occurrences(from, to) {
+			if isReplaced(s.ID, date, replaced) {
+				continue
+			}
+			day := byDate[date.Format("2006-01-02")]
+			day.Rides = appendScheduledRide(s, date, day.Rides)
 		}
+	}
 
-		day := date.Weekday().String()
-		scheduleValue := reflect.ValueOf(&s)
-		field := scheduleValue.Elem().FieldByName(day)
+	blocks, err := store.ListUnavailabilities(horseIDs(days), from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range days {
+		setOccurrenceIDs(day.Rides)
+		for _, r := range day.Rides {
+			r.locate(utils.Location(r.TimeZone))
+			if r.Status != Cancelled {
+				r.Blocked = blockFor(blocks, r.HorseID, day.Date)
+			}
+		}
+		sortRides(day.Rides)
+	}
+	return days, nil
+}
 
-		if s.EndDate != nil {
-			if date.Before(s.EndDate.Time) && field.Bool() {
-				rides = appendScheduledRide(&s, date, rides)
+func horseIDs(days []*Day) []int64 {
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, day := range days {
+		for _, r := range day.Rides {
+			if !seen[r.HorseID] {
+				seen[r.HorseID] = true
+				ids = append(ids, r.HorseID)
 			}
-		} else if field.Bool() {
-			rides = appendScheduledRide(&s, date, rides)
 		}
 	}
-	sortRides(rides)
-	return rides, nil
+	return ids
+}
+
+func isReplaced(scheduleID int64, date utils.Date, replaced []*Ride) bool {
+	for _, r := range replaced {
+		if r.ScheduleID != nil && r.OccurrenceDate != nil && *r.ScheduleID == scheduleID && r.OccurrenceDate.Equal(date.Time) {
+			return true
+		}
+	}
+	return false
 }
 
 func appendScheduledRide(s *Schedule, date utils.Date, rides []*RideDetail) []*RideDetail {
 	var r RideDetail
+	scheduleID := s.ID
+	occurrenceDate := date
+	r.ScheduleID = &scheduleID
+	r.OccurrenceDate = &occurrenceDate
 	r.Date = date
 	r.Status = Scheduled
 	r.HorseID = s.HorseID
@@ -273,7 +646,16 @@ func appendScheduledRide(s *Schedule, date utils.Date, rides []*RideDetail) []*R
 	r.RiderName = s.RiderName
 	r.EventTypeID = s.EventType.ID
 	r.EventTypeName = s.EventType.Name
+	r.ResourceID = s.ResourceID
+	r.ResourceName = s.ResourceName
+	r.InstructorID = s.InstructorID
+	r.InstructorName = s.InstructorName
 	r.Time = s.Time
+	r.TimeZone = s.TimeZone
+	r.DurationMinutes = s.DurationMinutes
+	if r.DurationMinutes == 0 {
+		r.DurationMinutes = s.EventType.DurationMinutes
+	}
 	found := areHorseAndRiderPresent(&r, rides)
 	if !found {
 		rides = append(rides, &r)
@@ -283,13 +665,22 @@ func appendScheduledRide(s *Schedule, date utils.Date, rides []*RideDetail) []*R
 
 func areHorseAndRiderPresent(ride *RideDetail, rides []*RideDetail) bool {
 	for _, r := range rides {
-		if r.HorseID == ride.HorseID && r.RiderID == ride.RiderID && r.Time == ride.Time {
+		if r.HorseID == ride.HorseID && r.RiderID == ride.RiderID && sameTime(r.Time, ride.Time) {
 			return true
 		}
 	}
 	return false
 }
 
+func sameTime(a *utils.Time, b *utils.Time) bool {
+	aSet := a != nil && a.Valid
+	bSet := b != nil && b.Valid
+	if !aSet || !bSet {
+		return aSet == bSet
+	}
+	return a.Time.Equal(b.Time)
+}
+
 // I hate this function, but I don't know how to do it better right now
 func sortRides(rides []*RideDetail) {
 	sort.SliceStable(rides, func(i, j int) bool {
@@ -303,6 +694,10 @@ func sortRides(rides []*RideDetail) {
 		if rides[i].Time == nil && rides[j].Time == nil {
 			return true
 		}
+		// rides starting together go shortest first
+		if rides[i].Time.Time.Equal(rides[j].Time.Time) {
+			return rides[i].DurationMinutes < rides[j].DurationMinutes
+		}
 		return rides[i].Time.Time.Before(rides[j].Time.Time)
 	})
 }
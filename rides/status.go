@@ -0,0 +1,165 @@
+package rides
+
+import (
+	"errors"
+	"time"
+
+	"hack/utils"
+)
+
+// transitions lists the statuses a ride can move to from each status. A
+// cancelled ride goes back on the schedule when its occurrence is moved.
+var transitions = map[Status][]Status{
+	Scheduled: {CheckedIn, NoShow, Cancelled},
+	CheckedIn: {Completed, Cancelled},
+	Cancelled: {Scheduled},
+}
+
+func (s Status) CanBecome(to Status) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is returned for a move the workflow does not allow.
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e *TransitionError) Error() string {
+	return "cannot change a " + string(e.From) + " ride to " + string(e.To)
+}
+
+// StatusChange records one move of a ride from one status to another.
+type StatusChange struct {
+	ID        int64     `json:"id"`
+	RideID    int64     `json:"ride_id"`
+	From      Status    `json:"from"`
+	To        Status    `json:"to"`
+	ActorID   int64     `json:"actor_id"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type StatusStore interface {
+	InsertStatusChange(c *StatusChange) error
+	ListStatusChanges(rideID int64) ([]*StatusChange, error)
+	UpdateRideCompletion(id int64, c *Completion) error
+}
+
+type Intensity string
+
+const (
+	Light    Intensity = "light"
+	Moderate Intensity = "moderate"
+	Hard     Intensity = "hard"
+)
+
+// Completion is what the trainer records about a finished ride.
+type Completion struct {
+	// ActualMinutes of zero means the ride took as long as planned.
+	ActualMinutes int       `json:"actual_minutes,omitempty"`
+	Intensity     Intensity `json:"intensity,omitempty"`
+	TrainerNotes  string    `json:"trainer_notes,omitempty"`
+	// BehaviorRating rates the horse from 1 (poor) to 5 (excellent).
+	BehaviorRating int `json:"behavior_rating,omitempty"`
+}
+
+func (c *Completion) validate() error {
+	if c.ActualMinutes < 0 {
+		return errors.New("actual duration cannot be negative")
+	}
+	switch c.Intensity {
+	case "", Light, Moderate, Hard:
+	default:
+		return errors.New("invalid intensity: " + string(c.Intensity))
+	}
+	if c.BehaviorRating < 0 || c.BehaviorRating > 5 {
+		return errors.New("behavior rating must be from 1 to 5")
+	}
+	return nil
+}
+
+// Transition moves the ride to status to on behalf of actorID. A ride that
+// has not been saved yet, like an occurrence nobody has edited, is saved
+// with its new status. A cancelled ride of a group lesson offers its spot to
+// the lesson's waitlist.
+func (r *Ride) Transition(to Status, actorID int64, store Store) error {
+	if r.Status == "" {
+		r.Status = Scheduled
+	}
+	if !r.Status.CanBecome(to) {
+		return &TransitionError{From: r.Status, To: to}
+	}
+	change := &StatusChange{
+		From:      r.Status,
+		To:        to,
+		ActorID:   actorID,
+		ChangedAt: time.Now().UTC(),
+	}
+	r.Status = to
+	var err error
+	if r.ID == 0 {
+		err = r.prepare(store)
+		if err == nil {
+			err = store.InsertRide(r)
+		}
+	} else {
+		err = store.UpdateRideStatus(r.ID, to)
+	}
+	if err != nil {
+		return err
+	}
+	change.RideID = r.ID
+	err = store.InsertStatusChange(change)
+	if err != nil || to != Cancelled || r.LessonID == nil {
+		return err
+	}
+	return fillLesson(*r.LessonID, store)
+}
+
+// Complete finishes the ride with what the trainer recorded. A ride nobody
+// checked in is checked in on the way.
+func (r *Ride) Complete(completion Completion, actorID int64, store Store) error {
+	err := completion.validate()
+	if err != nil {
+		return err
+	}
+	if r.Status == "" || r.Status == Scheduled {
+		err = r.Transition(CheckedIn, actorID, store)
+		if err != nil {
+			return err
+		}
+	}
+	err = r.Transition(Completed, actorID, store)
+	if err != nil {
+		return err
+	}
+	r.Completion = &completion
+	return store.UpdateRideCompletion(r.ID, r.Completion)
+}
+
+func ListStatusChanges(rideID int64, store StatusStore) ([]*StatusChange, error) {
+	return store.ListStatusChanges(rideID)
+}
+
+// TransitionOccurrence moves the occurrence of s on date to status to,
+// saving it as a ride of its own first if needed.
+func TransitionOccurrence(s *Schedule, date utils.Date, to Status, actorID int64, store Store) (*Ride, error) {
+	r, err := occurrenceRide(s, date, store)
+	if err != nil {
+		return nil, err
+	}
+	return r, r.Transition(to, actorID, store)
+}
+
+func CompleteOccurrence(s *Schedule, date utils.Date, completion Completion, actorID int64, store Store) (*Ride, error) {
+	r, err := occurrenceRide(s, date, store)
+	if err != nil {
+		return nil, err
+	}
+	return r, r.Complete(completion, actorID, store)
+}
@@ -0,0 +1,305 @@
+package rides
+
+import (
+	"time"
+
+	"hack/utils"
+)
+
+// memoryStore keeps rides, schedules, lessons, waitlists and workload rules
+// in memory, every horse in the same time zone and every event type lasting
+// DefaultDurationMinutes, which rides and schedules are listed with as the
+// SQL store lists them. It embeds Store and only implements what the tests
+// reach; anything else panics.
+type memoryStore struct {
+	Store
+	zone      string
+	rides     []*Ride
+	schedules []*Schedule
+	lessons   []*Lesson
+	waitlist  []*WaitlistEntry
+	rules     map[int64]*WorkloadRule
+	changes   []*StatusChange
+	// failMove is what MoveOccurrenceRides fails with, if anything.
+	failMove error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{zone: "America/New_York", rules: make(map[int64]*WorkloadRule)}
+}
+
+func date(s string) utils.Date {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return utils.Date{Time: t}
+}
+
+func clock(hour int, minute int) *utils.Time {
+	t := &utils.Time{}
+	t.Valid = true
+	t.Time = time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+	return t
+}
+
+func (m *memoryStore) GetHorseTimeZone(horseID int64) (string, error) {
+	return m.zone, nil
+}
+
+func (m *memoryStore) GetEventType(id int64) (*EventType, error) {
+	return nil, utils.ErrNotFound
+}
+
+func (m *memoryStore) DefaultInstructor(horseID int64, eventTypeID int64) (*int64, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) ListUnavailabilities(horseIDs []int64, from utils.Date, to utils.Date) ([]*Unavailability, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) GetWorkloadRule(horseID int64) (*WorkloadRule, error) {
+	w, ok := m.rules[horseID]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	return w, nil
+}
+
+func (m *memoryStore) GetRide(id int64) (*Ride, error) {
+	for _, r := range m.rides {
+		if r.ID == id {
+			ride := *r
+			return &ride, nil
+		}
+	}
+	return nil, utils.ErrNotFound
+}
+
+func (m *memoryStore) InsertRide(r *Ride) error {
+	r.ID = int64(len(m.rides) + 1)
+	ride := *r
+	m.rides = append(m.rides, &ride)
+	return nil
+}
+
+func (m *memoryStore) UpdateRide(r *Ride) error {
+	for i, existing := range m.rides {
+		if existing.ID == r.ID {
+			ride := *r
+			m.rides[i] = &ride
+			return nil
+		}
+	}
+	return utils.ErrNotFound
+}
+
+func (m *memoryStore) UpdateRideStatus(id int64, status Status) error {
+	for _, r := range m.rides {
+		if r.ID == id {
+			r.Status = status
+			return nil
+		}
+	}
+	return utils.ErrNotFound
+}
+
+func (m *memoryStore) InsertStatusChange(c *StatusChange) error {
+	m.changes = append(m.changes, c)
+	return nil
+}
+
+func (m *memoryStore) listRides(from utils.Date, to utils.Date, keep func(*Ride) bool) []*RideDetail {
+	var found []*RideDetail
+	for _, r := range m.rides {
+		if keep(r) && !r.Date.Before(from.Time) && !r.Date.After(to.Time) {
+			d := &RideDetail{Ride: *r, TimeZone: m.zone}
+			if d.DurationMinutes == 0 {
+				d.DurationMinutes = DefaultDurationMinutes
+			}
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+func (m *memoryStore) ListRidesByHorse(horseID int64, from utils.Date, to utils.Date) ([]*RideDetail, error) {
+	return m.listRides(from, to, func(r *Ride) bool { return r.HorseID == horseID }), nil
+}
+
+func (m *memoryStore) ListRidesByRider(riderID int64, from utils.Date, to utils.Date) ([]*RideDetail, error) {
+	return m.listRides(from, to, func(r *Ride) bool { return r.RiderID == riderID }), nil
+}
+
+func (m *memoryStore) ListRidesByInstructor(instructorID int64, from utils.Date, to utils.Date) ([]*RideDetail, error) {
+	return m.listRides(from, to, func(r *Ride) bool { return r.InstructorID != nil && *r.InstructorID == instructorID }), nil
+}
+
+func (m *memoryStore) GetOccurrenceRide(scheduleID int64, date utils.Date) (*Ride, error) {
+	for _, r := range m.rides {
+		if r.ScheduleID != nil && *r.ScheduleID == scheduleID && r.OccurrenceDate.Equal(date.Time) {
+			ride := *r
+			return &ride, nil
+		}
+	}
+	return nil, utils.ErrNotFound
+}
+
+func (m *memoryStore) ListOccurrenceRides(scheduleIDs []int64, from utils.Date, to utils.Date) ([]*Ride, error) {
+	var found []*Ride
+	for _, r := range m.rides {
+		if r.ScheduleID == nil || r.OccurrenceDate.Before(from.Time) || r.OccurrenceDate.After(to.Time) {
+			continue
+		}
+		for _, id := range scheduleIDs {
+			if *r.ScheduleID == id {
+				ride := *r
+				found = append(found, &ride)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (m *memoryStore) MoveOccurrenceRides(fromScheduleID int64, toScheduleID int64, from utils.Date) error {
+	if m.failMove != nil {
+		return m.failMove
+	}
+	for _, r := range m.rides {
+		if r.ScheduleID != nil && *r.ScheduleID == fromScheduleID && !r.OccurrenceDate.Before(from.Time) {
+			id := toScheduleID
+			r.ScheduleID = &id
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) GetSchedule(id int64) (*Schedule, error) {
+	for _, s := range m.schedules {
+		if s.ID == id {
+			schedule := *s
+			return &schedule, nil
+		}
+	}
+	return nil, utils.ErrNotFound
+}
+
+func (m *memoryStore) InsertSchedule(s *Schedule) error {
+	s.ID = 1
+	if len(m.schedules) > 0 {
+		s.ID = m.schedules[len(m.schedules)-1].ID + 1
+	}
+	schedule := *s
+	m.schedules = append(m.schedules, &schedule)
+	return nil
+}
+
+func (m *memoryStore) UpdateSchedule(s *Schedule) error {
+	for i, existing := range m.schedules {
+		if existing.ID == s.ID {
+			schedule := *s
+			m.schedules[i] = &schedule
+			return nil
+		}
+	}
+	return utils.ErrNotFound
+}
+
+func (m *memoryStore) DeleteSchedule(id int64) error {
+	for i, s := range m.schedules {
+		if s.ID == id {
+			m.schedules = append(m.schedules[:i], m.schedules[i+1:]...)
+			return nil
+		}
+	}
+	return utils.ErrNotFound
+}
+
+func (m *memoryStore) listSchedules(from utils.Date, to utils.Date, keep func(*Schedule) bool) []*Schedule {
+	var found []*Schedule
+	for _, s := range m.schedules {
+		if keep(s) && !s.StartDate.After(to.Time) && (s.EndDate == nil || !s.EndDate.Before(from.Time)) {
+			schedule := *s
+			schedule.TimeZone = m.zone
+			schedule.EventType.DurationMinutes = DefaultDurationMinutes
+			found = append(found, &schedule)
+		}
+	}
+	return found
+}
+
+func (m *memoryStore) ListActiveSchedulesByHorse(horseID int64, from utils.Date, to utils.Date) ([]*Schedule, error) {
+	return m.listSchedules(from, to, func(s *Schedule) bool { return s.HorseID == horseID }), nil
+}
+
+func (m *memoryStore) ListActiveSchedulesByRider(riderID int64, from utils.Date, to utils.Date) ([]*Schedule, error) {
+	return m.listSchedules(from, to, func(s *Schedule) bool { return s.RiderID == riderID }), nil
+}
+
+func (m *memoryStore) ListActiveSchedulesByInstructor(instructorID int64, from utils.Date, to utils.Date) ([]*Schedule, error) {
+	return m.listSchedules(from, to, func(s *Schedule) bool { return s.InstructorID != nil && *s.InstructorID == instructorID }), nil
+}
+
+func (m *memoryStore) GetLesson(id int64) (*Lesson, error) {
+	for _, l := range m.lessons {
+		if l.ID == id {
+			lesson := *l
+			return &lesson, nil
+		}
+	}
+	return nil, utils.ErrNotFound
+}
+
+func (m *memoryStore) ListLessonRides(lessonID int64) ([]*RideDetail, error) {
+	var roster []*RideDetail
+	for _, r := range m.rides {
+		if r.LessonID != nil && *r.LessonID == lessonID {
+			roster = append(roster, &RideDetail{Ride: *r, TimeZone: m.zone})
+		}
+	}
+	return roster, nil
+}
+
+func (m *memoryStore) HorseAndRiderInBarn(barnID int64, horseID int64, riderID int64) (bool, error) {
+	return true, nil
+}
+
+func (m *memoryStore) InsertWaitlistEntry(e *WaitlistEntry) error {
+	e.ID = int64(len(m.waitlist) + 1)
+	entry := *e
+	m.waitlist = append(m.waitlist, &entry)
+	return nil
+}
+
+func (m *memoryStore) UpdateWaitlistEntry(e *WaitlistEntry) error {
+	for i, existing := range m.waitlist {
+		if existing.ID == e.ID {
+			entry := *e
+			m.waitlist[i] = &entry
+			return nil
+		}
+	}
+	return utils.ErrNotFound
+}
+
+func (m *memoryStore) GetWaitlistEntry(id int64) (*WaitlistEntry, error) {
+	for _, e := range m.waitlist {
+		if e.ID == id {
+			entry := *e
+			return &entry, nil
+		}
+	}
+	return nil, utils.ErrNotFound
+}
+
+func (m *memoryStore) ListWaitlist(lessonID int64) ([]*WaitlistEntry, error) {
+	var entries []*WaitlistEntry
+	for _, e := range m.waitlist {
+		if e.LessonID == lessonID {
+			entry := *e
+			entries = append(entries, &entry)
+		}
+	}
+	return entries, nil
+}
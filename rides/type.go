@@ -1,13 +1,17 @@
 package rides
 
 import (
-	"database/sql"
 	"errors"
 )
 
+// DefaultDurationMinutes is how long an event type's rides last unless it
+// says otherwise.
+const DefaultDurationMinutes = 60
+
 type EventType struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	DurationMinutes int    `json:"duration_minutes"`
 }
 
 type Name string
@@ -25,35 +29,22 @@ func (n *Name) Scan(value interface{}) error {
 	}
 }
 
-func (t *EventType) Save(db *sql.DB) error {
-	query := "insert into event_types (name) values (?)"
-	result, err := db.Exec(query, t.Name)
-	if err != nil {
-		return errors.New("failed to insert event type into database: " + err.Error())
-	}
-	id, err := result.LastInsertId()
-	if err != nil {
-		return errors.New("failed to get last insert id: " + err.Error())
-	}
-	t.ID = id
-	return nil
+type EventTypeStore interface {
+	GetEventType(id int64) (*EventType, error)
+	InsertEventType(t *EventType) error
+	ListEventTypes() ([]EventType, error)
 }
 
-func ListEventTypes(db *sql.DB) ([]EventType, error) {
-	query := "select id, name from event_types"
-	rows, err := db.Query(query)
-	if err != nil {
-		return nil, errors.New("failed to query event types: " + err.Error())
+func (t *EventType) Save(store EventTypeStore) error {
+	if t.DurationMinutes < 0 {
+		return errors.New("duration cannot be negative")
 	}
-	defer rows.Close()
-	var types []EventType
-	for rows.Next() {
-		var t EventType
-		err = rows.Scan(&t.ID, &t.Name)
-		if err != nil {
-			return nil, errors.New("failed to scan event type: " + err.Error())
-		}
-		types = append(types, t)
+	if t.DurationMinutes == 0 {
+		t.DurationMinutes = DefaultDurationMinutes
 	}
-	return types, nil
+	return store.InsertEventType(t)
+}
+
+func ListEventTypes(store EventTypeStore) ([]EventType, error) {
+	return store.ListEventTypes()
 }
@@ -0,0 +1,230 @@
+package rides
+
+import (
+	"errors"
+	"time"
+
+	"hack/recurrence"
+	"hack/utils"
+)
+
+type UnavailableReason string
+
+const (
+	Lameness UnavailableReason = "lameness"
+	Layup    UnavailableReason = "layup"
+	OffSite  UnavailableReason = "off_site"
+	Other    UnavailableReason = "other"
+)
+
+// Unavailability blocks a horse from being ridden from StartDate to EndDate,
+// both included. Without an end date the block lasts until it is given one.
+type Unavailability struct {
+	ID        int64             `json:"id"`
+	HorseID   int64             `json:"horse_id"`
+	Reason    UnavailableReason `json:"reason"`
+	Notes     string            `json:"notes,omitempty"`
+	StartDate utils.Date        `json:"start_date"`
+	EndDate   *utils.Date       `json:"end_date,omitempty"`
+	CreatedBy int64             `json:"created_by"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type UnavailabilityStore interface {
+	InsertUnavailability(u *Unavailability) error
+	UpdateUnavailability(u *Unavailability) error
+	DeleteUnavailability(id int64) error
+	GetUnavailability(id int64) (*Unavailability, error)
+	// ListUnavailabilities returns the blocks of the given horses that
+	// overlap from to to.
+	ListUnavailabilities(horseIDs []int64, from utils.Date, to utils.Date) ([]*Unavailability, error)
+}
+
+func (u *Unavailability) validate() error {
+	switch u.Reason {
+	case Lameness, Layup, OffSite, Other:
+	default:
+		return errors.New("invalid reason: " + string(u.Reason))
+	}
+	if u.StartDate.IsZero() {
+		return errors.New("a block needs a start date")
+	}
+	if u.EndDate != nil && u.EndDate.Before(u.StartDate.Time) {
+		return errors.New("end date is before the start date")
+	}
+	return nil
+}
+
+func (u *Unavailability) Save(store UnavailabilityStore) error {
+	err := u.validate()
+	if err != nil {
+		return err
+	}
+	if u.ID > 0 {
+		return store.UpdateUnavailability(u)
+	}
+	u.CreatedAt = time.Now().UTC()
+	return store.InsertUnavailability(u)
+}
+
+// GetUnavailability returns the block only if it belongs to horseID.
+func GetUnavailability(horseID int64, id int64, store UnavailabilityStore) (*Unavailability, error) {
+	u, err := store.GetUnavailability(id)
+	if err != nil {
+		return nil, err
+	}
+	if u.HorseID != horseID {
+		return nil, utils.ErrNotFound
+	}
+	return u, nil
+}
+
+func ListUnavailabilities(horseID int64, from utils.Date, to utils.Date, store UnavailabilityStore) ([]*Unavailability, error) {
+	return store.ListUnavailabilities([]int64{horseID}, from, to)
+}
+
+func DeleteUnavailability(id int64, store UnavailabilityStore) error {
+	return store.DeleteUnavailability(id)
+}
+
+func (u *Unavailability) covers(horseID int64, date utils.Date) bool {
+	if u.HorseID != horseID || date.Before(u.StartDate.Time) {
+		return false
+	}
+	return u.EndDate == nil || !date.After(u.EndDate.Time)
+}
+
+// blockFor returns the block that keeps horseID from being ridden on date.
+func blockFor(blocks []*Unavailability, horseID int64, date utils.Date) *Unavailability {
+	for _, u := range blocks {
+		if u.covers(horseID, date) {
+			return u
+		}
+	}
+	return nil
+}
+
+// span returns the dates the block covers from today in loc on, an open
+// block counting as conflictHorizon days, or false if it is over.
+func (u *Unavailability) span(loc *time.Location) (utils.Date, utils.Date, bool) {
+	from := u.StartDate
+	today := recurrence.Day(time.Now().In(loc))
+	if today.After(from.Time) {
+		from = utils.Date{Time: today}
+	}
+	to := utils.Date{Time: from.AddDate(0, 0, conflictHorizon-1)}
+	if u.EndDate != nil && u.EndDate.Before(to.Time) {
+		to = *u.EndDate
+	}
+	return from, to, !to.Before(from.Time)
+}
+
+// AffectedRides returns the rides and occurrences from today on that the
+// block keeps the horse out of.
+func AffectedRides(u *Unavailability, store Store) ([]*RideDetail, error) {
+	loc, err := horseLocation(u.HorseID, store)
+	if err != nil {
+		return nil, err
+	}
+	affected := []*RideDetail{}
+	from, to, ok := u.span(loc)
+	if !ok {
+		return affected, nil
+	}
+	days, err := GetHorseScheduleByRange(u.HorseID, from, to, store)
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range days {
+		for _, r := range day.Rides {
+			if r.Blocked != nil && r.Status == Scheduled {
+				affected = append(affected, r)
+			}
+		}
+	}
+	return affected, nil
+}
+
+// Reassignment moves rides a block affects to another horse. Without any
+// ride or occurrence IDs it moves every affected ride.
+type Reassignment struct {
+	HorseID       int64    `json:"horse_id"`
+	RideIDs       []int64  `json:"ride_ids,omitempty"`
+	OccurrenceIDs []string `json:"occurrence_ids,omitempty"`
+}
+
+// Reassigned is what became of one affected ride.
+type Reassigned struct {
+	RideID       int64        `json:"ride_id,omitempty"`
+	OccurrenceID string       `json:"occurrence_id,omitempty"`
+	Ride         *Ride        `json:"ride,omitempty"`
+	Error        string       `json:"error,omitempty"`
+	Conflicts    []*Conflict  `json:"conflicts,omitempty"`
+	Violations   []*Violation `json:"violations,omitempty"`
+}
+
+// Reassign moves the affected rides re names to re.HorseID one at a time,
+// so one that would double-book the new horse does not hold up the rest.
+// Occurrences become rides of their own on the new horse.
+func Reassign(u *Unavailability, re Reassignment, override bool, actorID int64, store Store) ([]*Reassigned, error) {
+	if re.HorseID == 0 || re.HorseID == u.HorseID {
+		return nil, errors.New("rides have to be reassigned to another horse")
+	}
+	affected, err := AffectedRides(u, store)
+	if err != nil {
+		return nil, err
+	}
+	all := len(re.RideIDs) == 0 && len(re.OccurrenceIDs) == 0
+	results := []*Reassigned{}
+	for _, r := range affected {
+		if !all && !reassigns(re, r) {
+			continue
+		}
+		result := &Reassigned{RideID: r.ID, OccurrenceID: r.OccurrenceID}
+		var ride *Ride
+		if r.ID > 0 {
+			ride, err = store.GetRide(r.ID)
+			if err == nil {
+				ride.HorseID = re.HorseID
+				err = ride.Save(store, override)
+			}
+		} else {
+			var s *Schedule
+			s, err = store.GetSchedule(*r.ScheduleID)
+			if err == nil {
+				ride, err = MoveOccurrence(s, *r.OccurrenceDate, OccurrenceChange{HorseID: re.HorseID}, override, actorID, store)
+			}
+		}
+		switch e := err.(type) {
+		case nil:
+			result.Ride = ride
+		case *ConflictError:
+			result.Error = e.Error()
+			result.Conflicts = e.Conflicts
+		case *WorkloadError:
+			result.Error = e.Error()
+			result.Violations = e.Violations
+		default:
+			result.Error = e.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func reassigns(re Reassignment, r *RideDetail) bool {
+	if r.ID > 0 {
+		for _, id := range re.RideIDs {
+			if id == r.ID {
+				return true
+			}
+		}
+		return false
+	}
+	for _, id := range re.OccurrenceIDs {
+		if id == r.OccurrenceID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package rides
+
+import (
+	"errors"
+	"time"
+
+	"hack/utils"
+)
+
+// now is when offers are made, held and run out; tests fix it.
+var now = time.Now
+
+// DefaultHoldMinutes is how long an offered spot is held for a rider of a
+// lesson that does not set its own hold.
+const DefaultHoldMinutes = 120
+
+type WaitlistStatus string
+
+const (
+	Waiting WaitlistStatus = "waiting"
+	// Offered is a rider a spot has opened up for, held until ExpiresAt.
+	Offered   WaitlistStatus = "offered"
+	Confirmed WaitlistStatus = "confirmed"
+	Declined  WaitlistStatus = "declined"
+	Expired   WaitlistStatus = "expired"
+	// Removed is a rider taken off the waitlist, or every rider still on it
+	// when the lesson is cancelled.
+	Removed WaitlistStatus = "removed"
+)
+
+// WaitlistEntry is a horse and rider queued for a full lesson. Entries are
+// offered spots in the order they joined.
+type WaitlistEntry struct {
+	ID        int64          `json:"id"`
+	LessonID  int64          `json:"lesson_id"`
+	HorseID   int64          `json:"horse_id"`
+	HorseName string         `json:"horse_name,omitempty"`
+	RiderID   int64          `json:"rider_id"`
+	RiderName string         `json:"rider_name,omitempty"`
+	Notes     string         `json:"notes"`
+	Status    WaitlistStatus `json:"status"`
+	// Position is the place of a waiting entry in the queue, from 1.
+	Position  int        `json:"position,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	OfferedAt *time.Time `json:"offered_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// RideID is the ride the entry became on the lesson's roster.
+	RideID *int64 `json:"ride_id,omitempty"`
+}
+
+type WaitlistStore interface {
+	InsertWaitlistEntry(e *WaitlistEntry) error
+	// UpdateWaitlistEntry saves the status, offer and ride of the entry.
+	UpdateWaitlistEntry(e *WaitlistEntry) error
+	GetWaitlistEntry(id int64) (*WaitlistEntry, error)
+	// ListWaitlist returns every entry of the lesson in the order they
+	// joined.
+	ListWaitlist(lessonID int64) ([]*WaitlistEntry, error)
+}
+
+// open reports whether the entry still waits for or holds a spot.
+func (e *WaitlistEntry) open() bool {
+	return e.Status == Waiting || e.Status == Offered
+}
+
+// JoinWaitlist queues the entry for a full lesson of the horse and rider's
+// barn. A lesson with room is joined through its roster instead.
+func JoinWaitlist(e *WaitlistEntry, store Store) error {
+	err := checkRoom(e.LessonID, e.RiderID, store)
+	if err == nil {
+		return errors.New("the lesson has room; join it instead")
+	}
+	if err != ErrLessonFull {
+		return err
+	}
+	l, err := store.GetLesson(e.LessonID)
+	if err != nil {
+		return err
+	}
+	if l.started(now()) {
+		return errors.New("the lesson has started")
+	}
+	err = checkLessonBarn(l, e.HorseID, e.RiderID, store)
+	if err != nil {
+		return err
+	}
+	roster, err := store.ListLessonRides(e.LessonID)
+	if err != nil {
+		return err
+	}
+	for _, r := range active(roster) {
+		if r.RiderID == e.RiderID {
+			return errors.New("the rider is already in the lesson")
+		}
+	}
+	entries, err := store.ListWaitlist(e.LessonID)
+	if err != nil {
+		return err
+	}
+	for _, other := range entries {
+		if other.RiderID == e.RiderID && other.open() {
+			return errors.New("the rider is already on the waitlist")
+		}
+	}
+	e.ID = 0
+	e.Status = Waiting
+	e.CreatedAt = now().UTC()
+	e.OfferedAt, e.ExpiresAt, e.RideID = nil, nil, nil
+	return store.InsertWaitlistEntry(e)
+}
+
+// GetWaitlist returns the lesson's waitlist after offering any spot that
+// opened up or whose hold ran out.
+func GetWaitlist(lessonID int64, store Store) ([]*WaitlistEntry, error) {
+	err := fillLesson(lessonID, store)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.ListWaitlist(lessonID)
+	if err != nil {
+		return nil, err
+	}
+	position := 0
+	for _, e := range entries {
+		if e.Status == Waiting {
+			position++
+			e.Position = position
+		}
+	}
+	return entries, nil
+}
+
+// GetWaitlistEntry returns the entry only if it is on lessonID's waitlist,
+// as it stands once expired holds are dealt with.
+func GetWaitlistEntry(lessonID int64, id int64, store Store) (*WaitlistEntry, error) {
+	err := fillLesson(lessonID, store)
+	if err != nil {
+		return nil, err
+	}
+	e, err := store.GetWaitlistEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if e.LessonID != lessonID {
+		return nil, utils.ErrNotFound
+	}
+	return e, nil
+}
+
+// Confirm takes the spot held for the entry, adding its horse and rider to
+// the lesson's roster. The ride is saved as Ride.Save saves it.
+func (e *WaitlistEntry) Confirm(store Store, override bool) (*Ride, error) {
+	if e.Status != Offered {
+		return nil, errors.New("no spot is on offer to this rider")
+	}
+	lessonID := e.LessonID
+	r := &Ride{HorseID: e.HorseID, RiderID: e.RiderID, Notes: e.Notes, LessonID: &lessonID}
+	err := r.Save(store, override)
+	if err != nil {
+		return nil, err
+	}
+	e.Status = Confirmed
+	e.RideID = &r.ID
+	return r, nil
+}
+
+// Decline turns down the spot held for the entry, or takes a waiting entry
+// off the waitlist, and offers the spot to the next rider.
+func (e *WaitlistEntry) Decline(store Store) error {
+	if !e.open() {
+		return errors.New("the rider is no longer on the waitlist")
+	}
+	if e.Status == Offered {
+		e.Status = Declined
+	} else {
+		e.Status = Removed
+	}
+	err := store.UpdateWaitlistEntry(e)
+	if err != nil {
+		return err
+	}
+	return fillLesson(e.LessonID, store)
+}
+
+// fillLesson expires the offers of the lesson whose hold ran out and offers
+// every spot nobody holds to the riders waiting longest. Nothing is offered
+// once the lesson is cancelled or has started.
+func fillLesson(lessonID int64, store Store) error {
+	l, err := store.GetLesson(lessonID)
+	if err != nil {
+		return err
+	}
+	at := now()
+	if l.Status == Cancelled || l.started(at) {
+		return nil
+	}
+	entries, err := store.ListWaitlist(lessonID)
+	if err != nil {
+		return err
+	}
+	roster, err := store.ListLessonRides(lessonID)
+	if err != nil {
+		return err
+	}
+	spots := l.MaxRiders - len(active(roster))
+	for _, e := range entries {
+		if e.Status != Offered {
+			continue
+		}
+		if at.Before(*e.ExpiresAt) {
+			spots--
+			continue
+		}
+		e.Status = Expired
+		err = store.UpdateWaitlistEntry(e)
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		if spots <= 0 {
+			break
+		}
+		if e.Status != Waiting {
+			continue
+		}
+		offeredAt := at.UTC()
+		expiresAt := offeredAt.Add(time.Duration(l.holdMinutes()) * time.Minute)
+		// a hold ends when the lesson starts
+		if l.StartsAt != nil && l.StartsAt.Before(expiresAt) {
+			expiresAt = l.StartsAt.UTC()
+		}
+		e.Status = Offered
+		e.OfferedAt = &offeredAt
+		e.ExpiresAt = &expiresAt
+		err = store.UpdateWaitlistEntry(e)
+		if err != nil {
+			return err
+		}
+		spots--
+	}
+	return nil
+}
+
+// heldSpots counts the spots of the lesson on offer to riders other than
+// riderID.
+func heldSpots(lessonID int64, riderID int64, store Store) (int, error) {
+	entries, err := store.ListWaitlist(lessonID)
+	if err != nil {
+		return 0, err
+	}
+	held := 0
+	for _, e := range entries {
+		if e.Status == Offered && e.RiderID != riderID {
+			held++
+		}
+	}
+	return held, nil
+}
+
+// claimWaitlist marks the open entries of a rider who has joined the lesson
+// as confirmed, so a spot is not held or offered for them as well.
+func claimWaitlist(r *Ride, store Store) error {
+	entries, err := store.ListWaitlist(*r.LessonID)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.RiderID != r.RiderID || !e.open() {
+			continue
+		}
+		e.Status = Confirmed
+		id := r.ID
+		e.RideID = &id
+		err = store.UpdateWaitlistEntry(e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeWaitlist takes every rider still waiting off a cancelled lesson's
+// waitlist.
+func closeWaitlist(lessonID int64, store Store) error {
+	entries, err := store.ListWaitlist(lessonID)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.open() {
+			continue
+		}
+		e.Status = Removed
+		err = store.UpdateWaitlistEntry(e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
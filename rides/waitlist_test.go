@@ -0,0 +1,240 @@
+package rides
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lessonStart is when the lesson of fullLesson starts: 18:00 in New York on
+// 2031-01-07.
+var lessonStart = time.Date(2031, 1, 7, 23, 0, 0, 0, time.UTC)
+
+// setNow fixes the clock at t for the rest of the test.
+func setNow(test *testing.T, t time.Time) {
+	test.Cleanup(func() { now = time.Now })
+	now = func() time.Time { return t }
+}
+
+// fullLesson saves lesson 1, which takes two riders and holds a spot for an
+// hour, with riders 1 and 2 on its roster and riders 3, 4 and 5 waiting in
+// that order, a day before it starts. Each rider rides the horse of their
+// own number.
+func fullLesson(t *testing.T) *memoryStore {
+	t.Helper()
+	setNow(t, lessonStart.Add(-24*time.Hour))
+	store := newMemoryStore()
+	store.lessons = append(store.lessons, &Lesson{ID: 1, BarnID: 1, EventTypeID: 10, Date: date("2031-01-07"), Time: clock(18, 0), DurationMinutes: 60, TimeZone: store.zone, MaxRiders: 2, HoldMinutes: 60, Status: Scheduled})
+	lessonID := int64(1)
+	for _, riderID := range []int64{1, 2} {
+		r := &Ride{HorseID: riderID, RiderID: riderID, LessonID: &lessonID}
+		err := r.Save(store, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, riderID := range []int64{3, 4, 5} {
+		err := JoinWaitlist(&WaitlistEntry{LessonID: 1, HorseID: riderID, RiderID: riderID}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+// dropOut cancels the roster ride of riderID.
+func dropOut(t *testing.T, store *memoryStore, riderID int64) {
+	t.Helper()
+	for _, r := range store.rides {
+		if r.RiderID == riderID {
+			ride := *r
+			err := ride.Transition(Cancelled, 1, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+	t.Fatalf("rider %d is not on the roster", riderID)
+}
+
+// formatWaitlist lists the entries as rider:status.
+func formatWaitlist(entries []*WaitlistEntry) string {
+	var s []string
+	for _, e := range entries {
+		s = append(s, strconv.FormatInt(e.RiderID, 10)+":"+string(e.Status))
+	}
+	return strings.Join(s, " ")
+}
+
+func TestWaitlistOffers(t *testing.T) {
+	tests := []struct {
+		name  string
+		drop  []int64
+		after time.Duration
+		want  string
+	}{
+		{
+			name: "nobody drops out",
+			want: "3:waiting 4:waiting 5:waiting",
+		},
+		{
+			name: "first in line is offered the spot",
+			drop: []int64{1},
+			want: "3:offered 4:waiting 5:waiting",
+		},
+		{
+			name: "a spot for each rider who drops out",
+			drop: []int64{1, 2},
+			want: "3:offered 4:offered 5:waiting",
+		},
+		{
+			name:  "held until the hold runs out",
+			drop:  []int64{1},
+			after: 59 * time.Minute,
+			want:  "3:offered 4:waiting 5:waiting",
+		},
+		{
+			name:  "passed on once the hold runs out",
+			drop:  []int64{1},
+			after: time.Hour,
+			want:  "3:expired 4:offered 5:waiting",
+		},
+		{
+			name:  "passed on down the line",
+			drop:  []int64{1},
+			after: 2 * time.Hour,
+			want:  "3:expired 4:expired 5:offered",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := fullLesson(t)
+			for _, riderID := range tt.drop {
+				dropOut(t, store, riderID)
+			}
+			// holds run out one look at a time, as they do when the waitlist
+			// is read every so often
+			start := now()
+			for elapsed := time.Duration(0); elapsed < tt.after; elapsed += time.Hour {
+				setNow(t, start.Add(elapsed))
+				_, err := GetWaitlist(1, store)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			setNow(t, start.Add(tt.after))
+			entries, err := GetWaitlist(1, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := formatWaitlist(entries); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitlistHoldEndsWhenLessonStarts(t *testing.T) {
+	store := fullLesson(t)
+	setNow(t, lessonStart.Add(-30*time.Minute))
+	dropOut(t, store, 1)
+	e, err := GetWaitlistEntry(1, 1, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != Offered || !e.ExpiresAt.Equal(lessonStart) {
+		t.Fatalf("entry is %s until %v, want offered until the lesson starts at %v", e.Status, e.ExpiresAt, lessonStart)
+	}
+
+	// nothing is offered once the lesson has started
+	setNow(t, lessonStart)
+	dropOut(t, store, 2)
+	entries, err := GetWaitlist(1, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := formatWaitlist(entries), "3:offered 4:waiting 5:waiting"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestWaitlistHoldKeepsOthersOut(t *testing.T) {
+	store := fullLesson(t)
+	dropOut(t, store, 1)
+	lessonID := int64(1)
+
+	walkIn := &Ride{HorseID: 6, RiderID: 6, LessonID: &lessonID}
+	err := walkIn.Save(store, false)
+	if err != ErrLessonFull {
+		t.Fatalf("a rider not offered the held spot got %v, want ErrLessonFull", err)
+	}
+	next := &Ride{HorseID: 4, RiderID: 4, LessonID: &lessonID}
+	err = next.Save(store, false)
+	if err != ErrLessonFull {
+		t.Fatalf("the next rider in line got %v, want ErrLessonFull", err)
+	}
+
+	// the rider the spot is held for can take it by joining the roster
+	holder := &Ride{HorseID: 3, RiderID: 3, LessonID: &lessonID}
+	err = holder.Save(store, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := store.GetWaitlistEntry(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != Confirmed || e.RideID == nil || *e.RideID != holder.ID {
+		t.Fatalf("entry is %s with ride %v, want confirmed with ride %d", e.Status, e.RideID, holder.ID)
+	}
+}
+
+func TestWaitlistConfirm(t *testing.T) {
+	store := fullLesson(t)
+	dropOut(t, store, 1)
+
+	waiting, err := GetWaitlistEntry(1, 2, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = waiting.Confirm(store, false)
+	if err == nil {
+		t.Fatal("a rider still waiting confirmed a spot")
+	}
+
+	offered, err := GetWaitlistEntry(1, 1, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := offered.Confirm(store, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offered.Status != Confirmed || *offered.RideID != r.ID {
+		t.Fatalf("entry is %s with ride %d, want confirmed with ride %d", offered.Status, *offered.RideID, r.ID)
+	}
+	roster, err := store.ListLessonRides(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(active(roster)); n != 2 {
+		t.Fatalf("%d riders on the roster, want 2", n)
+	}
+
+	// a hold that ran out cannot be confirmed
+	dropOut(t, store, 2)
+	setNow(t, now().Add(time.Hour))
+	expired, err := GetWaitlistEntry(1, 2, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired.Status != Expired {
+		t.Fatalf("entry is %s an hour after it was offered, want expired", expired.Status)
+	}
+	_, err = expired.Confirm(store, false)
+	if err == nil {
+		t.Fatal("an expired hold was confirmed")
+	}
+}
@@ -0,0 +1,396 @@
+package rides
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"hack/utils"
+)
+
+type Enforcement string
+
+const (
+	// Warn saves bookings that break a limit and reports what they break.
+	Warn Enforcement = "warn"
+	// Block refuses them unless the save overrides it.
+	Block Enforcement = "block"
+)
+
+// WorkloadRule limits how hard a horse is worked. A limit of zero is no
+// limit.
+type WorkloadRule struct {
+	HorseID           int64 `json:"horse_id"`
+	MaxRidesPerDay    int   `json:"max_rides_per_day,omitempty"`
+	MaxMinutesPerDay  int   `json:"max_minutes_per_day,omitempty"`
+	MaxMinutesPerWeek int   `json:"max_minutes_per_week,omitempty"`
+	// RestDays are the weekdays the horse is not worked, as lower case
+	// names like "monday".
+	RestDays []string `json:"rest_days,omitempty"`
+	// MinGapMinutes is the least time between the end of one ride and the
+	// start of the next on the same day.
+	MinGapMinutes int         `json:"min_gap_minutes,omitempty"`
+	Enforcement   Enforcement `json:"enforcement"`
+}
+
+type WorkloadStore interface {
+	InsertWorkloadRule(w *WorkloadRule) error
+	UpdateWorkloadRule(w *WorkloadRule) error
+	DeleteWorkloadRule(horseID int64) error
+	GetWorkloadRule(horseID int64) (*WorkloadRule, error)
+	ListWorkloadRulesByBarn(barnID int64) ([]*WorkloadRule, error)
+}
+
+type Limit string
+
+const (
+	RidesPerDay    Limit = "max_rides_per_day"
+	MinutesPerDay  Limit = "max_minutes_per_day"
+	MinutesPerWeek Limit = "max_minutes_per_week"
+	RestDay        Limit = "rest_day"
+	MinGap         Limit = "min_gap_minutes"
+)
+
+// Violation is a limit a horse goes over on a day, or in the week starting
+// on Date for the weekly limit.
+type Violation struct {
+	HorseID int64      `json:"horse_id"`
+	Limit   Limit      `json:"limit"`
+	Date    utils.Date `json:"date"`
+	Allowed int        `json:"allowed"`
+	Actual  int        `json:"actual"`
+}
+
+// WorkloadError is returned by the save functions when a blocking workload
+// rule refuses a booking.
+type WorkloadError struct {
+	Violations []*Violation `json:"violations"`
+}
+
+func (e *WorkloadError) Error() string {
+	if len(e.Violations) == 1 {
+		return "1 workload limit exceeded"
+	}
+	return strconv.Itoa(len(e.Violations)) + " workload limits exceeded"
+}
+
+func (w *WorkloadRule) validate() error {
+	if w.MaxRidesPerDay < 0 || w.MaxMinutesPerDay < 0 || w.MaxMinutesPerWeek < 0 || w.MinGapMinutes < 0 {
+		return errors.New("workload limits cannot be negative")
+	}
+	for i, day := range w.RestDays {
+		if _, ok := weekday(day); !ok {
+			return errors.New("invalid rest day: " + day)
+		}
+		w.RestDays[i] = strings.ToLower(day)
+	}
+	switch w.Enforcement {
+	case "":
+		w.Enforcement = Warn
+	case Warn, Block:
+	default:
+		return errors.New("invalid enforcement: " + string(w.Enforcement))
+	}
+	return nil
+}
+
+func weekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+func (w *WorkloadRule) Save(store WorkloadStore) error {
+	err := w.validate()
+	if err != nil {
+		return err
+	}
+	_, err = store.GetWorkloadRule(w.HorseID)
+	if err == utils.ErrNotFound {
+		return store.InsertWorkloadRule(w)
+	}
+	if err != nil {
+		return err
+	}
+	return store.UpdateWorkloadRule(w)
+}
+
+func GetWorkloadRule(horseID int64, store WorkloadStore) (*WorkloadRule, error) {
+	return store.GetWorkloadRule(horseID)
+}
+
+func DeleteWorkloadRule(horseID int64, store WorkloadStore) error {
+	return store.DeleteWorkloadRule(horseID)
+}
+
+// WeekStart returns the Monday of the week date is in.
+func WeekStart(date utils.Date) utils.Date {
+	back := (int(date.Weekday()) + 6) % 7
+	return utils.Date{Time: date.AddDate(0, 0, -back)}
+}
+
+// works reports whether the ride counts toward the horse's workload, and for
+// how many minutes.
+func works(r *Ride) (int, bool) {
+	if r.Status == Cancelled || r.Status == NoShow {
+		return 0, false
+	}
+	if r.Completion != nil && r.Completion.ActualMinutes > 0 {
+		return r.Completion.ActualMinutes, true
+	}
+	return r.DurationMinutes, true
+}
+
+// evaluate checks the horse's rides on days, which start on a Monday and
+// cover whole weeks, against the rule.
+func (w *WorkloadRule) evaluate(days []*Day) []*Violation {
+	var violations []*Violation
+	add := func(limit Limit, date utils.Date, allowed int, actual int) {
+		violations = append(violations, &Violation{
+			HorseID: w.HorseID,
+			Limit:   limit,
+			Date:    date,
+			Allowed: allowed,
+			Actual:  actual,
+		})
+	}
+	weekMinutes := 0
+	for i, day := range days {
+		count, minutes := 0, 0
+		var timed []*Ride
+		for _, r := range day.Rides {
+			m, ok := works(&r.Ride)
+			if !ok {
+				continue
+			}
+			count++
+			minutes += m
+			if r.StartsAt != nil {
+				timed = append(timed, &r.Ride)
+			}
+		}
+		weekMinutes += minutes
+		if w.MaxRidesPerDay > 0 && count > w.MaxRidesPerDay {
+			add(RidesPerDay, day.Date, w.MaxRidesPerDay, count)
+		}
+		if w.MaxMinutesPerDay > 0 && minutes > w.MaxMinutesPerDay {
+			add(MinutesPerDay, day.Date, w.MaxMinutesPerDay, minutes)
+		}
+		if count > 0 && w.isRestDay(day.Date.Weekday()) {
+			add(RestDay, day.Date, 0, count)
+		}
+		if gap, ok := smallestGap(timed); ok && w.MinGapMinutes > 0 && gap < w.MinGapMinutes {
+			add(MinGap, day.Date, w.MinGapMinutes, gap)
+		}
+		if i%7 == 6 {
+			if w.MaxMinutesPerWeek > 0 && weekMinutes > w.MaxMinutesPerWeek {
+				add(MinutesPerWeek, days[i-6].Date, w.MaxMinutesPerWeek, weekMinutes)
+			}
+			weekMinutes = 0
+		}
+	}
+	return violations
+}
+
+func (w *WorkloadRule) isRestDay(day time.Weekday) bool {
+	for _, name := range w.RestDays {
+		if rest, ok := weekday(name); ok && rest == day {
+			return true
+		}
+	}
+	return false
+}
+
+// smallestGap returns the fewest minutes between one located ride ending and
+// the next starting, which is negative when they overlap.
+func smallestGap(rides []*Ride) (int, bool) {
+	if len(rides) < 2 {
+		return 0, false
+	}
+	sort.Slice(rides, func(i, j int) bool {
+		return rides[i].StartsAt.Before(*rides[j].StartsAt)
+	})
+	smallest := 0
+	for i := 1; i < len(rides); i++ {
+		_, end := rides[i-1].span()
+		gap := int(rides[i].StartsAt.Sub(end).Minutes())
+		if i == 1 || gap < smallest {
+			smallest = gap
+		}
+	}
+	return smallest, true
+}
+
+// findViolations returns the limits of the horse's rule the proposal breaks,
+// on the days and in the weeks it books anything. A horse without a rule has
+// no limits.
+func findViolations(p *proposal, store Store) (*WorkloadRule, []*Violation, error) {
+	rule, err := store.GetWorkloadRule(p.horseID)
+	if err == utils.ErrNotFound {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	from := WeekStart(p.from)
+	to := utils.Date{Time: WeekStart(p.to).AddDate(0, 0, 6)}
+	days, err := GetHorseScheduleByRange(p.horseID, from, to, store)
+	if err != nil {
+		return nil, nil, err
+	}
+	proposed := make(map[string]bool)
+	for _, day := range days {
+		kept := []*RideDetail{}
+		for _, r := range day.Rides {
+			if !p.replaces(r) {
+				kept = append(kept, r)
+			}
+		}
+		for _, r := range p.rides {
+			if r.Date.Equal(day.Date.Time) {
+				kept = append(kept, &RideDetail{Ride: *r})
+				proposed[day.Date.Format("2006-01-02")] = true
+				proposed[WeekStart(day.Date).Format("2006-01-02")+"/week"] = true
+			}
+		}
+		day.Rides = kept
+	}
+	var violations []*Violation
+	for _, v := range rule.evaluate(days) {
+		key := v.Date.Format("2006-01-02")
+		if v.Limit == MinutesPerWeek {
+			key += "/week"
+		}
+		if proposed[key] {
+			violations = append(violations, v)
+		}
+	}
+	return rule, violations, nil
+}
+
+// FindViolations returns the limits of the horse's workload rule the ride
+// would break, and the rule, which is nil for a horse without one.
+func (r *Ride) FindViolations(store Store) (*WorkloadRule, []*Violation, error) {
+	p, err := r.propose(store)
+	if err != nil || p == nil {
+		return nil, nil, err
+	}
+	return findViolations(p, store)
+}
+
+// checkWorkload returns a *WorkloadError when a blocking rule is broken and
+// the save does not override it; otherwise what is broken comes back as
+// warnings.
+func checkWorkload(p *proposal, override bool, store Store) ([]*Violation, error) {
+	if p == nil {
+		return nil, nil
+	}
+	rule, violations, err := findViolations(p, store)
+	if err != nil {
+		return nil, err
+	}
+	if len(violations) > 0 && rule.Enforcement == Block && !override {
+		return nil, &WorkloadError{Violations: violations}
+	}
+	return violations, nil
+}
+
+// HorseWorkload is how hard a horse is worked in a week.
+type HorseWorkload struct {
+	HorseID    int64          `json:"horse_id"`
+	HorseName  string         `json:"horse_name"`
+	Rule       *WorkloadRule  `json:"rule"`
+	Rides      int            `json:"rides"`
+	Minutes    int            `json:"minutes"`
+	Days       []*DayWorkload `json:"days"`
+	Violations []*Violation   `json:"violations"`
+}
+
+type DayWorkload struct {
+	Date    utils.Date `json:"date"`
+	Rides   int        `json:"rides"`
+	Minutes int        `json:"minutes"`
+}
+
+// GetWorkloadReport returns the horses in the barn that go over a limit of
+// their rule in the week, Monday to Sunday, that date falls in.
+func GetWorkloadReport(barnID int64, date utils.Date, store Store) ([]*HorseWorkload, error) {
+	rules, err := store.ListWorkloadRulesByBarn(barnID)
+	if err != nil {
+		return nil, err
+	}
+	from := WeekStart(date)
+	to := utils.Date{Time: from.AddDate(0, 0, 6)}
+	barnDays, err := GetScheduleByRange(barnID, from, to, store)
+	if err != nil {
+		return nil, err
+	}
+	report := []*HorseWorkload{}
+	for _, rule := range rules {
+		w := &HorseWorkload{HorseID: rule.HorseID, Rule: rule, Days: []*DayWorkload{}}
+		var days []*Day
+		for _, barnDay := range barnDays {
+			day := &Day{Date: barnDay.Date, Rides: []*RideDetail{}}
+			dw := &DayWorkload{Date: barnDay.Date}
+			for _, r := range barnDay.Rides {
+				if r.HorseID != rule.HorseID {
+					continue
+				}
+				w.HorseName = r.HorseName
+				day.Rides = append(day.Rides, r)
+				if minutes, ok := works(&r.Ride); ok {
+					dw.Rides++
+					dw.Minutes += minutes
+				}
+			}
+			days = append(days, day)
+			w.Days = append(w.Days, dw)
+			w.Rides += dw.Rides
+			w.Minutes += dw.Minutes
+		}
+		w.Violations = rule.evaluate(days)
+		if len(w.Violations) > 0 {
+			report = append(report, w)
+		}
+	}
+	return report, nil
+}
+
+// GetWeekWorkload returns the rides and minutes of the horses in the barn
+// that are worked in the week, Monday to Sunday, that date falls in, by
+// horse ID. It does not look at their rules.
+func GetWeekWorkload(barnID int64, date utils.Date, store Store) (map[int64]*HorseWorkload, error) {
+	from := WeekStart(date)
+	to := utils.Date{Time: from.AddDate(0, 0, 6)}
+	barnDays, err := GetScheduleByRange(barnID, from, to, store)
+	if err != nil {
+		return nil, err
+	}
+	workloads := make(map[int64]*HorseWorkload)
+	for i, barnDay := range barnDays {
+		for _, r := range barnDay.Rides {
+			minutes, ok := works(&r.Ride)
+			if !ok {
+				continue
+			}
+			w, ok := workloads[r.HorseID]
+			if !ok {
+				w = &HorseWorkload{HorseID: r.HorseID, HorseName: r.HorseName, Violations: []*Violation{}}
+				for _, day := range barnDays {
+					w.Days = append(w.Days, &DayWorkload{Date: day.Date})
+				}
+				workloads[r.HorseID] = w
+			}
+			w.Days[i].Rides++
+			w.Days[i].Minutes += minutes
+			w.Rides++
+			w.Minutes += minutes
+		}
+	}
+	return workloads, nil
+}
@@ -0,0 +1,71 @@
+package rides
+
+import (
+	"time"
+
+	"hack/utils"
+)
+
+// TimeZoneStore looks up the zone a horse's rides are in, which is the zone
+// of its barn.
+type TimeZoneStore interface {
+	GetHorseTimeZone(horseID int64) (string, error)
+	UpdateRideStartsAt(id int64, startsAt *time.Time) error
+}
+
+func horseLocation(horseID int64, store TimeZoneStore) (*time.Location, error) {
+	name, err := store.GetHorseTimeZone(horseID)
+	if err == utils.ErrNotFound {
+		return time.UTC, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return utils.Location(name), nil
+}
+
+// locate pins a timed ride to instants in loc. The wall clock time is taken
+// as it reads in loc on the ride's date, so a recurring 9:00 AM stays 9:00 AM
+// across DST changes; a time skipped by a DST jump resolves the way
+// time.Date resolves it. A StartsAt already read from the store is kept.
+func (r *Ride) locate(loc *time.Location) {
+	if r.Time == nil || !r.Time.Valid {
+		r.StartsAt, r.EndsAt = nil, nil
+		return
+	}
+	if r.StartsAt == nil {
+		start := time.Date(r.Date.Year(), r.Date.Month(), r.Date.Day(), r.Time.Time.Hour(), r.Time.Time.Minute(), r.Time.Time.Second(), 0, loc)
+		r.StartsAt = &start
+	}
+	start := r.StartsAt.In(loc)
+	r.StartsAt = &start
+	r.EndsAt = nil
+	if r.DurationMinutes > 0 {
+		end := start.Add(time.Duration(r.DurationMinutes) * time.Minute)
+		r.EndsAt = &end
+		r.EndTime = &utils.Time{}
+		r.EndTime.Valid = true
+		r.EndTime.Time = time.Date(0, 1, 1, end.Hour(), end.Minute(), end.Second(), 0, time.UTC)
+	}
+}
+
+// Relocate re-pins every timed ride of a barn after its time zone changed,
+// keeping their dates and times as they read on the barn's clock.
+func Relocate(barnID int64, timeZone string, store Store) error {
+	loc := utils.Location(timeZone)
+	from := utils.Date{Time: time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)}
+	to := utils.Date{Time: time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)}
+	rides, err := store.ListRidesByBarn(barnID, from, to)
+	if err != nil {
+		return err
+	}
+	for _, r := range rides {
+		r.StartsAt = nil
+		r.locate(loc)
+		err = store.UpdateRideStartsAt(r.ID, r.StartsAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
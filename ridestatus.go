@@ -0,0 +1,83 @@
+package main
+
+import (
+	"hack/barns"
+	"hack/rides"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerRideStatusRoutes(app *fiber.App, s stores) {
+	for path, status := range map[string]rides.Status{
+		"check-in": rides.CheckedIn,
+		"no-show":  rides.NoShow,
+		"cancel":   rides.Cancelled,
+	} {
+		status := status
+		app.Post("/ride/:id/"+path, func(c *fiber.Ctx) error {
+			ride, err := storedRide(c, s)
+			if err != nil {
+				return err
+			}
+			err = ride.Transition(status, currentUser(c).ID, s.rides)
+			if err != nil {
+				return failed(fiber.StatusInternalServerError, "Failed to change ride status", err)
+			}
+			return c.JSON(fiber.Map{
+				"ride": ride,
+			})
+		})
+	}
+
+	app.Post("/ride/:id/complete", func(c *fiber.Ctx) error {
+		var completion rides.Completion
+		err := parseBody(c, &completion, "completion")
+		if err != nil {
+			return err
+		}
+		ride, err := storedRide(c, s)
+		if err != nil {
+			return err
+		}
+		err = ride.Complete(completion, currentUser(c).ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to complete ride", err)
+		}
+		return c.JSON(fiber.Map{
+			"ride": ride,
+		})
+	})
+
+	app.Get("/ride/:id/history", func(c *fiber.Ctx) error {
+		ride, err := storedRide(c, s)
+		if err != nil {
+			return err
+		}
+		changes, err := rides.ListStatusChanges(ride.ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get ride history", err)
+		}
+		return c.JSON(fiber.Map{
+			"ride":    ride,
+			"history": changes,
+		})
+	})
+}
+
+// storedRide loads the ride named by the :id param for a member who can
+// manage rides for its horse and rider.
+func storedRide(c *fiber.Ctx, s stores) (*rides.Ride, error) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return nil, err
+	}
+	ride, err := s.rides.GetRide(id)
+	if err == utils.ErrNotFound {
+		return nil, fiber.NewError(fiber.StatusNotFound, "ride not found")
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to get ride: "+err.Error())
+	}
+	return ride, authorizeHorseAndRider(c, s, ride.HorseID, ride.RiderID, barns.ManageRides)
+}
@@ -0,0 +1,102 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"hack/users"
+	"hack/utils"
+)
+
+func (s *Store) GetOrCreateLocalIdentity(phone string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow("select id from local_auth_identities where phone = ?", phone).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, errors.New("failed to select local identity: " + err.Error())
+	}
+	result, err := s.db.Exec("insert into local_auth_identities (phone, created_at) values (?, ?)", phone, time.Now().UTC())
+	if err != nil {
+		return 0, errors.New("failed to insert local identity: " + err.Error())
+	}
+	id, err = result.LastInsertId()
+	if err != nil {
+		return 0, errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return id, nil
+}
+
+func (s *Store) InsertOTP(o *users.LocalOTP) error {
+	query := "insert into local_auth_otps (method_id, identity_id, code_hash, expires_at, attempts) values (?, ?, ?, ?, ?)"
+	_, err := s.db.Exec(query, o.MethodID, o.IdentityID, o.CodeHash, o.ExpiresAt, o.Attempts)
+	if err != nil {
+		return errors.New("failed to insert passcode: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetOTP(methodID string) (*users.LocalOTP, error) {
+	query := "select method_id, identity_id, code_hash, expires_at, attempts, used_at from local_auth_otps where method_id = ?"
+	var o users.LocalOTP
+	var usedAt sql.NullTime
+	err := s.db.QueryRow(query, methodID).Scan(&o.MethodID, &o.IdentityID, &o.CodeHash, &o.ExpiresAt, &o.Attempts, &usedAt)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("failed to select passcode: " + err.Error())
+	}
+	if usedAt.Valid {
+		o.UsedAt = &usedAt.Time
+	}
+	return &o, nil
+}
+
+func (s *Store) UpdateOTP(o *users.LocalOTP) error {
+	var usedAt interface{}
+	if o.UsedAt != nil {
+		usedAt = *o.UsedAt
+	}
+	_, err := s.db.Exec("update local_auth_otps set attempts = ?, used_at = ? where method_id = ?", o.Attempts, usedAt, o.MethodID)
+	if err != nil {
+		return errors.New("failed to update passcode: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) InsertLocalSession(ls *users.LocalSession) error {
+	query := "insert into local_auth_sessions (id, identity_id, created_at, expires_at) values (?, ?, ?, ?)"
+	_, err := s.db.Exec(query, ls.ID, ls.IdentityID, time.Now().UTC(), ls.ExpiresAt)
+	if err != nil {
+		return errors.New("failed to insert session: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetLocalSession(id string) (*users.LocalSession, error) {
+	query := "select id, identity_id, expires_at, revoked_at from local_auth_sessions where id = ?"
+	var ls users.LocalSession
+	var revokedAt sql.NullTime
+	err := s.db.QueryRow(query, id).Scan(&ls.ID, &ls.IdentityID, &ls.ExpiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("failed to select session: " + err.Error())
+	}
+	if revokedAt.Valid {
+		ls.RevokedAt = &revokedAt.Time
+	}
+	return &ls, nil
+}
+
+func (s *Store) RevokeLocalSession(id string, at time.Time) error {
+	_, err := s.db.Exec("update local_auth_sessions set revoked_at = ? where id = ?", at, id)
+	if err != nil {
+		return errors.New("failed to revoke session: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/rides"
+	"hack/utils"
+)
+
+const availabilityColumns = "id, barn_id, name, instructor_id, (select u.name from instructors i join users u on u.id = i.user_id where i.id = instructor_id) instructor_name, resource_id, (select name from resources where id = resource_id) resource_name, rrule, start_date, end_date, start_time, end_time, slot_minutes, (select time_zone from barns where id = barn_id) time_zone"
+
+func (s *Store) InsertAvailabilityTemplate(t *rides.AvailabilityTemplate) error {
+	query := "insert into availability_templates (barn_id, name, instructor_id, resource_id, rrule, start_date, end_date, start_time, end_time, slot_minutes) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, t.BarnID, t.Name, t.InstructorID, t.ResourceID, t.RRule, t.StartDate.Format("2006-01-02"), nullableDate(t.EndDate), t.StartTime, t.EndTime, t.SlotMinutes)
+	if err != nil {
+		return errors.New("failed to insert availability template: " + err.Error())
+	}
+	t.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return s.setAvailabilityEventTypes(t)
+}
+
+func (s *Store) UpdateAvailabilityTemplate(t *rides.AvailabilityTemplate) error {
+	query := "update availability_templates set name = ?, instructor_id = ?, resource_id = ?, rrule = ?, start_date = ?, end_date = ?, start_time = ?, end_time = ?, slot_minutes = ? where id = ?"
+	_, err := s.db.Exec(query, t.Name, t.InstructorID, t.ResourceID, t.RRule, t.StartDate.Format("2006-01-02"), nullableDate(t.EndDate), t.StartTime, t.EndTime, t.SlotMinutes, t.ID)
+	if err != nil {
+		return errors.New("failed to update availability template: " + err.Error())
+	}
+	return s.setAvailabilityEventTypes(t)
+}
+
+func (s *Store) setAvailabilityEventTypes(t *rides.AvailabilityTemplate) error {
+	_, err := s.db.Exec("delete from availability_template_event_types where template_id = ?", t.ID)
+	if err != nil {
+		return errors.New("failed to clear availability template event types: " + err.Error())
+	}
+	for _, id := range t.EventTypeIDs {
+		_, err = s.db.Exec("insert into availability_template_event_types (template_id, event_type_id) values (?, ?)", t.ID, id)
+		if err != nil {
+			return errors.New("failed to insert availability template event type: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *Store) DeleteAvailabilityTemplate(id int64) error {
+	_, err := s.db.Exec("delete from availability_templates where id = ?", id)
+	if err != nil {
+		return errors.New("failed to delete availability template: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetAvailabilityTemplate(id int64) (*rides.AvailabilityTemplate, error) {
+	query := "select " + availabilityColumns + " from availability_templates where id = ?"
+	templates, err := s.listAvailabilityTemplates(query, "select template_id, event_type_id from availability_template_event_types where template_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return templates[0], nil
+}
+
+func (s *Store) ListAvailabilityTemplatesByBarn(barnID int64) ([]*rides.AvailabilityTemplate, error) {
+	query := "select " + availabilityColumns + " from availability_templates where barn_id = ? order by start_time, name"
+	return s.listAvailabilityTemplates(query, "select template_id, event_type_id from availability_template_event_types where template_id in (select id from availability_templates where barn_id = ?)", barnID)
+}
+
+// listAvailabilityTemplates runs query and fills in event types from
+// eventTypeQuery, both taking the same argument.
+func (s *Store) listAvailabilityTemplates(query string, eventTypeQuery string, arg interface{}) ([]*rides.AvailabilityTemplate, error) {
+	rows, err := s.db.Query(query, arg)
+	if err != nil {
+		return nil, errors.New("failed to select availability templates: " + err.Error())
+	}
+	defer rows.Close()
+	templates := []*rides.AvailabilityTemplate{}
+	byID := make(map[int64]*rides.AvailabilityTemplate)
+	for rows.Next() {
+		t := &rides.AvailabilityTemplate{EventTypeIDs: []int64{}}
+		var instructorName, resourceName sql.NullString
+		err := rows.Scan(&t.ID, &t.BarnID, &t.Name, &t.InstructorID, &instructorName, &t.ResourceID, &resourceName, &t.RRule, &t.StartDate, &t.EndDate, &t.StartTime, &t.EndTime, &t.SlotMinutes, &t.TimeZone)
+		if err != nil {
+			return nil, errors.New("failed to scan availability template row: " + err.Error())
+		}
+		t.InstructorName = instructorName.String
+		t.ResourceName = resourceName.String
+		templates = append(templates, t)
+		byID[t.ID] = t
+	}
+	typeRows, err := s.db.Query(eventTypeQuery+" order by event_type_id", arg)
+	if err != nil {
+		return nil, errors.New("failed to select availability template event types: " + err.Error())
+	}
+	defer typeRows.Close()
+	for typeRows.Next() {
+		var templateID, eventTypeID int64
+		err := typeRows.Scan(&templateID, &eventTypeID)
+		if err != nil {
+			return nil, errors.New("failed to scan availability template event type row: " + err.Error())
+		}
+		if t, ok := byID[templateID]; ok {
+			t.EventTypeIDs = append(t.EventTypeIDs, eventTypeID)
+		}
+	}
+	return templates, nil
+}
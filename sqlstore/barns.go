@@ -0,0 +1,170 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/barns"
+	"hack/utils"
+)
+
+func (s *Store) GetBarn(id int64) (*barns.Barn, error) {
+	query := "select id, name, time_zone from barns where id = ?"
+	var b barns.Barn
+	err := s.db.QueryRow(query, id).Scan(&b.ID, &b.Name, &b.TimeZone)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("failed to select barn from database: " + err.Error())
+	}
+	return &b, nil
+}
+
+func (s *Store) InsertBarn(b *barns.Barn) error {
+	query := "insert into barns (name, time_zone) values (?, ?)"
+	result, err := s.db.Exec(query, b.Name, b.TimeZone)
+	if err != nil {
+		return errors.New("failed to insert barn into database: " + err.Error())
+	}
+	b.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateBarn(b *barns.Barn) error {
+	query := "update barns set name = ?, time_zone = ? where id = ?"
+	_, err := s.db.Exec(query, b.Name, b.TimeZone, b.ID)
+	if err != nil {
+		return errors.New("failed to update barn in database: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetBarnsByUserID(userID int64) ([]*barns.Barn, error) {
+	query := "select b.id, b.name, b.time_zone, bo.is_primary_barn from barns b join barn_owners bo on b.id = bo.barn_id join owners o on bo.owner_id = o.id where o.user_id = ?"
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, errors.New("failed to select barns from database: " + err.Error())
+	}
+	defer rows.Close()
+	var result []*barns.Barn
+	for rows.Next() {
+		var b barns.Barn
+		err := rows.Scan(&b.ID, &b.Name, &b.TimeZone, &b.IsPrimary)
+		if err != nil {
+			return nil, errors.New("failed to scan row: " + err.Error())
+		}
+		result = append(result, &b)
+	}
+	return result, nil
+}
+
+func (s *Store) GetOwnerByUserID(userID int64) (*barns.Owner, error) {
+	query := "select id, name from owners where user_id = ?"
+	owner := barns.Owner{UserID: userID}
+	var name sql.NullString
+	err := s.db.QueryRow(query, userID).Scan(&owner.ID, &name)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("failed to scan row: " + err.Error())
+	}
+	owner.Name = name.String
+	return &owner, nil
+}
+
+func (s *Store) InsertOwner(o *barns.Owner) error {
+	query := "insert into owners (user_id) values (?)"
+	result, err := s.db.Exec(query, o.UserID)
+	if err != nil {
+		return errors.New("failed to insert owner into database: " + err.Error())
+	}
+	o.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetBarnOwner(barnID int64, userID int64) (*barns.BarnOwner, error) {
+	query := "select bo.id, bo.barn_id, bo.owner_id, bo.is_primary_barn, bo.role from barn_owners bo join owners o on bo.owner_id = o.id where bo.barn_id = ? and o.user_id = ?"
+	return s.getBarnOwner(query, barnID, userID)
+}
+
+func (s *Store) GetBarnOwnerByID(id int64) (*barns.BarnOwner, error) {
+	query := "select id, barn_id, owner_id, is_primary_barn, role from barn_owners where id = ?"
+	return s.getBarnOwner(query, id)
+}
+
+func (s *Store) getBarnOwner(query string, args ...interface{}) (*barns.BarnOwner, error) {
+	var bo barns.BarnOwner
+	err := s.db.QueryRow(query, args...).Scan(&bo.ID, &bo.BarnID, &bo.OwnerID, &bo.IsPrimaryBarn, &bo.Role)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("failed to select barn owner: " + err.Error())
+	}
+	return &bo, nil
+}
+
+func (s *Store) ListBarnMembers(barnID int64) ([]*barns.Member, error) {
+	query := "select bo.id, bo.barn_id, u.id, u.name, u.email, u.phone, bo.role, bo.is_primary_barn from barn_owners bo join owners o on bo.owner_id = o.id join users u on o.user_id = u.id where bo.barn_id = ? order by u.name"
+	rows, err := s.db.Query(query, barnID)
+	if err != nil {
+		return nil, errors.New("failed to select barn members: " + err.Error())
+	}
+	defer rows.Close()
+	var members []*barns.Member
+	for rows.Next() {
+		var m barns.Member
+		err := rows.Scan(&m.ID, &m.BarnID, &m.UserID, &m.Name, &m.Email, &m.Phone, &m.Role, &m.IsPrimaryBarn)
+		if err != nil {
+			return nil, errors.New("failed to scan row: " + err.Error())
+		}
+		members = append(members, &m)
+	}
+	return members, nil
+}
+
+func (s *Store) UpdateBarnOwnerRole(id int64, role barns.Role) error {
+	_, err := s.db.Exec("update barn_owners set role = ? where id = ?", role, id)
+	if err != nil {
+		return errors.New("failed to update barn member role: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) DeleteBarnOwner(id int64) error {
+	_, err := s.db.Exec("delete from barn_owners where id = ?", id)
+	if err != nil {
+		return errors.New("failed to delete barn member: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) CountBarnOwnersWithRole(barnID int64, role barns.Role) (int, error) {
+	var count int
+	err := s.db.QueryRow("select count(*) from barn_owners where barn_id = ? and role = ?", barnID, role).Scan(&count)
+	if err != nil {
+		return 0, errors.New("failed to count barn members: " + err.Error())
+	}
+	return count, nil
+}
+
+func (s *Store) InsertBarnOwner(bo *barns.BarnOwner) error {
+	query := "insert into barn_owners (barn_id, owner_id, is_primary_barn, role) values (?, ?, ?, ?)"
+	result, err := s.db.Exec(query, bo.BarnID, bo.OwnerID, bo.IsPrimaryBarn, bo.Role)
+	if err != nil {
+		return errors.New("failed to insert barn owner into database: " + err.Error())
+	}
+	bo.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/booking"
+	"hack/utils"
+)
+
+const bookingPolicyColumns = "barn_id, min_notice_hours, max_advance_days, max_rides_per_day, max_rides_per_week, cancel_notice_hours"
+
+func (s *Store) InsertBookingPolicy(p *booking.Policy) error {
+	query := "insert into booking_policies (" + bookingPolicyColumns + ") values (?, ?, ?, ?, ?, ?)"
+	_, err := s.db.Exec(query, p.BarnID, nullableMinutes(p.MinNoticeHours), nullableMinutes(p.MaxAdvanceDays), nullableMinutes(p.MaxRidesPerDay), nullableMinutes(p.MaxRidesPerWeek), nullableMinutes(p.CancelNoticeHours))
+	if err != nil {
+		return errors.New("failed to insert booking policy: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateBookingPolicy(p *booking.Policy) error {
+	query := "update booking_policies set min_notice_hours = ?, max_advance_days = ?, max_rides_per_day = ?, max_rides_per_week = ?, cancel_notice_hours = ? where barn_id = ?"
+	_, err := s.db.Exec(query, nullableMinutes(p.MinNoticeHours), nullableMinutes(p.MaxAdvanceDays), nullableMinutes(p.MaxRidesPerDay), nullableMinutes(p.MaxRidesPerWeek), nullableMinutes(p.CancelNoticeHours), p.BarnID)
+	if err != nil {
+		return errors.New("failed to update booking policy: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) DeleteBookingPolicy(barnID int64) error {
+	_, err := s.db.Exec("delete from booking_policies where barn_id = ?", barnID)
+	if err != nil {
+		return errors.New("failed to delete booking policy: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetBookingPolicy(barnID int64) (*booking.Policy, error) {
+	row := s.db.QueryRow("select "+bookingPolicyColumns+" from booking_policies where barn_id = ?", barnID)
+	var p booking.Policy
+	var minNotice, maxAdvance, perDay, perWeek, cancelNotice sql.NullInt64
+	err := row.Scan(&p.BarnID, &minNotice, &maxAdvance, &perDay, &perWeek, &cancelNotice)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("failed to scan booking policy: " + err.Error())
+	}
+	p.MinNoticeHours = int(minNotice.Int64)
+	p.MaxAdvanceDays = int(maxAdvance.Int64)
+	p.MaxRidesPerDay = int(perDay.Int64)
+	p.MaxRidesPerWeek = int(perWeek.Int64)
+	p.CancelNoticeHours = int(cancelNotice.Int64)
+	return &p, nil
+}
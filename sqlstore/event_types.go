@@ -0,0 +1,54 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/rides"
+	"hack/utils"
+)
+
+func (s *Store) GetEventType(id int64) (*rides.EventType, error) {
+	query := "select id, name, duration_minutes from event_types where id = ?"
+	var t rides.EventType
+	err := s.db.QueryRow(query, id).Scan(&t.ID, &t.Name, &t.DurationMinutes)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("failed to select event type from database: " + err.Error())
+	}
+	return &t, nil
+}
+
+func (s *Store) InsertEventType(t *rides.EventType) error {
+	query := "insert into event_types (name, duration_minutes) values (?, ?)"
+	result, err := s.db.Exec(query, t.Name, t.DurationMinutes)
+	if err != nil {
+		return errors.New("failed to insert event type into database: " + err.Error())
+	}
+	t.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert id: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) ListEventTypes() ([]rides.EventType, error) {
+	query := "select id, name, duration_minutes from event_types"
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, errors.New("failed to query event types: " + err.Error())
+	}
+	defer rows.Close()
+	var types []rides.EventType
+	for rows.Next() {
+		var t rides.EventType
+		err = rows.Scan(&t.ID, &t.Name, &t.DurationMinutes)
+		if err != nil {
+			return nil, errors.New("failed to scan event type: " + err.Error())
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
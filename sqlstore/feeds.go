@@ -0,0 +1,89 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/feeds"
+	"hack/utils"
+)
+
+const feedColumns = "id, barn_id, scope, horse_id, rider_id, name, (select time_zone from barns where id = barn_id) time_zone, token_hash, created_by, created_at, revoked_at"
+
+func nullableID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func (s *Store) InsertFeed(f *feeds.Feed) error {
+	query := "insert into calendar_feeds (barn_id, scope, horse_id, rider_id, name, token_hash, created_by, created_at) values (?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, f.BarnID, f.Scope, nullableID(f.HorseID), nullableID(f.RiderID), f.Name, f.TokenHash, f.CreatedBy, f.CreatedAt)
+	if err != nil {
+		return errors.New("failed to insert calendar feed: " + err.Error())
+	}
+	f.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetFeed(id int64) (*feeds.Feed, error) {
+	return s.getFeed("select "+feedColumns+" from calendar_feeds where id = ?", id)
+}
+
+func (s *Store) GetFeedByTokenHash(tokenHash string) (*feeds.Feed, error) {
+	return s.getFeed("select "+feedColumns+" from calendar_feeds where token_hash = ?", tokenHash)
+}
+
+func (s *Store) getFeed(query string, args ...interface{}) (*feeds.Feed, error) {
+	list, err := s.listFeeds(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return list[0], nil
+}
+
+func (s *Store) ListFeeds(barnID int64) ([]*feeds.Feed, error) {
+	query := "select " + feedColumns + " from calendar_feeds where barn_id = ? and revoked_at is null order by created_at"
+	return s.listFeeds(query, barnID)
+}
+
+func (s *Store) UpdateFeed(f *feeds.Feed) error {
+	query := "update calendar_feeds set name = ?, revoked_at = ? where id = ?"
+	_, err := s.db.Exec(query, f.Name, nullableTime(f.RevokedAt), f.ID)
+	if err != nil {
+		return errors.New("failed to update calendar feed: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) listFeeds(query string, args ...interface{}) ([]*feeds.Feed, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select calendar feeds: " + err.Error())
+	}
+	defer rows.Close()
+	var list []*feeds.Feed
+	for rows.Next() {
+		var f feeds.Feed
+		var horseID, riderID sql.NullInt64
+		var revokedAt sql.NullTime
+		err := rows.Scan(&f.ID, &f.BarnID, &f.Scope, &horseID, &riderID, &f.Name, &f.TimeZone, &f.TokenHash, &f.CreatedBy, &f.CreatedAt, &revokedAt)
+		if err != nil {
+			return nil, errors.New("failed to scan calendar feed: " + err.Error())
+		}
+		f.HorseID = horseID.Int64
+		f.RiderID = riderID.Int64
+		if revokedAt.Valid {
+			f.RevokedAt = &revokedAt.Time
+		}
+		list = append(list, &f)
+	}
+	return list, nil
+}
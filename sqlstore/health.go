@@ -0,0 +1,82 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/horses"
+	"hack/utils"
+)
+
+const healthColumns = "id, horse_id, kind, name, date, provider, notes, next_due, created_by, created_at"
+
+func (s *Store) InsertHealthRecord(r *horses.HealthRecord) error {
+	query := "insert into health_records (horse_id, kind, name, date, provider, notes, next_due, created_by, created_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, r.HorseID, r.Kind, nullableString(r.Name), r.Date.Format("2006-01-02"), nullableString(r.Provider), nullableString(r.Notes), nullableDate(r.NextDue), r.CreatedBy, r.CreatedAt)
+	if err != nil {
+		return errors.New("failed to insert health record: " + err.Error())
+	}
+	r.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateHealthRecord(r *horses.HealthRecord) error {
+	query := "update health_records set kind = ?, name = ?, date = ?, provider = ?, notes = ?, next_due = ? where id = ?"
+	_, err := s.db.Exec(query, r.Kind, nullableString(r.Name), r.Date.Format("2006-01-02"), nullableString(r.Provider), nullableString(r.Notes), nullableDate(r.NextDue), r.ID)
+	if err != nil {
+		return errors.New("failed to update health record: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) DeleteHealthRecord(id int64) error {
+	_, err := s.db.Exec("delete from health_records where id = ?", id)
+	if err != nil {
+		return errors.New("failed to delete health record: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetHealthRecord(id int64) (*horses.HealthRecord, error) {
+	records, err := s.listHealthRecords("select "+healthColumns+" from health_records where id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return records[0], nil
+}
+
+func (s *Store) ListHealthRecords(horseID int64) ([]*horses.HealthRecord, error) {
+	return s.listHealthRecords("select "+healthColumns+" from health_records where horse_id = ? order by date desc, id desc", horseID)
+}
+
+func (s *Store) ListHealthRecordsByBarnID(barnID int64) ([]*horses.HealthRecord, error) {
+	return s.listHealthRecords("select "+healthColumns+" from health_records where horse_id in (select id from horses where barn_id = ?)", barnID)
+}
+
+func (s *Store) listHealthRecords(query string, args ...interface{}) ([]*horses.HealthRecord, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select health records: " + err.Error())
+	}
+	defer rows.Close()
+	records := []*horses.HealthRecord{}
+	for rows.Next() {
+		var r horses.HealthRecord
+		var name, provider, notes sql.NullString
+		err := rows.Scan(&r.ID, &r.HorseID, &r.Kind, &name, &r.Date, &provider, &notes, &r.NextDue, &r.CreatedBy, &r.CreatedAt)
+		if err != nil {
+			return nil, errors.New("failed to scan health record: " + err.Error())
+		}
+		r.Name = name.String
+		r.Provider = provider.String
+		r.Notes = notes.String
+		records = append(records, &r)
+	}
+	return records, nil
+}
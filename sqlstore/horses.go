@@ -0,0 +1,94 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"hack/horses"
+	"hack/riders"
+	"hack/utils"
+)
+
+const horseColumns = "id, name, dob, gender, barn_id, temperament, min_skill_level, max_rider_weight_pounds, disciplines"
+
+func (s *Store) InsertHorse(h *horses.Horse) error {
+	query := "insert into horses (name, dob, gender, barn_id, temperament, min_skill_level, max_rider_weight_pounds, disciplines) values (?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, h.Name, h.DOB.Time.Format("2006-01-02"), nullableString(string(h.Gender)), h.BarnID, nullableString(string(h.Temperament)), nullableString(string(h.MinSkillLevel)), nullableMinutes(h.MaxRiderWeightPounds), nullableString(strings.Join(h.Disciplines, ",")))
+	if err != nil {
+		return errors.New("failed to insert horse into database: " + err.Error())
+	}
+	h.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateHorse(h *horses.Horse) error {
+	query := "update horses set name = ?, dob = ?, gender = ?, temperament = ?, min_skill_level = ?, max_rider_weight_pounds = ?, disciplines = ? where id = ?"
+	_, err := s.db.Exec(query, h.Name, h.DOB.Time.Format("2006-01-02"), nullableString(string(h.Gender)), nullableString(string(h.Temperament)), nullableString(string(h.MinSkillLevel)), nullableMinutes(h.MaxRiderWeightPounds), nullableString(strings.Join(h.Disciplines, ",")), h.ID)
+	if err != nil {
+		return errors.New("failed to update horse: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetHorse(id int64) (*horses.Horse, error) {
+	query := "select " + horseColumns + " from horses where id = ?"
+	var h horses.Horse
+	err := scanHorse(s.db.QueryRow(query, id), &h)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("failed to select horse from database: " + err.Error())
+	}
+	return &h, nil
+}
+
+func (s *Store) GetHorsesByBarnID(barnID int64) ([]*horses.Horse, error) {
+	return s.listHorses("select "+horseColumns+" from horses where barn_id = ?", barnID)
+}
+
+func (s *Store) GetHorsesByUserID(userID int64) ([]*horses.Horse, error) {
+	return s.listHorses("select "+horseColumns+" from horses where barn_id in (select bo.barn_id from barn_owners bo join owners o on bo.owner_id = o.id where o.user_id = ?)", userID)
+}
+
+func (s *Store) listHorses(query string, args ...interface{}) ([]*horses.Horse, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select horses from database: " + err.Error())
+	}
+	defer rows.Close()
+	var result []*horses.Horse
+	for rows.Next() {
+		var h horses.Horse
+		err := scanHorse(rows, &h)
+		if err != nil {
+			return nil, errors.New("failed to scan row: " + err.Error())
+		}
+		result = append(result, &h)
+	}
+	return result, nil
+}
+
+func scanHorse(row scanner, h *horses.Horse) error {
+	var dob time.Time
+	var gender, temperament, skillLevel, disciplines sql.NullString
+	var weight sql.NullInt64
+	err := row.Scan(&h.ID, &h.Name, &dob, &gender, &h.BarnID, &temperament, &skillLevel, &weight, &disciplines)
+	if err != nil {
+		return err
+	}
+	h.DOB = utils.Date{Time: dob}
+	h.Gender = horses.Gender(gender.String)
+	h.Temperament = horses.Temperament(temperament.String)
+	h.MinSkillLevel = riders.SkillLevel(skillLevel.String)
+	h.MaxRiderWeightPounds = int(weight.Int64)
+	if disciplines.String != "" {
+		h.Disciplines = strings.Split(disciplines.String, ",")
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"hack/rides"
+	"hack/utils"
+)
+
+const instructorColumns = "i.id, i.barn_id, i.user_id, u.name"
+
+func (s *Store) InsertInstructor(i *rides.Instructor) error {
+	query := "insert into instructors (barn_id, user_id) values (?, ?)"
+	result, err := s.db.Exec(query, i.BarnID, i.UserID)
+	if err != nil {
+		return errors.New("failed to insert instructor: " + err.Error())
+	}
+	i.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return s.setInstructorEventTypes(i)
+}
+
+// UpdateInstructor only changes the event types; the member an instructor
+// is stays the same.
+func (s *Store) UpdateInstructor(i *rides.Instructor) error {
+	return s.setInstructorEventTypes(i)
+}
+
+func (s *Store) setInstructorEventTypes(i *rides.Instructor) error {
+	_, err := s.db.Exec("delete from instructor_event_types where instructor_id = ?", i.ID)
+	if err != nil {
+		return errors.New("failed to clear instructor event types: " + err.Error())
+	}
+	if len(i.EventTypeIDs) == 0 {
+		return nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(i.EventTypeIDs)), ", ")
+	args := []interface{}{i.BarnID}
+	for _, id := range i.EventTypeIDs {
+		args = append(args, id)
+	}
+	_, err = s.db.Exec("delete from instructor_event_types where barn_id = ? and event_type_id in ("+placeholders+")", args...)
+	if err != nil {
+		return errors.New("failed to take over instructor event types: " + err.Error())
+	}
+	for _, id := range i.EventTypeIDs {
+		_, err = s.db.Exec("insert into instructor_event_types (instructor_id, event_type_id, barn_id) values (?, ?, ?)", i.ID, id, i.BarnID)
+		if err != nil {
+			return errors.New("failed to insert instructor event type: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *Store) DeleteInstructor(id int64) error {
+	_, err := s.db.Exec("delete from instructors where id = ?", id)
+	if err != nil {
+		return errors.New("failed to delete instructor: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetInstructor(id int64) (*rides.Instructor, error) {
+	query := "select " + instructorColumns + " from instructors i join users u on u.id = i.user_id where i.id = ?"
+	instructors, err := s.listInstructors(query, "select instructor_id, event_type_id from instructor_event_types where instructor_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(instructors) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return instructors[0], nil
+}
+
+func (s *Store) ListInstructorsByBarn(barnID int64) ([]*rides.Instructor, error) {
+	query := "select " + instructorColumns + " from instructors i join users u on u.id = i.user_id where i.barn_id = ? order by u.name"
+	return s.listInstructors(query, "select instructor_id, event_type_id from instructor_event_types where barn_id = ?", barnID)
+}
+
+// listInstructors runs query and fills in event types from eventTypeQuery,
+// both taking the same argument.
+func (s *Store) listInstructors(query string, eventTypeQuery string, arg interface{}) ([]*rides.Instructor, error) {
+	rows, err := s.db.Query(query, arg)
+	if err != nil {
+		return nil, errors.New("failed to select instructors: " + err.Error())
+	}
+	defer rows.Close()
+	instructors := []*rides.Instructor{}
+	byID := make(map[int64]*rides.Instructor)
+	for rows.Next() {
+		i := &rides.Instructor{EventTypeIDs: []int64{}}
+		err := rows.Scan(&i.ID, &i.BarnID, &i.UserID, &i.Name)
+		if err != nil {
+			return nil, errors.New("failed to scan instructor row: " + err.Error())
+		}
+		instructors = append(instructors, i)
+		byID[i.ID] = i
+	}
+	typeRows, err := s.db.Query(eventTypeQuery+" order by event_type_id", arg)
+	if err != nil {
+		return nil, errors.New("failed to select instructor event types: " + err.Error())
+	}
+	defer typeRows.Close()
+	for typeRows.Next() {
+		var instructorID, eventTypeID int64
+		err := typeRows.Scan(&instructorID, &eventTypeID)
+		if err != nil {
+			return nil, errors.New("failed to scan instructor event type row: " + err.Error())
+		}
+		if i, ok := byID[instructorID]; ok {
+			i.EventTypeIDs = append(i.EventTypeIDs, eventTypeID)
+		}
+	}
+	return instructors, nil
+}
+
+func (s *Store) DefaultInstructor(horseID int64, eventTypeID int64) (*int64, error) {
+	query := "select instructor_id from instructor_event_types where event_type_id = ? and barn_id = (select barn_id from horses where id = ?)"
+	return s.defaultInstructor(query, eventTypeID, horseID)
+}
+
+func (s *Store) DefaultBarnInstructor(barnID int64, eventTypeID int64) (*int64, error) {
+	query := "select instructor_id from instructor_event_types where event_type_id = ? and barn_id = ?"
+	return s.defaultInstructor(query, eventTypeID, barnID)
+}
+
+func (s *Store) defaultInstructor(query string, args ...interface{}) (*int64, error) {
+	var id int64
+	err := s.db.QueryRow(query, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to select default instructor: " + err.Error())
+	}
+	return &id, nil
+}
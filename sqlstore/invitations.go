@@ -0,0 +1,108 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"hack/barns"
+	"hack/utils"
+)
+
+const invitationColumns = "id, barn_id, role, phone, email, code_hash, invited_by, created_at, expires_at, accepted_at, accepted_by, revoked_at"
+
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullableTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func (s *Store) InsertInvitation(inv *barns.Invitation) error {
+	query := "insert into barn_invitations (barn_id, role, phone, email, code_hash, invited_by, created_at, expires_at) values (?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, inv.BarnID, inv.Role, nullableString(inv.Phone), nullableString(inv.Email), inv.CodeHash, inv.InvitedBy, inv.CreatedAt, inv.ExpiresAt)
+	if err != nil {
+		return errors.New("failed to insert invitation: " + err.Error())
+	}
+	inv.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetInvitation(id int64) (*barns.Invitation, error) {
+	return s.getInvitation("select "+invitationColumns+" from barn_invitations where id = ?", id)
+}
+
+func (s *Store) GetInvitationByCodeHash(codeHash string) (*barns.Invitation, error) {
+	return s.getInvitation("select "+invitationColumns+" from barn_invitations where code_hash = ?", codeHash)
+}
+
+func (s *Store) getInvitation(query string, args ...interface{}) (*barns.Invitation, error) {
+	invitations, err := s.listInvitations(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(invitations) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return invitations[0], nil
+}
+
+func (s *Store) ListPendingInvitations(barnID int64, now time.Time) ([]*barns.Invitation, error) {
+	query := "select " + invitationColumns + " from barn_invitations where barn_id = ? and accepted_at is null and revoked_at is null and expires_at > ? order by created_at"
+	return s.listInvitations(query, barnID, now)
+}
+
+func (s *Store) UpdateInvitation(inv *barns.Invitation) error {
+	query := "update barn_invitations set accepted_at = ?, accepted_by = ?, revoked_at = ? where id = ?"
+	var acceptedBy interface{}
+	if inv.AcceptedBy != nil {
+		acceptedBy = *inv.AcceptedBy
+	}
+	_, err := s.db.Exec(query, nullableTime(inv.AcceptedAt), acceptedBy, nullableTime(inv.RevokedAt), inv.ID)
+	if err != nil {
+		return errors.New("failed to update invitation: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) listInvitations(query string, args ...interface{}) ([]*barns.Invitation, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select invitations: " + err.Error())
+	}
+	defer rows.Close()
+	var invitations []*barns.Invitation
+	for rows.Next() {
+		var inv barns.Invitation
+		var phone, email sql.NullString
+		var acceptedAt, revokedAt sql.NullTime
+		var acceptedBy sql.NullInt64
+		err := rows.Scan(&inv.ID, &inv.BarnID, &inv.Role, &phone, &email, &inv.CodeHash, &inv.InvitedBy, &inv.CreatedAt, &inv.ExpiresAt, &acceptedAt, &acceptedBy, &revokedAt)
+		if err != nil {
+			return nil, errors.New("failed to scan invitation: " + err.Error())
+		}
+		inv.Phone = phone.String
+		inv.Email = email.String
+		if acceptedAt.Valid {
+			inv.AcceptedAt = &acceptedAt.Time
+		}
+		if acceptedBy.Valid {
+			inv.AcceptedBy = &acceptedBy.Int64
+		}
+		if revokedAt.Valid {
+			inv.RevokedAt = &revokedAt.Time
+		}
+		invitations = append(invitations, &inv)
+	}
+	return invitations, nil
+}
@@ -0,0 +1,91 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/rides"
+	"hack/utils"
+)
+
+const lessonColumns = "id, barn_id, event_type_id, (select name from event_types where id = event_type_id) event_type_name, date, time, coalesce(duration_minutes, (select duration_minutes from event_types where id = event_type_id)), (select time_zone from barns where id = barn_id) time_zone, instructor_id, (select u.name from instructors i join users u on u.id = i.user_id where i.id = instructor_id) instructor_name, resource_id, (select name from resources where id = resource_id) resource_name, max_riders, hold_minutes, notes, status"
+
+func (s *Store) InsertLesson(l *rides.Lesson) error {
+	query := "insert into lessons (barn_id, event_type_id, date, time, duration_minutes, instructor_id, resource_id, max_riders, hold_minutes, notes, status) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, l.BarnID, l.EventTypeID, l.Date.Format("2006-01-02"), l.Time, nullableMinutes(l.DurationMinutes), l.InstructorID, l.ResourceID, l.MaxRiders, nullableMinutes(l.HoldMinutes), l.Notes, l.Status)
+	if err != nil {
+		return errors.New("failed to insert lesson: " + err.Error())
+	}
+	l.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateLesson(l *rides.Lesson) error {
+	query := "update lessons set event_type_id = ?, date = ?, time = ?, duration_minutes = ?, instructor_id = ?, resource_id = ?, max_riders = ?, hold_minutes = ?, notes = ? where id = ?"
+	_, err := s.db.Exec(query, l.EventTypeID, l.Date.Format("2006-01-02"), l.Time, nullableMinutes(l.DurationMinutes), l.InstructorID, l.ResourceID, l.MaxRiders, nullableMinutes(l.HoldMinutes), l.Notes, l.ID)
+	if err != nil {
+		return errors.New("failed to update lesson: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateLessonStatus(id int64, status rides.Status) error {
+	_, err := s.db.Exec("update lessons set status = ? where id = ?", status, id)
+	if err != nil {
+		return errors.New("failed to update lesson status: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetLesson(id int64) (*rides.Lesson, error) {
+	lessons, err := s.listLessons("select "+lessonColumns+" from lessons where id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(lessons) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return lessons[0], nil
+}
+
+func (s *Store) ListLessonsByBarn(barnID int64, from utils.Date, to utils.Date) ([]*rides.Lesson, error) {
+	query := "select " + lessonColumns + " from lessons where barn_id = ? and date >= ? and date <= ? order by date, time"
+	return s.listLessons(query, barnID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+}
+
+func (s *Store) HorseAndRiderInBarn(barnID int64, horseID int64, riderID int64) (bool, error) {
+	query := "select count(*) from horses h join riders r on r.barn_id = h.barn_id where h.id = ? and r.id = ? and h.barn_id = ?"
+	var count int
+	err := s.db.QueryRow(query, horseID, riderID, barnID).Scan(&count)
+	if err != nil {
+		return false, errors.New("failed to check horse and rider barn: " + err.Error())
+	}
+	return count > 0, nil
+}
+
+func (s *Store) listLessons(query string, args ...interface{}) ([]*rides.Lesson, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select lessons: " + err.Error())
+	}
+	defer rows.Close()
+	lessons := []*rides.Lesson{}
+	for rows.Next() {
+		var l rides.Lesson
+		var instructorName, resourceName, notes sql.NullString
+		var hold sql.NullInt64
+		err := rows.Scan(&l.ID, &l.BarnID, &l.EventTypeID, &l.EventTypeName, &l.Date, &l.Time, &l.DurationMinutes, &l.TimeZone, &l.InstructorID, &instructorName, &l.ResourceID, &resourceName, &l.MaxRiders, &hold, &notes, &l.Status)
+		if err != nil {
+			return nil, errors.New("failed to scan lesson row: " + err.Error())
+		}
+		l.InstructorName = instructorName.String
+		l.ResourceName = resourceName.String
+		l.HoldMinutes = int(hold.Int64)
+		l.Notes = notes.String
+		lessons = append(lessons, &l)
+	}
+	return lessons, nil
+}
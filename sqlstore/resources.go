@@ -0,0 +1,73 @@
+package sqlstore
+
+import (
+	"errors"
+
+	"hack/rides"
+	"hack/utils"
+)
+
+const resourceColumns = "id, barn_id, name, kind, capacity"
+
+func (s *Store) InsertResource(r *rides.Resource) error {
+	query := "insert into resources (barn_id, name, kind, capacity) values (?, ?, ?, ?)"
+	result, err := s.db.Exec(query, r.BarnID, r.Name, r.Kind, r.Capacity)
+	if err != nil {
+		return errors.New("failed to insert resource: " + err.Error())
+	}
+	r.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateResource(r *rides.Resource) error {
+	query := "update resources set name = ?, kind = ?, capacity = ? where id = ?"
+	_, err := s.db.Exec(query, r.Name, r.Kind, r.Capacity, r.ID)
+	if err != nil {
+		return errors.New("failed to update resource: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) DeleteResource(id int64) error {
+	_, err := s.db.Exec("delete from resources where id = ?", id)
+	if err != nil {
+		return errors.New("failed to delete resource: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetResource(id int64) (*rides.Resource, error) {
+	resources, err := s.listResources("select "+resourceColumns+" from resources where id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return resources[0], nil
+}
+
+func (s *Store) ListResourcesByBarn(barnID int64) ([]*rides.Resource, error) {
+	return s.listResources("select "+resourceColumns+" from resources where barn_id = ? order by name", barnID)
+}
+
+func (s *Store) listResources(query string, args ...interface{}) ([]*rides.Resource, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select resources: " + err.Error())
+	}
+	defer rows.Close()
+	resources := []*rides.Resource{}
+	for rows.Next() {
+		var r rides.Resource
+		err := rows.Scan(&r.ID, &r.BarnID, &r.Name, &r.Kind, &r.Capacity)
+		if err != nil {
+			return nil, errors.New("failed to scan resource row: " + err.Error())
+		}
+		resources = append(resources, &r)
+	}
+	return resources, nil
+}
@@ -0,0 +1,172 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"hack/riders"
+	"hack/utils"
+)
+
+// riderColumns leaves out medical notes, which only GetRider reads.
+const riderColumns = "id, name, barn_id, user_id, skill_level, height_inches, weight_pounds, dob, emergency_contacts"
+
+// emergencyContacts stores a rider's contacts as JSON.
+func emergencyContacts(contacts []riders.EmergencyContact) (interface{}, error) {
+	if len(contacts) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(contacts)
+	if err != nil {
+		return nil, errors.New("failed to encode emergency contacts: " + err.Error())
+	}
+	return string(data), nil
+}
+
+func (s *Store) InsertRider(r *riders.Rider) error {
+	contacts, err := emergencyContacts(r.EmergencyContacts)
+	if err != nil {
+		return err
+	}
+	query := "insert into riders (name, barn_id, skill_level, height_inches, weight_pounds, dob, emergency_contacts, medical_notes) values (?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, r.Name, r.BarnID, nullableString(string(r.SkillLevel)), nullableMinutes(r.HeightInches), nullableMinutes(r.WeightPounds), nullableDate(r.DOB), contacts, nullableString(r.MedicalNotes))
+	if err != nil {
+		return errors.New("failed to insert rider into database: " + err.Error())
+	}
+	r.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateRider(r *riders.Rider) error {
+	contacts, err := emergencyContacts(r.EmergencyContacts)
+	if err != nil {
+		return err
+	}
+	query := "update riders set name = ?, skill_level = ?, height_inches = ?, weight_pounds = ?, dob = ?, emergency_contacts = ?, medical_notes = ? where id = ?"
+	_, err = s.db.Exec(query, r.Name, nullableString(string(r.SkillLevel)), nullableMinutes(r.HeightInches), nullableMinutes(r.WeightPounds), nullableDate(r.DOB), contacts, nullableString(r.MedicalNotes), r.ID)
+	if err != nil {
+		return errors.New("failed to update rider: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateRiderUser(riderID int64, userID *int64) error {
+	_, err := s.db.Exec("update riders set user_id = ? where id = ?", userID, riderID)
+	if err != nil {
+		return errors.New("failed to update rider user: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetRider(id int64) (*riders.Rider, error) {
+	query := "select " + riderColumns + ", medical_notes from riders where id = ?"
+	var r riders.Rider
+	var medicalNotes sql.NullString
+	err := scanRider(s.db.QueryRow(query, id), &r, &medicalNotes)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("failed to select rider from database: " + err.Error())
+	}
+	r.MedicalNotes = medicalNotes.String
+	return &r, nil
+}
+
+func (s *Store) GetRidersByBarnID(barnID int64) ([]*riders.Rider, error) {
+	return s.listRiders("select "+riderColumns+" from riders where barn_id = ?", barnID)
+}
+
+func (s *Store) GetRidersByUserID(userID int64) ([]*riders.Rider, error) {
+	return s.listRiders("select "+riderColumns+" from riders where barn_id in (select bo.barn_id from barn_owners bo join owners o on bo.owner_id = o.id where o.user_id = ?)", userID)
+}
+
+func (s *Store) GetLinkedRiders(userID int64) ([]*riders.Rider, error) {
+	return s.listRiders("select "+riderColumns+" from riders where (user_id = ? or id in (select rider_id from rider_guardians where user_id = ?)) and barn_id in (select bo.barn_id from barn_owners bo join owners o on bo.owner_id = o.id where o.user_id = ?) order by name", userID, userID, userID)
+}
+
+func (s *Store) listRiders(query string, args ...interface{}) ([]*riders.Rider, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select riders from database: " + err.Error())
+	}
+	defer rows.Close()
+	var result []*riders.Rider
+	for rows.Next() {
+		var r riders.Rider
+		err := scanRider(rows, &r)
+		if err != nil {
+			return nil, errors.New("failed to scan row: " + err.Error())
+		}
+		result = append(result, &r)
+	}
+	return result, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRider reads riderColumns, and then extra.
+func scanRider(row scanner, r *riders.Rider, extra ...interface{}) error {
+	var skillLevel, contacts sql.NullString
+	var height, weight sql.NullInt64
+	dest := []interface{}{&r.ID, &r.Name, &r.BarnID, &r.UserID, &skillLevel, &height, &weight, &r.DOB, &contacts}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	r.SkillLevel = riders.SkillLevel(skillLevel.String)
+	r.HeightInches = int(height.Int64)
+	r.WeightPounds = int(weight.Int64)
+	if contacts.String != "" {
+		err = json.Unmarshal([]byte(contacts.String), &r.EmergencyContacts)
+		if err != nil {
+			return errors.New("failed to decode emergency contacts: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *Store) InsertGuardian(g *riders.Guardian) error {
+	query := "insert into rider_guardians (rider_id, user_id, relationship, created_at) values (?, ?, ?, ?)"
+	_, err := s.db.Exec(query, g.RiderID, g.UserID, nullableString(g.Relationship), g.CreatedAt)
+	if err != nil {
+		return errors.New("failed to insert guardian: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) DeleteGuardian(riderID int64, userID int64) error {
+	_, err := s.db.Exec("delete from rider_guardians where rider_id = ? and user_id = ?", riderID, userID)
+	if err != nil {
+		return errors.New("failed to delete guardian: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) ListGuardians(riderID int64) ([]*riders.Guardian, error) {
+	query := "select g.rider_id, g.user_id, u.name, g.relationship, g.created_at from rider_guardians g join users u on u.id = g.user_id where g.rider_id = ? order by g.created_at"
+	rows, err := s.db.Query(query, riderID)
+	if err != nil {
+		return nil, errors.New("failed to select guardians: " + err.Error())
+	}
+	defer rows.Close()
+	guardians := []*riders.Guardian{}
+	for rows.Next() {
+		var g riders.Guardian
+		var name, relationship sql.NullString
+		err := rows.Scan(&g.RiderID, &g.UserID, &name, &relationship, &g.CreatedAt)
+		if err != nil {
+			return nil, errors.New("failed to scan guardian: " + err.Error())
+		}
+		g.Name = name.String
+		g.Relationship = relationship.String
+		guardians = append(guardians, &g)
+	}
+	return guardians, nil
+}
@@ -0,0 +1,220 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"hack/rides"
+	"hack/utils"
+)
+
+const rideDetailColumns = "id, horse_id, (select name from horses where id = horse_id) horse_name, rider_id, (select name from riders where id = rider_id) rider_name, event_type_id, (select name from event_types where id = event_type_id) event_type_name, date, time, notes, status, schedule_id, occurrence_date, coalesce(duration_minutes, (select duration_minutes from event_types where id = event_type_id)), starts_at, (select time_zone from barns where id = (select barn_id from horses where id = horse_id)) time_zone, resource_id, (select name from resources where id = resource_id) resource_name, instructor_id, (select u.name from instructors i join users u on u.id = i.user_id where i.id = instructor_id) instructor_name, lesson_id, " + completionColumns
+
+const rideColumns = "id, horse_id, rider_id, event_type_id, date, time, notes, status, schedule_id, occurrence_date, duration_minutes, starts_at, resource_id, instructor_id, lesson_id, " + completionColumns
+
+func (s *Store) GetRide(id int64) (*rides.Ride, error) {
+	query := "select " + rideColumns + " from rides where id = ?"
+	return s.getRide(query, id)
+}
+
+func (s *Store) GetOccurrenceRide(scheduleID int64, date utils.Date) (*rides.Ride, error) {
+	query := "select " + rideColumns + " from rides where schedule_id = ? and occurrence_date = ?"
+	return s.getRide(query, scheduleID, date.Format("2006-01-02"))
+}
+
+func (s *Store) getRide(query string, args ...interface{}) (*rides.Ride, error) {
+	result, err := s.listRides(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return result[0], nil
+}
+
+func (s *Store) ListOccurrenceRides(scheduleIDs []int64, from utils.Date, to utils.Date) ([]*rides.Ride, error) {
+	if len(scheduleIDs) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(scheduleIDs)), ", ")
+	query := "select " + rideColumns + " from rides where schedule_id in (" + placeholders + ") and occurrence_date >= ? and occurrence_date <= ?"
+	var args []interface{}
+	for _, id := range scheduleIDs {
+		args = append(args, id)
+	}
+	args = append(args, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	return s.listRides(query, args...)
+}
+
+func (s *Store) MoveOccurrenceRides(fromScheduleID int64, toScheduleID int64, from utils.Date) error {
+	query := "update rides set schedule_id = ? where schedule_id = ? and occurrence_date >= ?"
+	_, err := s.db.Exec(query, toScheduleID, fromScheduleID, from.Format("2006-01-02"))
+	if err != nil {
+		return errors.New("failed to move occurrence rides: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) listRides(query string, args ...interface{}) ([]*rides.Ride, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select rides from database: " + err.Error())
+	}
+	defer rows.Close()
+	var result []*rides.Ride
+	for rows.Next() {
+		var r rides.Ride
+		var notes sql.NullString
+		var duration sql.NullInt64
+		var startsAt sql.NullTime
+		var c completion
+		err := rows.Scan(&r.ID, &r.HorseID, &r.RiderID, &r.EventTypeID, &r.Date, &r.Time, &notes, &r.Status, &r.ScheduleID, &r.OccurrenceDate, &duration, &startsAt, &r.ResourceID, &r.InstructorID, &r.LessonID, &c.actualMinutes, &c.intensity, &c.trainerNotes, &c.behaviorRating)
+		if err != nil {
+			return nil, errors.New("failed to scan ride row: " + err.Error())
+		}
+		r.Notes = notes.String
+		r.DurationMinutes = int(duration.Int64)
+		r.StartsAt = instant(startsAt)
+		r.Completion = c.get(r.Status)
+		result = append(result, &r)
+	}
+	return result, nil
+}
+
+func (s *Store) InsertRide(r *rides.Ride) error {
+	query := "insert into rides (horse_id, rider_id, event_type_id, date, time, notes, status, schedule_id, occurrence_date, duration_minutes, starts_at, resource_id, instructor_id, lesson_id) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, r.HorseID, r.RiderID, r.EventTypeID, r.Date.Format("2006-01-02"), r.Time, r.Notes, r.Status, r.ScheduleID, nullableDate(r.OccurrenceDate), nullableMinutes(r.DurationMinutes), nullableInstant(r.StartsAt), r.ResourceID, r.InstructorID, r.LessonID)
+	if err != nil {
+		return errors.New("failed to insert ride into database: " + err.Error())
+	}
+	r.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateRide(r *rides.Ride) error {
+	query := "update rides set horse_id = ?, rider_id = ?, event_type_id = ?, date = ?, time = ?, notes = ?, status = ?, schedule_id = ?, occurrence_date = ?, duration_minutes = ?, starts_at = ?, resource_id = ?, instructor_id = ?, lesson_id = ? where id = ?"
+	_, err := s.db.Exec(query, r.HorseID, r.RiderID, r.EventTypeID, r.Date.Format("2006-01-02"), r.Time, r.Notes, r.Status, r.ScheduleID, nullableDate(r.OccurrenceDate), nullableMinutes(r.DurationMinutes), nullableInstant(r.StartsAt), r.ResourceID, r.InstructorID, r.LessonID, r.ID)
+	if err != nil {
+		return errors.New("failed to update ride in database: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateRideStartsAt(id int64, startsAt *time.Time) error {
+	query := "update rides set starts_at = ? where id = ?"
+	_, err := s.db.Exec(query, nullableInstant(startsAt), id)
+	if err != nil {
+		return errors.New("failed to update ride start: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetHorseTimeZone(horseID int64) (string, error) {
+	query := "select b.time_zone from horses h join barns b on b.id = h.barn_id where h.id = ?"
+	var timeZone string
+	err := s.db.QueryRow(query, horseID).Scan(&timeZone)
+	if err == sql.ErrNoRows {
+		return "", utils.ErrNotFound
+	}
+	if err != nil {
+		return "", errors.New("failed to select time zone from database: " + err.Error())
+	}
+	return timeZone, nil
+}
+
+func (s *Store) UpdateRideStatus(id int64, status rides.Status) error {
+	query := "update rides set status = ? where id = ?"
+	_, err := s.db.Exec(query, status, id)
+	if err != nil {
+		return errors.New("failed to update ride status: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) ListRidesByBarn(barnID int64, from utils.Date, to utils.Date) ([]*rides.RideDetail, error) {
+	query := "select " + rideDetailColumns + " from rides where date >= ? and date <= ? and horse_id in (select id from horses where barn_id = ?) and rider_id in (select id from riders where barn_id = ?) order by date, time"
+	return s.listRideDetails(query, from.Format("2006-01-02"), to.Format("2006-01-02"), barnID, barnID)
+}
+
+func (s *Store) ListRidesByHorse(horseID int64, from utils.Date, to utils.Date) ([]*rides.RideDetail, error) {
+	query := "select " + rideDetailColumns + " from rides where horse_id = ? and date >= ? and date <= ? order by date, time"
+	return s.listRideDetails(query, horseID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+}
+
+func (s *Store) ListRidesByRider(riderID int64, from utils.Date, to utils.Date) ([]*rides.RideDetail, error) {
+	query := "select " + rideDetailColumns + " from rides where rider_id = ? and date >= ? and date <= ? order by date, time"
+	return s.listRideDetails(query, riderID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+}
+
+func (s *Store) ListRidesByResource(resourceID int64, from utils.Date, to utils.Date) ([]*rides.RideDetail, error) {
+	query := "select " + rideDetailColumns + " from rides where resource_id = ? and date >= ? and date <= ? order by date, time"
+	return s.listRideDetails(query, resourceID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+}
+
+func (s *Store) ListRidesByInstructor(instructorID int64, from utils.Date, to utils.Date) ([]*rides.RideDetail, error) {
+	query := "select " + rideDetailColumns + " from rides where instructor_id = ? and date >= ? and date <= ? order by date, time"
+	return s.listRideDetails(query, instructorID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+}
+
+func (s *Store) ListLessonRides(lessonID int64) ([]*rides.RideDetail, error) {
+	query := "select " + rideDetailColumns + " from rides where lesson_id = ? order by id"
+	return s.listRideDetails(query, lessonID)
+}
+
+func (s *Store) listRideDetails(query string, args ...interface{}) ([]*rides.RideDetail, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select rides from database: " + err.Error())
+	}
+	defer rows.Close()
+	var result []*rides.RideDetail
+	for rows.Next() {
+		var r rides.RideDetail
+		var notes, resourceName, instructorName sql.NullString
+		var startsAt sql.NullTime
+		var c completion
+		err := rows.Scan(&r.ID, &r.HorseID, &r.HorseName, &r.RiderID, &r.RiderName, &r.EventTypeID, &r.EventTypeName, &r.Date, &r.Time, &notes, &r.Status, &r.ScheduleID, &r.OccurrenceDate, &r.DurationMinutes, &startsAt, &r.TimeZone, &r.ResourceID, &resourceName, &r.InstructorID, &instructorName, &r.LessonID, &c.actualMinutes, &c.intensity, &c.trainerNotes, &c.behaviorRating)
+		if err != nil {
+			return nil, errors.New("failed to scan ride row: " + err.Error())
+		}
+		r.Notes = notes.String
+		r.ResourceName = resourceName.String
+		r.InstructorName = instructorName.String
+		r.StartsAt = instant(startsAt)
+		r.Completion = c.get(r.Status)
+		result = append(result, &r)
+	}
+	return result, nil
+}
+
+// nullableMinutes stores a duration of zero, meaning the event type's, as
+// null.
+func nullableMinutes(minutes int) interface{} {
+	if minutes == 0 {
+		return nil
+	}
+	return minutes
+}
+
+// nullableInstant stores instants as UTC without a zone, which both MySQL
+// datetime and SQLite read back the same way.
+func nullableInstant(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+func instant(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := time.Date(t.Time.Year(), t.Time.Month(), t.Time.Day(), t.Time.Hour(), t.Time.Minute(), t.Time.Second(), 0, time.UTC)
+	return &utc
+}
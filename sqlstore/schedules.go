@@ -0,0 +1,115 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/rides"
+	"hack/utils"
+)
+
+const scheduleColumns = "id, horse_id, (select name from horses where id = horse_id) horse_name, rider_id, (select name from riders where id = rider_id) rider_name, event_type_id, (select name from event_types where id = event_type_id) event_type_name, (select duration_minutes from event_types where id = event_type_id) event_type_duration, start_date, end_date, time, duration_minutes, rrule, (select time_zone from barns where id = (select barn_id from horses where id = horse_id)) time_zone, resource_id, (select name from resources where id = resource_id) resource_name, instructor_id, (select u.name from instructors i join users u on u.id = i.user_id where i.id = instructor_id) instructor_name"
+
+func nullableDate(d *utils.Date) interface{} {
+	if d == nil {
+		return nil
+	}
+	return d.Format("2006-01-02")
+}
+
+func (s *Store) GetSchedule(id int64) (*rides.Schedule, error) {
+	query := "select " + scheduleColumns + " from schedules where id = ?"
+	schedules, err := s.listSchedules(query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return schedules[0], nil
+}
+
+func (s *Store) InsertSchedule(sc *rides.Schedule) error {
+	query := "insert into schedules (horse_id, rider_id, event_type_id, start_date, end_date, time, duration_minutes, rrule, resource_id, instructor_id) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, sc.HorseID, sc.RiderID, sc.EventType.ID, sc.StartDate.Format("2006-01-02"), nullableDate(sc.EndDate), sc.Time, nullableMinutes(sc.DurationMinutes), sc.RRule, sc.ResourceID, sc.InstructorID)
+	if err != nil {
+		return errors.New("failed to insert schedule into database: " + err.Error())
+	}
+	sc.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateSchedule(sc *rides.Schedule) error {
+	query := "update schedules set horse_id = ?, rider_id = ?, event_type_id = ?, start_date = ?, end_date = ?, time = ?, duration_minutes = ?, rrule = ?, resource_id = ?, instructor_id = ? where id = ?"
+	_, err := s.db.Exec(query, sc.HorseID, sc.RiderID, sc.EventType.ID, sc.StartDate.Format("2006-01-02"), nullableDate(sc.EndDate), sc.Time, nullableMinutes(sc.DurationMinutes), sc.RRule, sc.ResourceID, sc.InstructorID, sc.ID)
+	if err != nil {
+		return errors.New("failed to update schedule in database: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) DeleteSchedule(id int64) error {
+	query := "delete from schedules where id = ?"
+	_, err := s.db.Exec(query, id)
+	if err != nil {
+		return errors.New("failed to delete schedule from database: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) ListSchedulesByBarn(barnID int64) ([]*rides.Schedule, error) {
+	query := "select " + scheduleColumns + " from schedules where horse_id in (select id from horses where barn_id = ?) and rider_id in (select id from riders where barn_id = ?) order by start_date, time"
+	return s.listSchedules(query, barnID, barnID)
+}
+
+func (s *Store) ListActiveSchedulesByBarn(barnID int64, from utils.Date, to utils.Date) ([]*rides.Schedule, error) {
+	query := "select " + scheduleColumns + " from schedules where start_date <= ? and (end_date is null or end_date >= ?) and horse_id in (select id from horses where barn_id = ?) and rider_id in (select id from riders where barn_id = ?) order by time"
+	return s.listSchedules(query, to.Format("2006-01-02"), from.Format("2006-01-02"), barnID, barnID)
+}
+
+func (s *Store) ListActiveSchedulesByHorse(horseID int64, from utils.Date, to utils.Date) ([]*rides.Schedule, error) {
+	query := "select " + scheduleColumns + " from schedules where horse_id = ? and start_date <= ? and (end_date is null or end_date >= ?) order by start_date, time"
+	return s.listSchedules(query, horseID, to.Format("2006-01-02"), from.Format("2006-01-02"))
+}
+
+func (s *Store) ListActiveSchedulesByRider(riderID int64, from utils.Date, to utils.Date) ([]*rides.Schedule, error) {
+	query := "select " + scheduleColumns + " from schedules where rider_id = ? and start_date <= ? and (end_date is null or end_date >= ?) order by start_date, time"
+	return s.listSchedules(query, riderID, to.Format("2006-01-02"), from.Format("2006-01-02"))
+}
+
+func (s *Store) ListActiveSchedulesByResource(resourceID int64, from utils.Date, to utils.Date) ([]*rides.Schedule, error) {
+	query := "select " + scheduleColumns + " from schedules where resource_id = ? and start_date <= ? and (end_date is null or end_date >= ?) order by start_date, time"
+	return s.listSchedules(query, resourceID, to.Format("2006-01-02"), from.Format("2006-01-02"))
+}
+
+func (s *Store) ListActiveSchedulesByInstructor(instructorID int64, from utils.Date, to utils.Date) ([]*rides.Schedule, error) {
+	query := "select " + scheduleColumns + " from schedules where instructor_id = ? and start_date <= ? and (end_date is null or end_date >= ?) order by start_date, time"
+	return s.listSchedules(query, instructorID, to.Format("2006-01-02"), from.Format("2006-01-02"))
+}
+
+func (s *Store) listSchedules(query string, args ...interface{}) ([]*rides.Schedule, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to query schedules from database: " + err.Error())
+	}
+	defer rows.Close()
+
+	var schedules []*rides.Schedule
+	for rows.Next() {
+		var sc rides.Schedule
+		var duration sql.NullInt64
+		var resourceName, instructorName sql.NullString
+		err := rows.Scan(&sc.ID, &sc.HorseID, &sc.HorseName, &sc.RiderID, &sc.RiderName, &sc.EventType.ID, &sc.EventType.Name, &sc.EventType.DurationMinutes, &sc.StartDate, &sc.EndDate, &sc.Time, &duration, &sc.RRule, &sc.TimeZone, &sc.ResourceID, &resourceName, &sc.InstructorID, &instructorName)
+		if err != nil {
+			return nil, errors.New("failed to scan schedule from database: " + err.Error())
+		}
+		sc.DurationMinutes = int(duration.Int64)
+		sc.ResourceName = resourceName.String
+		sc.InstructorName = instructorName.String
+		schedules = append(schedules, &sc)
+	}
+	return schedules, nil
+}
@@ -0,0 +1,71 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/rides"
+)
+
+const completionColumns = "actual_minutes, intensity, trainer_notes, behavior_rating"
+
+// completion scans the completion columns of a ride.
+type completion struct {
+	actualMinutes  sql.NullInt64
+	intensity      sql.NullString
+	trainerNotes   sql.NullString
+	behaviorRating sql.NullInt64
+}
+
+func (c *completion) get(status rides.Status) *rides.Completion {
+	if status != rides.Completed {
+		return nil
+	}
+	return &rides.Completion{
+		ActualMinutes:  int(c.actualMinutes.Int64),
+		Intensity:      rides.Intensity(c.intensity.String),
+		TrainerNotes:   c.trainerNotes.String,
+		BehaviorRating: int(c.behaviorRating.Int64),
+	}
+}
+
+func (s *Store) UpdateRideCompletion(id int64, c *rides.Completion) error {
+	query := "update rides set actual_minutes = ?, intensity = ?, trainer_notes = ?, behavior_rating = ? where id = ?"
+	_, err := s.db.Exec(query, nullableMinutes(c.ActualMinutes), nullableString(string(c.Intensity)), nullableString(c.TrainerNotes), nullableMinutes(c.BehaviorRating), id)
+	if err != nil {
+		return errors.New("failed to update ride completion: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) InsertStatusChange(c *rides.StatusChange) error {
+	query := "insert into ride_status_changes (ride_id, from_status, to_status, actor_id, changed_at) values (?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, c.RideID, c.From, c.To, c.ActorID, c.ChangedAt)
+	if err != nil {
+		return errors.New("failed to insert ride status change: " + err.Error())
+	}
+	c.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) ListStatusChanges(rideID int64) ([]*rides.StatusChange, error) {
+	query := "select id, ride_id, from_status, to_status, actor_id, changed_at from ride_status_changes where ride_id = ? order by changed_at, id"
+	rows, err := s.db.Query(query, rideID)
+	if err != nil {
+		return nil, errors.New("failed to select ride status changes: " + err.Error())
+	}
+	defer rows.Close()
+	changes := []*rides.StatusChange{}
+	for rows.Next() {
+		var c rides.StatusChange
+		err := rows.Scan(&c.ID, &c.RideID, &c.From, &c.To, &c.ActorID, &c.ChangedAt)
+		if err != nil {
+			return nil, errors.New("failed to scan ride status change: " + err.Error())
+		}
+		changes = append(changes, &c)
+	}
+	return changes, nil
+}
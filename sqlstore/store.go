@@ -0,0 +1,42 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"hack/barns"
+	"hack/booking"
+	"hack/feeds"
+	"hack/horses"
+	"hack/riders"
+	"hack/rides"
+	"hack/users"
+)
+
+// Store implements the persistence interfaces of the domain packages on top
+// of a SQL database.
+type Store struct {
+	db *sql.DB
+}
+
+var (
+	_ barns.BarnStore   = (*Store)(nil)
+	_ booking.Store     = (*Store)(nil)
+	_ feeds.FeedStore   = (*Store)(nil)
+	_ horses.Store      = (*Store)(nil)
+	_ riders.RiderStore = (*Store)(nil)
+	_ rides.Store       = (*Store)(nil)
+	_ users.UserStore   = (*Store)(nil)
+
+	_ users.LocalAuthStore = (*Store)(nil)
+)
+
+func NewMySQL(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// NewSQLite wraps a database opened with the sqlite3 driver. The queries in
+// this package stick to the SQL that MySQL and SQLite share, and the scanners
+// in utils accept the text form SQLite returns for time columns.
+func NewSQLite(db *sql.DB) *Store {
+	return &Store{db: db}
+}
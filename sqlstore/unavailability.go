@@ -0,0 +1,87 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"hack/rides"
+	"hack/utils"
+)
+
+const unavailabilityColumns = "id, horse_id, reason, notes, start_date, end_date, created_by, created_at"
+
+func (s *Store) InsertUnavailability(u *rides.Unavailability) error {
+	query := "insert into horse_unavailability (horse_id, reason, notes, start_date, end_date, created_by, created_at) values (?, ?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, u.HorseID, u.Reason, nullableString(u.Notes), u.StartDate.Format("2006-01-02"), nullableDate(u.EndDate), u.CreatedBy, u.CreatedAt)
+	if err != nil {
+		return errors.New("failed to insert horse unavailability: " + err.Error())
+	}
+	u.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateUnavailability(u *rides.Unavailability) error {
+	query := "update horse_unavailability set reason = ?, notes = ?, start_date = ?, end_date = ? where id = ?"
+	_, err := s.db.Exec(query, u.Reason, nullableString(u.Notes), u.StartDate.Format("2006-01-02"), nullableDate(u.EndDate), u.ID)
+	if err != nil {
+		return errors.New("failed to update horse unavailability: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) DeleteUnavailability(id int64) error {
+	_, err := s.db.Exec("delete from horse_unavailability where id = ?", id)
+	if err != nil {
+		return errors.New("failed to delete horse unavailability: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetUnavailability(id int64) (*rides.Unavailability, error) {
+	blocks, err := s.listUnavailabilities("select "+unavailabilityColumns+" from horse_unavailability where id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return blocks[0], nil
+}
+
+func (s *Store) ListUnavailabilities(horseIDs []int64, from utils.Date, to utils.Date) ([]*rides.Unavailability, error) {
+	if len(horseIDs) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(horseIDs)), ", ")
+	query := "select " + unavailabilityColumns + " from horse_unavailability where horse_id in (" + placeholders + ") and start_date <= ? and (end_date is null or end_date >= ?) order by start_date, id"
+	var args []interface{}
+	for _, id := range horseIDs {
+		args = append(args, id)
+	}
+	args = append(args, to.Format("2006-01-02"), from.Format("2006-01-02"))
+	return s.listUnavailabilities(query, args...)
+}
+
+func (s *Store) listUnavailabilities(query string, args ...interface{}) ([]*rides.Unavailability, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select horse unavailability: " + err.Error())
+	}
+	defer rows.Close()
+	blocks := []*rides.Unavailability{}
+	for rows.Next() {
+		var u rides.Unavailability
+		var notes sql.NullString
+		err := rows.Scan(&u.ID, &u.HorseID, &u.Reason, &notes, &u.StartDate, &u.EndDate, &u.CreatedBy, &u.CreatedAt)
+		if err != nil {
+			return nil, errors.New("failed to scan horse unavailability: " + err.Error())
+		}
+		u.Notes = notes.String
+		blocks = append(blocks, &u)
+	}
+	return blocks, nil
+}
@@ -0,0 +1,53 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/users"
+	"hack/utils"
+)
+
+func (s *Store) InsertUser(u *users.User) error {
+	query := "insert into users (name, email, phone, auth_user_id, auth_method_id) values (?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, u.Name, u.Email, u.Phone, u.AuthUserID, u.AuthMethodID)
+	if err != nil {
+		return errors.New("error inserting user into database: " + err.Error())
+	}
+	u.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("error getting last insert id: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) getUser(where string, arg interface{}) (*users.User, error) {
+	query := "select id, name, email, phone, auth_user_id, auth_method_id from users where " + where
+	var u users.User
+	var methodID sql.NullString
+	err := s.db.QueryRow(query, arg).Scan(&u.ID, &u.Name, &u.Email, &u.Phone, &u.AuthUserID, &methodID)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("error getting user from database: " + err.Error())
+	}
+	u.AuthMethodID = methodID.String
+	return &u, nil
+}
+
+func (s *Store) GetUserByAuthUserID(authUserID string) (*users.User, error) {
+	return s.getUser("auth_user_id = ?", authUserID)
+}
+
+func (s *Store) GetUserByPhone(phone string) (*users.User, error) {
+	return s.getUser("phone = ?", phone)
+}
+
+func (s *Store) UpdateUserAuthMethodID(id int64, methodID string) error {
+	_, err := s.db.Exec("update users set auth_method_id = ? where id = ?", methodID, id)
+	if err != nil {
+		return errors.New("error updating user in database: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"hack/rides"
+	"hack/utils"
+)
+
+const waitlistColumns = "id, lesson_id, horse_id, (select name from horses where id = horse_id) horse_name, rider_id, (select name from riders where id = rider_id) rider_name, notes, status, created_at, offered_at, expires_at, ride_id"
+
+func (s *Store) InsertWaitlistEntry(e *rides.WaitlistEntry) error {
+	query := "insert into lesson_waitlist (lesson_id, horse_id, rider_id, notes, status, created_at) values (?, ?, ?, ?, ?, ?)"
+	result, err := s.db.Exec(query, e.LessonID, e.HorseID, e.RiderID, e.Notes, e.Status, nullableInstant(&e.CreatedAt))
+	if err != nil {
+		return errors.New("failed to insert waitlist entry: " + err.Error())
+	}
+	e.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get last insert ID: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateWaitlistEntry(e *rides.WaitlistEntry) error {
+	query := "update lesson_waitlist set status = ?, offered_at = ?, expires_at = ?, ride_id = ? where id = ?"
+	_, err := s.db.Exec(query, e.Status, nullableInstant(e.OfferedAt), nullableInstant(e.ExpiresAt), e.RideID, e.ID)
+	if err != nil {
+		return errors.New("failed to update waitlist entry: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetWaitlistEntry(id int64) (*rides.WaitlistEntry, error) {
+	entries, err := s.listWaitlist("select "+waitlistColumns+" from lesson_waitlist where id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return entries[0], nil
+}
+
+func (s *Store) ListWaitlist(lessonID int64) ([]*rides.WaitlistEntry, error) {
+	return s.listWaitlist("select "+waitlistColumns+" from lesson_waitlist where lesson_id = ? order by created_at, id", lessonID)
+}
+
+func (s *Store) listWaitlist(query string, args ...interface{}) ([]*rides.WaitlistEntry, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select waitlist: " + err.Error())
+	}
+	defer rows.Close()
+	entries := []*rides.WaitlistEntry{}
+	for rows.Next() {
+		var e rides.WaitlistEntry
+		var notes sql.NullString
+		var createdAt, offeredAt, expiresAt sql.NullTime
+		err := rows.Scan(&e.ID, &e.LessonID, &e.HorseID, &e.HorseName, &e.RiderID, &e.RiderName, &notes, &e.Status, &createdAt, &offeredAt, &expiresAt, &e.RideID)
+		if err != nil {
+			return nil, errors.New("failed to scan waitlist row: " + err.Error())
+		}
+		e.Notes = notes.String
+		e.CreatedAt = *instant(createdAt)
+		e.OfferedAt = instant(offeredAt)
+		e.ExpiresAt = instant(expiresAt)
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,80 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"hack/rides"
+	"hack/utils"
+)
+
+const workloadColumns = "horse_id, max_rides_per_day, max_minutes_per_day, max_minutes_per_week, rest_days, min_gap_minutes, enforcement"
+
+func (s *Store) InsertWorkloadRule(w *rides.WorkloadRule) error {
+	query := "insert into workload_rules (" + workloadColumns + ") values (?, ?, ?, ?, ?, ?, ?)"
+	_, err := s.db.Exec(query, w.HorseID, nullableMinutes(w.MaxRidesPerDay), nullableMinutes(w.MaxMinutesPerDay), nullableMinutes(w.MaxMinutesPerWeek), nullableString(strings.Join(w.RestDays, ",")), nullableMinutes(w.MinGapMinutes), w.Enforcement)
+	if err != nil {
+		return errors.New("failed to insert workload rule: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UpdateWorkloadRule(w *rides.WorkloadRule) error {
+	query := "update workload_rules set max_rides_per_day = ?, max_minutes_per_day = ?, max_minutes_per_week = ?, rest_days = ?, min_gap_minutes = ?, enforcement = ? where horse_id = ?"
+	_, err := s.db.Exec(query, nullableMinutes(w.MaxRidesPerDay), nullableMinutes(w.MaxMinutesPerDay), nullableMinutes(w.MaxMinutesPerWeek), nullableString(strings.Join(w.RestDays, ",")), nullableMinutes(w.MinGapMinutes), w.Enforcement, w.HorseID)
+	if err != nil {
+		return errors.New("failed to update workload rule: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) DeleteWorkloadRule(horseID int64) error {
+	_, err := s.db.Exec("delete from workload_rules where horse_id = ?", horseID)
+	if err != nil {
+		return errors.New("failed to delete workload rule: " + err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetWorkloadRule(horseID int64) (*rides.WorkloadRule, error) {
+	rules, err := s.listWorkloadRules("select "+workloadColumns+" from workload_rules where horse_id = ?", horseID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return rules[0], nil
+}
+
+func (s *Store) ListWorkloadRulesByBarn(barnID int64) ([]*rides.WorkloadRule, error) {
+	return s.listWorkloadRules("select "+workloadColumns+" from workload_rules where horse_id in (select id from horses where barn_id = ?) order by horse_id", barnID)
+}
+
+func (s *Store) listWorkloadRules(query string, args ...interface{}) ([]*rides.WorkloadRule, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("failed to select workload rules: " + err.Error())
+	}
+	defer rows.Close()
+	rules := []*rides.WorkloadRule{}
+	for rows.Next() {
+		var w rides.WorkloadRule
+		var ridesPerDay, minutesPerDay, minutesPerWeek, minGap sql.NullInt64
+		var restDays sql.NullString
+		err := rows.Scan(&w.HorseID, &ridesPerDay, &minutesPerDay, &minutesPerWeek, &restDays, &minGap, &w.Enforcement)
+		if err != nil {
+			return nil, errors.New("failed to scan workload rule: " + err.Error())
+		}
+		w.MaxRidesPerDay = int(ridesPerDay.Int64)
+		w.MaxMinutesPerDay = int(minutesPerDay.Int64)
+		w.MaxMinutesPerWeek = int(minutesPerWeek.Int64)
+		w.MinGapMinutes = int(minGap.Int64)
+		if restDays.String != "" {
+			w.RestDays = strings.Split(restDays.String, ",")
+		}
+		rules = append(rules, &w)
+	}
+	return rules, nil
+}
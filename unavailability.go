@@ -0,0 +1,184 @@
+package main
+
+import (
+	"time"
+
+	"hack/barns"
+	"hack/horses"
+	"hack/rides"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerUnavailabilityRoutes(app *fiber.App, s stores) {
+	// a new block comes back with the rides it affects, so they can be
+	// reassigned straight away
+	app.Post("/horse/:id/unavailability", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		var block rides.Unavailability
+		err = parseBody(c, &block, "unavailability")
+		if err != nil {
+			return err
+		}
+		_, err = authorizeHorse(c, s, id, barns.ManageHorses)
+		if err != nil {
+			return err
+		}
+		block.ID = 0
+		block.HorseID = id
+		block.CreatedBy = currentUser(c).ID
+		err = block.Save(s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save unavailability", err)
+		}
+		affected, err := rides.AffectedRides(&block, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get affected rides", err)
+		}
+		return c.JSON(fiber.Map{
+			"unavailability": block,
+			"affected":       affected,
+		})
+	})
+
+	// without ?from= and ?to= every block of the horse is listed
+	app.Get("/horse/:id/unavailability", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		from := utils.Date{Time: time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)}
+		to := utils.Date{Time: time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)}
+		if c.Query("from") != "" || c.Query("to") != "" {
+			from, to, err = dateRange(c)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = authorizeHorse(c, s, id, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		blocks, err := rides.ListUnavailabilities(id, from, to, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get unavailability", err)
+		}
+		return c.JSON(fiber.Map{
+			"unavailability": blocks,
+		})
+	})
+
+	app.Put("/horse/:id/unavailability/:blockID", func(c *fiber.Ctx) error {
+		block, err := unavailability(c, s, barns.ManageHorses)
+		if err != nil {
+			return err
+		}
+		var update rides.Unavailability
+		err = parseBody(c, &update, "unavailability")
+		if err != nil {
+			return err
+		}
+		update.ID = block.ID
+		update.HorseID = block.HorseID
+		update.CreatedBy = block.CreatedBy
+		update.CreatedAt = block.CreatedAt
+		err = update.Save(s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save unavailability", err)
+		}
+		affected, err := rides.AffectedRides(&update, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get affected rides", err)
+		}
+		return c.JSON(fiber.Map{
+			"unavailability": update,
+			"affected":       affected,
+		})
+	})
+
+	app.Delete("/horse/:id/unavailability/:blockID", func(c *fiber.Ctx) error {
+		block, err := unavailability(c, s, barns.ManageHorses)
+		if err != nil {
+			return err
+		}
+		err = rides.DeleteUnavailability(block.ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to delete unavailability", err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+		})
+	})
+
+	app.Get("/horse/:id/unavailability/:blockID/rides", func(c *fiber.Ctx) error {
+		block, err := unavailability(c, s, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		affected, err := rides.AffectedRides(block, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get affected rides", err)
+		}
+		return c.JSON(fiber.Map{
+			"affected": affected,
+		})
+	})
+
+	// reassign moves affected rides to another horse in the barn; each one
+	// is saved on its own, and ?override=true saves them despite conflicts
+	app.Post("/horse/:id/unavailability/:blockID/reassign", func(c *fiber.Ctx) error {
+		var re rides.Reassignment
+		err := parseBody(c, &re, "reassignment")
+		if err != nil {
+			return err
+		}
+		block, err := unavailability(c, s, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+		horse, err := horses.GetHorse(block.HorseID, s.horses)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get horse", err)
+		}
+		other, err := authorizeHorse(c, s, re.HorseID, barns.ManageRides)
+		if err != nil {
+			return err
+		}
+		if other.BarnID != horse.BarnID {
+			return fiber.NewError(fiber.StatusBadRequest, "horses belong to different barns")
+		}
+		results, err := rides.Reassign(block, re, override(c), currentUser(c).ID, s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to reassign rides", err)
+		}
+		return c.JSON(fiber.Map{
+			"results": results,
+		})
+	})
+}
+
+// unavailability loads the :blockID block of the :id horse for a member with
+// permission in its barn.
+func unavailability(c *fiber.Ctx, s stores, permission barns.Permission) (*rides.Unavailability, error) {
+	horseID, err := paramID(c, "id")
+	if err != nil {
+		return nil, err
+	}
+	id, err := paramID(c, "blockID")
+	if err != nil {
+		return nil, err
+	}
+	_, err = authorizeHorse(c, s, horseID, permission)
+	if err != nil {
+		return nil, err
+	}
+	block, err := rides.GetUnavailability(horseID, id, s.rides)
+	if err != nil {
+		return nil, failed(fiber.StatusInternalServerError, "Failed to get unavailability", err)
+	}
+	return block, nil
+}
@@ -0,0 +1,22 @@
+package users
+
+// AuthProvider is the identity service behind signup, login and sessions.
+// Stytch is one adapter; LocalProvider runs everything in our own database.
+type AuthProvider interface {
+	// SendOTP sends a one-time passcode to phone, creating the identity
+	// if needed, and returns who it was sent to.
+	SendOTP(phone string) (*OTPChallenge, error)
+	// VerifyOTP checks a passcode against the method it was sent through and
+	// starts a session.
+	VerifyOTP(methodID string, code string) (sessionToken string, err error)
+	// ValidateSession returns the provider's user ID for a live session.
+	ValidateSession(sessionToken string) (authUserID string, err error)
+	RevokeSession(sessionToken string) error
+}
+
+type OTPChallenge struct {
+	AuthUserID string
+	MethodID   string
+}
+
+const sessionDurationMinutes = 7 * 24 * 60
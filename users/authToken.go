@@ -2,31 +2,23 @@ package users
 
 import (
 	"errors"
-
-	"github.com/stytchauth/stytch-go/v4/stytch"
-	"github.com/stytchauth/stytch-go/v4/stytch/stytchapi"
 )
 
 type Session struct {
-	Token string `json:"token"`
+	Token      string `json:"token"`
+	AuthUserID string `json:"-"`
 }
 
-const sessionDurationMinutes = 7 * 24 * 60
-
-func (s *Session) Validate(client *stytchapi.API) error {
+func (s *Session) Validate(provider AuthProvider) error {
 	if s.Token == "" {
 		return errors.New("session token is required")
 	}
 
-	params := &stytch.SessionsAuthenticateParams{
-		SessionToken:           s.Token,
-		SessionDurationMinutes: sessionDurationMinutes,
-	}
-
-	_, err := client.Sessions.Authenticate(params)
+	authUserID, err := provider.ValidateSession(s.Token)
 	if err != nil {
-		return errors.New("error authenticating session: " + err.Error())
+		return err
 	}
+	s.AuthUserID = authUserID
 
 	return nil
 }
@@ -0,0 +1,238 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"hack/utils"
+)
+
+const (
+	otpExpiration  = 10 * time.Minute
+	otpMaxAttempts = 5
+	localUserIDTag = "local-"
+)
+
+type LocalOTP struct {
+	MethodID   string
+	IdentityID int64
+	CodeHash   string
+	ExpiresAt  time.Time
+	Attempts   int
+	UsedAt     *time.Time
+}
+
+type LocalSession struct {
+	ID         string
+	IdentityID int64
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+type LocalAuthStore interface {
+	GetOrCreateLocalIdentity(phone string) (int64, error)
+	InsertOTP(o *LocalOTP) error
+	GetOTP(methodID string) (*LocalOTP, error)
+	UpdateOTP(o *LocalOTP) error
+	InsertLocalSession(s *LocalSession) error
+	GetLocalSession(id string) (*LocalSession, error)
+	RevokeLocalSession(id string, at time.Time) error
+}
+
+// SMSSender delivers the passcodes LocalProvider generates.
+type SMSSender interface {
+	SendSMS(phone string, message string) error
+}
+
+// LogSender prints passcodes to stdout instead of texting them, for running
+// offline.
+type LogSender struct{}
+
+func (LogSender) SendSMS(phone string, message string) error {
+	fmt.Printf("sms to %s: %s\n", phone, message)
+	return nil
+}
+
+// LocalProvider generates passcodes itself, keeps only their HMACs, and
+// issues session tokens signed with secret. Sessions are also recorded in the
+// store so they can be revoked before they expire.
+type LocalProvider struct {
+	store  LocalAuthStore
+	sender SMSSender
+	secret []byte
+	now    func() time.Time
+}
+
+func NewLocalProvider(store LocalAuthStore, sender SMSSender, secret string) (*LocalProvider, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("local auth secret must be at least 32 characters")
+	}
+	return &LocalProvider{
+		store:  store,
+		sender: sender,
+		secret: []byte(secret),
+		now:    time.Now,
+	}, nil
+}
+
+func (p *LocalProvider) SendOTP(phone string) (*OTPChallenge, error) {
+	if phone == "" {
+		return nil, errors.New("phone is required")
+	}
+	identityID, err := p.store.GetOrCreateLocalIdentity(phone)
+	if err != nil {
+		return nil, err
+	}
+	code, err := randomCode()
+	if err != nil {
+		return nil, err
+	}
+	methodID, err := randomID("otp-")
+	if err != nil {
+		return nil, err
+	}
+	otp := LocalOTP{
+		MethodID:   methodID,
+		IdentityID: identityID,
+		CodeHash:   p.hash(methodID + ":" + code),
+		ExpiresAt:  p.now().Add(otpExpiration).UTC(),
+	}
+	err = p.store.InsertOTP(&otp)
+	if err != nil {
+		return nil, err
+	}
+	err = p.sender.SendSMS(phone, "Your login code is "+code)
+	if err != nil {
+		return nil, errors.New("error sending SMS: " + err.Error())
+	}
+	return &OTPChallenge{
+		AuthUserID: localUserIDTag + strconv.FormatInt(identityID, 10),
+		MethodID:   methodID,
+	}, nil
+}
+
+func (p *LocalProvider) VerifyOTP(methodID string, code string) (string, error) {
+	otp, err := p.store.GetOTP(methodID)
+	if err == utils.ErrNotFound {
+		return "", errors.New("passcode authentication failed")
+	}
+	if err != nil {
+		return "", err
+	}
+	if otp.UsedAt != nil || otp.Attempts >= otpMaxAttempts || p.now().After(otp.ExpiresAt) {
+		return "", errors.New("passcode authentication failed")
+	}
+	otp.Attempts++
+	if !hmac.Equal([]byte(otp.CodeHash), []byte(p.hash(methodID+":"+code))) {
+		err = p.store.UpdateOTP(otp)
+		if err != nil {
+			return "", err
+		}
+		return "", errors.New("passcode authentication failed")
+	}
+	now := p.now().UTC()
+	otp.UsedAt = &now
+	err = p.store.UpdateOTP(otp)
+	if err != nil {
+		return "", err
+	}
+
+	sessionID, err := randomID("")
+	if err != nil {
+		return "", err
+	}
+	session := LocalSession{
+		ID:         sessionID,
+		IdentityID: otp.IdentityID,
+		ExpiresAt:  now.Add(sessionDurationMinutes * time.Minute),
+	}
+	err = p.store.InsertLocalSession(&session)
+	if err != nil {
+		return "", err
+	}
+	payload := session.ID + "." + strconv.FormatInt(session.ExpiresAt.Unix(), 10)
+	return payload + "." + p.sign(payload), nil
+}
+
+func (p *LocalProvider) ValidateSession(sessionToken string) (string, error) {
+	session, err := p.session(sessionToken)
+	if err != nil {
+		return "", err
+	}
+	return localUserIDTag + strconv.FormatInt(session.IdentityID, 10), nil
+}
+
+func (p *LocalProvider) RevokeSession(sessionToken string) error {
+	session, err := p.session(sessionToken)
+	if err != nil {
+		return err
+	}
+	return p.store.RevokeLocalSession(session.ID, p.now().UTC())
+}
+
+func (p *LocalProvider) session(sessionToken string) (*LocalSession, error) {
+	parts := strings.Split(sessionToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed session token")
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(p.sign(payload))) {
+		return nil, errors.New("invalid session token signature")
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errors.New("malformed session token")
+	}
+	if p.now().Unix() > expiresAt {
+		return nil, errors.New("session expired")
+	}
+	session, err := p.store.GetLocalSession(parts[0])
+	if err == utils.ErrNotFound {
+		return nil, errors.New("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, errors.New("session revoked")
+	}
+	return session, nil
+}
+
+func (p *LocalProvider) hash(value string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *LocalProvider) sign(payload string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte("session:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", errors.New("failed to generate passcode: " + err.Error())
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func randomID(prefix string) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.New("failed to generate id: " + err.Error())
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,289 @@
+package users
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"hack/utils"
+)
+
+// memoryAuthStore keeps local identities, passcodes, sessions and users in
+// memory.
+type memoryAuthStore struct {
+	identities map[string]int64
+	otps       map[string]*LocalOTP
+	sessions   map[string]*LocalSession
+	users      []*User
+}
+
+func newMemoryAuthStore() *memoryAuthStore {
+	return &memoryAuthStore{
+		identities: make(map[string]int64),
+		otps:       make(map[string]*LocalOTP),
+		sessions:   make(map[string]*LocalSession),
+	}
+}
+
+func (m *memoryAuthStore) GetOrCreateLocalIdentity(phone string) (int64, error) {
+	id, ok := m.identities[phone]
+	if !ok {
+		id = int64(len(m.identities) + 1)
+		m.identities[phone] = id
+	}
+	return id, nil
+}
+
+func (m *memoryAuthStore) InsertOTP(o *LocalOTP) error {
+	otp := *o
+	m.otps[o.MethodID] = &otp
+	return nil
+}
+
+func (m *memoryAuthStore) GetOTP(methodID string) (*LocalOTP, error) {
+	o, ok := m.otps[methodID]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	otp := *o
+	return &otp, nil
+}
+
+func (m *memoryAuthStore) UpdateOTP(o *LocalOTP) error {
+	otp := *o
+	m.otps[o.MethodID] = &otp
+	return nil
+}
+
+func (m *memoryAuthStore) InsertLocalSession(s *LocalSession) error {
+	session := *s
+	m.sessions[s.ID] = &session
+	return nil
+}
+
+func (m *memoryAuthStore) GetLocalSession(id string) (*LocalSession, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	session := *s
+	return &session, nil
+}
+
+func (m *memoryAuthStore) RevokeLocalSession(id string, at time.Time) error {
+	s, ok := m.sessions[id]
+	if !ok {
+		return utils.ErrNotFound
+	}
+	s.RevokedAt = &at
+	return nil
+}
+
+func (m *memoryAuthStore) InsertUser(u *User) error {
+	user := *u
+	user.ID = int64(len(m.users) + 1)
+	u.ID = user.ID
+	m.users = append(m.users, &user)
+	return nil
+}
+
+func (m *memoryAuthStore) GetUserByAuthUserID(authUserID string) (*User, error) {
+	for _, u := range m.users {
+		if u.AuthUserID == authUserID {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, utils.ErrNotFound
+}
+
+func (m *memoryAuthStore) GetUserByPhone(phone string) (*User, error) {
+	for _, u := range m.users {
+		if u.Phone == phone {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, utils.ErrNotFound
+}
+
+func (m *memoryAuthStore) UpdateUserAuthMethodID(id int64, methodID string) error {
+	for _, u := range m.users {
+		if u.ID == id {
+			u.AuthMethodID = methodID
+			return nil
+		}
+	}
+	return utils.ErrNotFound
+}
+
+// inbox keeps the last passcode texted to each phone.
+type inbox map[string]string
+
+func (i inbox) SendSMS(phone string, message string) error {
+	i[phone] = message[strings.LastIndex(message, " ")+1:]
+	return nil
+}
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func newTestProvider(t *testing.T) (*LocalProvider, *memoryAuthStore, inbox) {
+	store := newMemoryAuthStore()
+	sent := inbox{}
+	provider, err := NewLocalProvider(store, sent, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider, store, sent
+}
+
+func TestLocalProviderSessionLifecycle(t *testing.T) {
+	provider, store, sent := newTestProvider(t)
+	phone := "+15550000001"
+
+	signup := User{Name: "Ann", Phone: phone}
+	err := signup.Signup(provider, store)
+	if err != nil {
+		t.Fatalf("signup: %v", err)
+	}
+	if sent[phone] == "" {
+		t.Fatal("signup did not text a passcode")
+	}
+
+	// logging in sends a new passcode and only that one works
+	signupCode := sent[phone]
+	login := User{Phone: phone}
+	err = login.Login(provider, store)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if login.ID != signup.ID || login.AuthUserID != signup.AuthUserID {
+		t.Fatalf("login found user %d (%s), want %d (%s)", login.ID, login.AuthUserID, signup.ID, signup.AuthUserID)
+	}
+	if signupCode != sent[phone] {
+		stale := UserAuth{Phone: phone, Passcode: signupCode}
+		_, err = stale.AuthenticatePasscode(provider, store)
+		if err == nil {
+			t.Fatal("the signup passcode still works after login sent a new one")
+		}
+	}
+
+	auth := UserAuth{Phone: phone, Passcode: sent[phone]}
+	user, err := auth.AuthenticatePasscode(provider, store)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if user.SessionToken == "" {
+		t.Fatal("authenticate did not start a session")
+	}
+	_, err = auth.AuthenticatePasscode(provider, store)
+	if err == nil {
+		t.Fatal("a passcode worked twice")
+	}
+
+	session := Session{Token: user.SessionToken}
+	err = session.Validate(provider)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if session.AuthUserID != signup.AuthUserID {
+		t.Fatalf("session is for %s, want %s", session.AuthUserID, signup.AuthUserID)
+	}
+
+	err = Logout(user.SessionToken, provider)
+	if err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	err = session.Validate(provider)
+	if err == nil {
+		t.Fatal("a revoked session still validates")
+	}
+}
+
+func TestLocalProviderRejectsBadPasscodes(t *testing.T) {
+	provider, _, sent := newTestProvider(t)
+	phone := "+15550000002"
+	challenge, err := provider.SendOTP(phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "000000"
+	if sent[phone] == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < otpMaxAttempts; i++ {
+		_, err = provider.VerifyOTP(challenge.MethodID, wrong)
+		if err == nil {
+			t.Fatal("a wrong passcode worked")
+		}
+	}
+	_, err = provider.VerifyOTP(challenge.MethodID, sent[phone])
+	if err == nil {
+		t.Fatalf("the passcode worked after %d wrong attempts", otpMaxAttempts)
+	}
+
+	challenge, err = provider.SendOTP(phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.now = func() time.Time { return time.Now().Add(otpExpiration + time.Minute) }
+	_, err = provider.VerifyOTP(challenge.MethodID, sent[phone])
+	if err == nil {
+		t.Fatal("an expired passcode worked")
+	}
+}
+
+func TestLocalProviderRejectsBadTokens(t *testing.T) {
+	provider, _, sent := newTestProvider(t)
+	phone := "+15550000003"
+	challenge, err := provider.SendOTP(phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := provider.VerifyOTP(challenge.MethodID, sent[phone])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"malformed", "not-a-token"},
+		{"tampered", token[:strings.LastIndex(token, ".")+1] + "forged"},
+		{"other secret", func() string {
+			other, err := NewLocalProvider(newMemoryAuthStore(), inbox{}, strings.Repeat("x", 32))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts := strings.SplitN(token, ".", 3)
+			payload := parts[0] + "." + parts[1]
+			return payload + "." + other.sign(payload)
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.ValidateSession(tt.token)
+			if err == nil {
+				t.Fatal("the token validated")
+			}
+		})
+	}
+
+	provider.now = func() time.Time { return time.Now().Add(sessionDurationMinutes*time.Minute + time.Minute) }
+	_, err = provider.ValidateSession(token)
+	if err == nil {
+		t.Fatal("an expired session validated")
+	}
+}
+
+func TestUserKeepsStytchUserIDName(t *testing.T) {
+	body, err := json.Marshal(User{AuthUserID: "local-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"stytch_user_id":"local-1"`) {
+		t.Fatalf("user marshals as %s", body)
+	}
+}
@@ -1,74 +1,61 @@
 package users
 
 import (
-	"database/sql"
 	"errors"
 
-	"github.com/stytchauth/stytch-go/v4/stytch"
-	"github.com/stytchauth/stytch-go/v4/stytch/stytchapi"
+	"hack/utils"
 )
 
 type User struct {
-	ID             int64  `json:"id"`
-	Name           string `json:"name"`
-	Email          string `json:"email"`
-	Phone          string `json:"phone"`
-	StytchUserID   string `json:"stytch_user_id"`
-	SessionToken   string `json:"session_token"`
-	stytchMethodID sql.NullString
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+	// AuthUserID keeps the JSON name it had when Stytch was the only
+	// provider, so clients reading it do not break.
+	AuthUserID   string `json:"stytch_user_id"`
+	SessionToken string `json:"session_token"`
+	AuthMethodID string `json:"-"`
 }
 
-func (u *User) Signup(client *stytchapi.API, db *sql.DB) error {
-	params := &stytch.OTPsSMSLoginOrCreateParams{
-		PhoneNumber: u.Phone,
-	}
-	resp, err := client.OTPs.SMS.LoginOrCreate(params)
-	if err != nil {
-		return errors.New("error sending SMS: " + err.Error())
-	}
-	u.StytchUserID = resp.UserID
-	u.stytchMethodID.String = resp.PhoneID
-	u.stytchMethodID.Valid = true
+type UserStore interface {
+	InsertUser(u *User) error
+	GetUserByAuthUserID(authUserID string) (*User, error)
+	GetUserByPhone(phone string) (*User, error)
+	UpdateUserAuthMethodID(id int64, methodID string) error
+}
 
-	// insert user into database
-	result, err := db.Exec("INSERT INTO users (name, email, phone, stytch_user_id, stytch_method_id) VALUES (?, ?, ?, ?, ?)", u.Name, u.Email, u.Phone, u.StytchUserID, u.stytchMethodID.String)
-	if err != nil {
-		return errors.New("error inserting user into database: " + err.Error())
-	}
-	u.ID, err = result.LastInsertId()
+func (u *User) Signup(provider AuthProvider, store UserStore) error {
+	challenge, err := provider.SendOTP(u.Phone)
 	if err != nil {
-		return errors.New("error getting last insert id: " + err.Error())
+		return err
 	}
+	u.AuthUserID = challenge.AuthUserID
+	u.AuthMethodID = challenge.MethodID
 
-	return nil
+	return store.InsertUser(u)
 }
 
-func (u *User) Login(client *stytchapi.API, db *sql.DB) error {
-	params := &stytch.OTPsSMSLoginOrCreateParams{
-		PhoneNumber: u.Phone,
-	}
-	resp, err := client.OTPs.SMS.LoginOrCreate(params)
+func (u *User) Login(provider AuthProvider, store UserStore) error {
+	challenge, err := provider.SendOTP(u.Phone)
 	if err != nil {
-		return errors.New("error sending SMS: " + err.Error())
+		return err
 	}
-	u.StytchUserID = resp.UserID
-	u.stytchMethodID.String = resp.PhoneID
-	u.stytchMethodID.Valid = true
+	u.AuthUserID = challenge.AuthUserID
+	u.AuthMethodID = challenge.MethodID
 
-	err = db.QueryRow("select id, name, email from users where stytch_user_id = ?", u.StytchUserID).Scan(&u.ID, &u.Name, &u.Email)
-	if err == sql.ErrNoRows {
+	existing, err := store.GetUserByAuthUserID(u.AuthUserID)
+	if err == utils.ErrNotFound {
 		return errors.New("user not found")
 	}
 	if err != nil {
-		return errors.New("error getting user from database: " + err.Error())
+		return err
 	}
+	u.ID = existing.ID
+	u.Name = existing.Name
+	u.Email = existing.Email
 	// update method id
-	_, err = db.Exec("UPDATE users SET stytch_method_id = ? WHERE id = ?", u.stytchMethodID.String, u.ID)
-	if err != nil {
-		return errors.New("error updating user in database: " + err.Error())
-	}
-
-	return nil
+	return store.UpdateUserAuthMethodID(u.ID, u.AuthMethodID)
 }
 
 type UserAuth struct {
@@ -76,29 +63,25 @@ type UserAuth struct {
 	Passcode string `json:"passcode"`
 }
 
-func (a *UserAuth) AuthenticatePasscode(client *stytchapi.API, db *sql.DB) (*User, error) {
-	var u User
-	u.Phone = a.Phone
-	query := "select id, stytch_method_id from users where phone = ?"
-	err := db.QueryRow(query, a.Phone).Scan(&u.ID, &u.stytchMethodID)
+func (a *UserAuth) AuthenticatePasscode(provider AuthProvider, store UserStore) (*User, error) {
+	existing, err := store.GetUserByPhone(a.Phone)
 	if err != nil {
 		return nil, errors.New("error getting user from database: " + err.Error())
 	}
-	if !u.stytchMethodID.Valid {
-		return nil, errors.New("user has no Stytch method id")
+	u := User{
+		ID:           existing.ID,
+		Name:         existing.Name,
+		Email:        existing.Email,
+		Phone:        a.Phone,
+		AuthUserID:   existing.AuthUserID,
+		AuthMethodID: existing.AuthMethodID,
 	}
-	params := &stytch.OTPsAuthenticateParams{
-		MethodID:               u.stytchMethodID.String,
-		Code:                   a.Passcode,
-		SessionDurationMinutes: sessionDurationMinutes,
+	if u.AuthMethodID == "" {
+		return nil, errors.New("user has no auth method id")
 	}
-	resp, err := client.OTPs.Authenticate(params)
+	u.SessionToken, err = provider.VerifyOTP(u.AuthMethodID, a.Passcode)
 	if err != nil {
-		return nil, errors.New("error authenticating passcode: " + err.Error())
-	}
-	if resp.StatusCode != 200 {
-		return nil, errors.New("passcode authentication failed")
+		return nil, err
 	}
-	u.SessionToken = resp.SessionToken
 	return &u, nil
 }
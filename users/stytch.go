@@ -0,0 +1,74 @@
+package users
+
+import (
+	"errors"
+
+	"github.com/stytchauth/stytch-go/v4/stytch"
+	"github.com/stytchauth/stytch-go/v4/stytch/config"
+	"github.com/stytchauth/stytch-go/v4/stytch/stytchapi"
+)
+
+type StytchProvider struct {
+	client *stytchapi.API
+}
+
+func NewStytchProvider(env config.Env, projectID string, secret string) (*StytchProvider, error) {
+	client, err := stytchapi.NewAPIClient(env, projectID, secret)
+	if err != nil {
+		return nil, errors.New("failed to create stytch client: " + err.Error())
+	}
+	return &StytchProvider{client: client}, nil
+}
+
+func (p *StytchProvider) SendOTP(phone string) (*OTPChallenge, error) {
+	params := &stytch.OTPsSMSLoginOrCreateParams{
+		PhoneNumber: phone,
+	}
+	resp, err := p.client.OTPs.SMS.LoginOrCreate(params)
+	if err != nil {
+		return nil, errors.New("error sending SMS: " + err.Error())
+	}
+	return &OTPChallenge{
+		AuthUserID: resp.UserID,
+		MethodID:   resp.PhoneID,
+	}, nil
+}
+
+func (p *StytchProvider) VerifyOTP(methodID string, code string) (string, error) {
+	params := &stytch.OTPsAuthenticateParams{
+		MethodID:               methodID,
+		Code:                   code,
+		SessionDurationMinutes: sessionDurationMinutes,
+	}
+	resp, err := p.client.OTPs.Authenticate(params)
+	if err != nil {
+		return "", errors.New("error authenticating passcode: " + err.Error())
+	}
+	if resp.StatusCode != 200 {
+		return "", errors.New("passcode authentication failed")
+	}
+	return resp.SessionToken, nil
+}
+
+func (p *StytchProvider) ValidateSession(sessionToken string) (string, error) {
+	params := &stytch.SessionsAuthenticateParams{
+		SessionToken:           sessionToken,
+		SessionDurationMinutes: sessionDurationMinutes,
+	}
+	resp, err := p.client.Sessions.Authenticate(params)
+	if err != nil {
+		return "", errors.New("error authenticating session: " + err.Error())
+	}
+	return resp.Session.UserID, nil
+}
+
+func (p *StytchProvider) RevokeSession(sessionToken string) error {
+	params := stytch.SessionsRevokeParams{
+		SessionToken: sessionToken,
+	}
+	_, err := p.client.Sessions.Revoke(&params)
+	if err != nil {
+		return errors.New("error revoking session: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+package utils
+
+import "errors"
+
+// ErrNotFound is returned by stores when a lookup matches no rows.
+var ErrNotFound = errors.New("not found")
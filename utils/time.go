@@ -29,11 +29,30 @@ func (d *Date) UnmarshalJSON(data []byte) error {
 }
 
 func (d *Date) Scan(value interface{}) error {
-	if value == nil {
+	switch v := value.(type) {
+	case nil:
 		return nil
+	case time.Time:
+		*d = Date{v}
+		return nil
+	case []byte:
+		return d.scanString(string(v))
+	case string:
+		return d.scanString(v)
+	default:
+		return errors.New("failed to scan date: invalid type")
+	}
+}
+
+func (d *Date) scanString(s string) error {
+	if len(s) > len("2006-01-02") {
+		s = s[:len("2006-01-02")]
 	}
-	t := value.(time.Time)
-	*d = Date{t}
+	parsedTime, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return errors.New("failed to parse date: " + err.Error())
+	}
+	*d = Date{parsedTime}
 	return nil
 }
 
@@ -67,13 +86,26 @@ func (t *Time) MarshalJSON() ([]byte, error) {
 	return json.Marshal("")
 }
 
+// Scan accepts the column as MySQL sends it ([]byte), as SQLite sends it
+// (string) or as a driver that already parsed it (time.Time).
 func (t *Time) Scan(value interface{}) error {
-	if value == nil {
+	var s string
+	switch v := value.(type) {
+	case nil:
 		t.Valid = false
 		return nil
+	case time.Time:
+		t.Valid = true
+		t.Time = time.Date(0, 1, 1, v.Hour(), v.Minute(), v.Second(), 0, time.UTC)
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return errors.New("failed to scan time: invalid type")
 	}
-	b := value.([]byte)
-	parsedTime, err := time.Parse("15:04:05", string(b))
+	parsedTime, err := time.Parse("15:04:05", s)
 	if err != nil {
 		return errors.New("failed to parse time: " + err.Error())
 	}
@@ -88,3 +120,13 @@ func (t *Time) Value() (driver.Value, error) {
 	}
 	return t.Time.Format("15:04:05"), nil
 }
+
+// Location loads an IANA time zone, falling back to UTC for names that do
+// not load so a bad value cannot break a schedule.
+func Location(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
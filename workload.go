@@ -0,0 +1,107 @@
+package main
+
+import (
+	"time"
+
+	"hack/barns"
+	"hack/recurrence"
+	"hack/rides"
+	"hack/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerWorkloadRoutes(app *fiber.App, s stores) {
+	app.Get("/horse/:id/workload", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		_, err = authorizeHorse(c, s, id, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		rule, err := rides.GetWorkloadRule(id, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get workload rule", err)
+		}
+		return c.JSON(fiber.Map{
+			"rule": rule,
+		})
+	})
+
+	app.Put("/horse/:id/workload", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		var rule rides.WorkloadRule
+		err = parseBody(c, &rule, "workload rule")
+		if err != nil {
+			return err
+		}
+		_, err = authorizeHorse(c, s, id, barns.ManageHorses)
+		if err != nil {
+			return err
+		}
+		rule.HorseID = id
+		err = rule.Save(s.rides)
+		if err != nil {
+			return failed(fiber.StatusBadRequest, "Failed to save workload rule", err)
+		}
+		return c.JSON(fiber.Map{
+			"rule": rule,
+		})
+	})
+
+	app.Delete("/horse/:id/workload", func(c *fiber.Ctx) error {
+		id, err := paramID(c, "id")
+		if err != nil {
+			return err
+		}
+		_, err = authorizeHorse(c, s, id, barns.ManageHorses)
+		if err != nil {
+			return err
+		}
+		err = rides.DeleteWorkloadRule(id, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to delete workload rule", err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+		})
+	})
+
+	// workload lists the horses over a limit in the week of ?week=, any date
+	// in it, or this week
+	app.Get("/barn/:barnID/workload", func(c *fiber.Ctx) error {
+		barnID, err := paramID(c, "barnID")
+		if err != nil {
+			return err
+		}
+		err = authorizeBarn(c, s, barnID, barns.ViewBarn)
+		if err != nil {
+			return err
+		}
+		var week time.Time
+		if raw := c.Query("week"); raw != "" {
+			week, err = time.Parse("2006-01-02", raw)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "Failed to parse week: "+err.Error())
+			}
+		} else {
+			barn, err := barns.GetBarn(barnID, s.barns)
+			if err != nil {
+				return failed(fiber.StatusInternalServerError, "Failed to get barn", err)
+			}
+			week = recurrence.Day(time.Now().In(utils.Location(barn.TimeZone)))
+		}
+		horses, err := rides.GetWorkloadReport(barnID, utils.Date{Time: week}, s.rides)
+		if err != nil {
+			return failed(fiber.StatusInternalServerError, "Failed to get workload report", err)
+		}
+		return c.JSON(fiber.Map{
+			"horses": horses,
+		})
+	})
+}